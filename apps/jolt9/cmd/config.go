@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the jolt9 configuration",
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration",
+	Long: `Show the effective configuration after merging the system config,
+the user config and the project jolt9.yaml.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		origin, _ := cmd.Flags().GetBool("origin")
+		project, _ := cmd.Flags().GetString("project")
+
+		merged, err := configs.LoadMerged(project)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, entry := range configEntries(merged.Config) {
+			if origin {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", entry[0], entry[1], merged.Origin(entry[0]))
+				continue
+			}
+
+			fmt.Fprintf(w, "%s\t%s\n", entry[0], entry[1])
+		}
+
+		return w.Flush()
+	},
+}
+
+// configEntries flattens the config into sorted key/value pairs
+// where the key matches the keys of MergedConfig.Origins.
+func configEntries(cfg *configs.ProjectConfig) [][2]string {
+	entries := [][2]string{}
+	add := func(key, value string) {
+		entries = append(entries, [2]string{key, value})
+	}
+
+	if cfg.Id != "" {
+		add("id", cfg.Id)
+	}
+
+	if cfg.Version != "" {
+		add("version", cfg.Version)
+	}
+
+	if cfg.Context.Default != "" {
+		add("context.default", cfg.Context.Default)
+	}
+
+	for _, name := range cfg.Vaults.Names() {
+		item, _ := cfg.Vaults.Get(name)
+		add("vaults."+name, item.Uri)
+	}
+
+	for _, name := range cfg.Envs.Names() {
+		item, _ := cfg.Envs.Get(name)
+		parts := []string{}
+		if len(item.Imports) > 0 {
+			parts = append(parts, "imports="+strings.Join(item.Imports, ","))
+		}
+
+		keys := make([]string, 0, len(item.Vars))
+		for k := range item.Vars {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		if len(keys) > 0 {
+			parts = append(parts, "vars="+strings.Join(keys, ","))
+		}
+
+		add("envs."+name, strings.Join(parts, " "))
+	}
+
+	for _, name := range cfg.Dns.Names() {
+		item, _ := cfg.Dns.Get(name)
+		add("dns."+name, item.Uri)
+	}
+
+	for _, name := range cfg.Traefik.Names() {
+		item, _ := cfg.Traefik.Get(name)
+		value := ""
+		if item.Acme != nil {
+			value = "acme.dns=" + item.Acme.Dns
+		}
+
+		add("traefik."+name, value)
+	}
+
+	for _, name := range cfg.Contexts.Names() {
+		item, _ := cfg.Contexts.Get(name)
+		add("contexts."+name, fmt.Sprintf("vaults=%s envs=%s dns=%s servers=%s",
+			strings.Join(item.Vaults, ","), strings.Join(item.Envs, ","), item.Dns, strings.Join(item.Servers, ",")))
+	}
+
+	for _, name := range cfg.Jobs.Names() {
+		item, _ := cfg.Jobs.Get(name)
+		add("jobs."+name, fmt.Sprintf("%d task(s)", len(item.Tasks)))
+	}
+
+	for _, host := range cfg.Inventory.Hosts() {
+		add("inventory."+host.Name, host.Host)
+	}
+
+	if len(cfg.Compose.Include) > 0 {
+		add("compose.include", strings.Join(cfg.Compose.Include, ","))
+	}
+
+	if len(cfg.Compose.Exclude) > 0 {
+		add("compose.exclude", strings.Join(cfg.Compose.Exclude, ","))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i][0] < entries[j][0]
+	})
+
+	return entries
+}
+
+func init() {
+	configShowCmd.Flags().Bool("origin", false, "Print the file each value came from")
+	configCmd.AddCommand(configShowCmd)
+	rootCmd.AddCommand(configCmd)
+}
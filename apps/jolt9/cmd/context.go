@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/jolt9dev/jolt9/pkg/inventory"
+	"github.com/spf13/cobra"
+)
+
+var contextCmd = &cobra.Command{
+	Use:     "context",
+	Aliases: []string{"ctx"},
+	Short:   "Manage the deployment contexts of the project",
+	Long: `Manage the deployment contexts of the project. A context selects
+the vaults, envs, dns and servers to use. The context is picked from
+--context, $J9_CONTEXT, the context set with "jolt9 context use",
+context.default of the config and finally "default".`,
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the contexts of the project",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merged, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		cfg := merged.Config
+		current, _, err := selectContext(cmd, cfg)
+		if err != nil {
+			return err
+		}
+
+		names := cfg.Contexts.Names()
+		if !cfg.Contexts.Has(ctxs.DefaultContext) {
+			names = append(names, ctxs.DefaultContext)
+		}
+
+		sort.Strings(names)
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "\tNAME\tVAULTS\tENVS\tDNS\tSERVERS")
+		for _, name := range names {
+			item, _ := cfg.Contexts.Get(name)
+			marker := ""
+			if name == current {
+				marker = "*"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", marker, name,
+				orDash(strings.Join(item.Vaults, ",")),
+				orDash(strings.Join(item.Envs, ",")),
+				orDash(item.Dns),
+				orDash(strings.Join(item.Servers, ",")))
+		}
+
+		return w.Flush()
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use <context>",
+	Short: "Set the active context of the project",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merged, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		cfg := merged.Config
+		name := args[0]
+		if _, err := ctxs.Resolve(cfg, name); err != nil {
+			return err
+		}
+
+		store, err := ctxs.NewContextStore()
+		if err != nil {
+			return err
+		}
+
+		if err := store.Set(filepath.Dir(cfg.File), name); err != nil {
+			return err
+		}
+
+		fmt.Printf("Switched to context %q\n", name)
+		return nil
+	},
+}
+
+var contextCurrentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Show the context in use and where it was selected from",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merged, err := loadConfig(cmd)
+		if err != nil {
+			return err
+		}
+
+		name, source, err := selectContext(cmd, merged.Config)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s\t(%s)\n", name, source)
+		return nil
+	},
+}
+
+// loadConfig loads the effective configuration with the cached facts
+// of the inventory applied.
+func loadConfig(cmd *cobra.Command) (*configs.MergedConfig, error) {
+	project, _ := cmd.Flags().GetString("project")
+
+	merged, err := configs.LoadMerged(project)
+	if err != nil {
+		return nil, err
+	}
+
+	if cache, err := inventory.NewFactsCache(merged.Config.File); err == nil {
+		if err := cache.Apply(&merged.Config.Inventory); err != nil {
+			return nil, err
+		}
+	}
+
+	return merged, nil
+}
+
+func selectContext(cmd *cobra.Command, cfg *configs.ProjectConfig) (string, string, error) {
+	flag, _ := cmd.Flags().GetString("context")
+
+	store, err := ctxs.NewContextStore()
+	if err != nil {
+		return "", "", err
+	}
+
+	return ctxs.SelectContext(flag, cfg, store)
+}
+
+// loadRuntime loads the configuration and resolves the selected
+// context.
+func loadRuntime(cmd *cobra.Command) (*configs.MergedConfig, *ctxs.Runtime, error) {
+	merged, err := loadConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name, _, err := selectContext(cmd, merged.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rt, err := ctxs.Resolve(merged.Config, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return merged, rt, nil
+}
+
+func init() {
+	contextCmd.AddCommand(contextListCmd, contextUseCmd, contextCurrentCmd)
+	rootCmd.AddCommand(contextCmd)
+}
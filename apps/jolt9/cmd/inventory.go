@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/jolt9dev/jolt9/pkg/inventory"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:     "inventory",
+	Aliases: []string{"inv"},
+	Short:   "Inspect the hosts of the inventory",
+	Long: `Inspect the hosts of the inventory. Hosts are selected by name,
+glob (web-*), group (group:web) or fact (facts.os.family=debian).`,
+}
+
+var inventoryListCmd = &cobra.Command{
+	Use:   "list [selector...]",
+	Short: "List the hosts of the inventory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hosts, _, err := selectHosts(cmd, args)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tHOST\tUSER\tPORT\tGROUPS")
+		for _, host := range hosts {
+			port := "-"
+			if host.Port != 0 {
+				port = fmt.Sprint(host.Port)
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", host.Name, orDash(host.Host), orDash(host.User), port, orDash(strings.Join(host.Groups, ",")))
+		}
+
+		return w.Flush()
+	},
+}
+
+var inventoryShowCmd = &cobra.Command{
+	Use:   "show <host>",
+	Short: "Show a host with its vars and facts",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inv, _, err := loadInventory(cmd)
+		if err != nil {
+			return err
+		}
+
+		host, ok := inv.Get(args[0])
+		if !ok {
+			return fmt.Errorf("host %s not found in inventory", args[0])
+		}
+
+		return yaml.NewEncoder(os.Stdout).Encode(hostDocument(host))
+	},
+}
+
+var inventoryFactsCmd = &cobra.Command{
+	Use:   "facts [selector...]",
+	Short: "Show the facts of hosts",
+	Long: `Show the facts of hosts. With --refresh the facts are gathered
+from the hosts over ssh and cached for later runs.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		refresh, _ := cmd.Flags().GetBool("refresh")
+
+		// the runtime is loaded the same way as for `jolt9 run`, so that
+		// facts are gathered with the ssh_config of the context.
+		merged, rt, err := loadRuntime(cmd)
+		if err != nil {
+			return err
+		}
+
+		cache, err := inventory.NewFactsCache(merged.Config.File)
+		if err != nil {
+			return err
+		}
+
+		hosts, err := matchHosts(&merged.Config.Inventory, args)
+		if err != nil {
+			return err
+		}
+
+		failed := []string{}
+		doc := map[string]interface{}{}
+		for _, host := range hosts {
+			facts := host.Facts
+			if refresh {
+				gathered, err := cache.Refresh(host, sshConfigFiles(rt)...)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", host.Name, err)
+					failed = append(failed, host.Name)
+					continue
+				}
+
+				facts = inventory.MergeFacts(gathered, host.Facts)
+			}
+
+			if facts == nil {
+				facts = map[string]interface{}{}
+			}
+
+			doc[host.Name] = facts
+		}
+
+		if err := yaml.NewEncoder(os.Stdout).Encode(doc); err != nil {
+			return err
+		}
+
+		if len(failed) > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("failed to refresh facts of %s", strings.Join(failed, ", "))
+		}
+
+		return nil
+	},
+}
+
+// loadInventory returns the inventory of the effective configuration,
+// with the cached facts applied by loadConfig, and the facts cache of
+// the project that refreshed facts are stored in.
+func loadInventory(cmd *cobra.Command) (*configs.InventorySection, *inventory.FactsCache, error) {
+	merged, err := loadConfig(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cache, err := inventory.NewFactsCache(merged.Config.File)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inv := &merged.Config.Inventory
+	return inv, cache, nil
+}
+
+func selectHosts(cmd *cobra.Command, selectors []string) ([]configs.InventoryItem, *inventory.FactsCache, error) {
+	inv, cache, err := loadInventory(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hosts, err := matchHosts(inv, selectors)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return hosts, cache, nil
+}
+
+// matchHosts returns the hosts that match the selectors, all hosts
+// when there are none.
+func matchHosts(inv *configs.InventorySection, selectors []string) ([]configs.InventoryItem, error) {
+	if len(selectors) == 0 {
+		return inv.Hosts(), nil
+	}
+
+	return inv.Match(selectors)
+}
+
+// sshConfigFiles returns the ssh_config file of the context, none for
+// ~/.ssh/config and /etc/ssh/ssh_config.
+func sshConfigFiles(rt *ctxs.Runtime) []string {
+	if rt.SshConfig == "" {
+		return nil
+	}
+
+	return []string{rt.SshConfig}
+}
+
+func hostDocument(host configs.InventoryItem) map[string]interface{} {
+	doc := map[string]interface{}{"name": host.Name}
+	if host.Host != "" {
+		doc["host"] = host.Host
+	}
+
+	if host.Port != 0 {
+		doc["port"] = host.Port
+	}
+
+	if host.User != "" {
+		doc["user"] = host.User
+	}
+
+	if host.Key != "" {
+		doc["key"] = host.Key
+	}
+
+	if len(host.Groups) > 0 {
+		doc["groups"] = host.Groups
+	}
+
+	if len(host.Vars) > 0 {
+		doc["vars"] = host.Vars
+	}
+
+	if len(host.Facts) > 0 {
+		doc["facts"] = host.Facts
+	}
+
+	return doc
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func init() {
+	inventoryFactsCmd.Flags().Bool("refresh", false, "Gather the facts from the hosts over ssh")
+	inventoryCmd.AddCommand(inventoryListCmd, inventoryShowCmd, inventoryFactsCmd)
+	rootCmd.AddCommand(inventoryCmd)
+}
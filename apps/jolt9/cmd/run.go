@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/jobs"
+	"github.com/jolt9dev/jolt9/pkg/os/env"
+	"github.com/jolt9dev/jolt9/pkg/secrets"
+	"github.com/spf13/cobra"
+)
+
+var runCmd = &cobra.Command{
+	Use:   "run <job>",
+	Short: "Run a job from the jobs section",
+	Long: `Run a job such as before_deploy from the jobs section of the
+effective configuration and the selected context. Tasks run
+sequentially, tasks with "on" run on the matching inventory hosts
+over ssh.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merged, rt, err := loadRuntime(cmd)
+		if err != nil {
+			return err
+		}
+
+		runner := jobs.NewRunner(&rt.Jobs)
+		runner.Dir = filepath.Dir(merged.Config.File)
+		runner.Parallel, _ = cmd.Flags().GetInt("parallel")
+		runner.FailFast, _ = cmd.Flags().GetBool("fail-fast")
+
+		ctx := rt.ExecContext(env.All())
+		ctx.Context = cmd.Context()
+		if len(rt.Secrets) > 0 {
+			resolver := &secrets.Resolver{Open: rt.Vault, Context: cmd.Context(), Log: os.Stderr}
+			resolved, err := resolver.Resolve(rt.Secrets)
+			if err != nil {
+				return err
+			}
+
+			ctx.Secrets = resolved.Values
+		}
+		result, err := runner.Run(args[0], ctx)
+		if err != nil {
+			return err
+		}
+
+		printJobResult(result)
+		if result.Status != jobs.StatusSuccess {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("job %s finished with status %s", result.Id, result.Status)
+		}
+
+		return nil
+	},
+}
+
+func printJobResult(result *jobs.JobResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "TASK\tSTATUS\tCODE\tDURATION")
+	for _, task := range result.Tasks {
+		code := "-"
+		if task.Code() >= 0 {
+			code = fmt.Sprint(task.Code())
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", task.Name, task.Status, code, task.Duration().Round(time.Millisecond))
+		if task.Err != nil && task.Output == nil {
+			fmt.Fprintf(w, "\t%v\t\t\n", task.Err)
+		}
+
+		for _, host := range task.Hosts {
+			code := "-"
+			if host.Output != nil {
+				code = fmt.Sprint(host.Output.Code)
+			}
+
+			duration := time.Duration(0)
+			if host.Output != nil {
+				duration = host.Output.EndedAt.Sub(host.Output.StartedAt)
+			}
+
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", host.Host, host.Status, code, duration.Round(time.Millisecond))
+		}
+	}
+
+	w.Flush()
+}
+
+func init() {
+	runCmd.Flags().Int("parallel", jobs.DefaultParallel, "Number of inventory hosts a task runs on at once")
+	runCmd.Flags().Bool("fail-fast", false, "Cancel the remaining hosts once a host failed")
+	rootCmd.AddCommand(runCmd)
+}
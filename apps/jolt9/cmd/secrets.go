@@ -0,0 +1,506 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/jobs"
+	"github.com/jolt9dev/jolt9/pkg/os/env"
+	"github.com/jolt9dev/jolt9/pkg/secrets"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage the secrets in the vaults of the current context",
+	Long: `Manage the secrets in the vaults of the current context. Without
+--vault the commands read through every vault of the context and write
+to the default vault, keys may be qualified with the name of a vault
+such as prod:PG_PASSWORD. Values are masked unless --reveal is given.`,
+}
+
+var secretsGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := contextVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		params := &vaults.GetSecretValueParams{OperationParams: vaultOp(cmd)}
+		if version, _ := cmd.Flags().GetInt("version"); version > 0 {
+			params.Version = strconv.Itoa(version)
+		}
+
+		value, err := vault.GetSecretValue(args[0], params)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(reveal(cmd, value))
+		return nil
+	},
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set <key> [value]",
+	Short: "Set the value of a secret",
+	Long: `Set the value of a secret. Without a value argument the value is
+read from stdin, or prompted for without echo when stdin is a terminal,
+so that it does not end up in the shell history.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := contextVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		var value string
+		if len(args) == 2 {
+			value = args[1]
+		} else if value, err = readSecretValue(args[0]); err != nil {
+			return err
+		}
+
+		return vault.SetSecretValue(args[0], value, &vaults.SetSecretValueParams{OperationParams: vaultOp(cmd)})
+	},
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List the secrets and the vaults that hold them",
+	Long: `List the secrets and the vaults that hold them. When a secret is in
+several vaults of the context, the first vault resolves it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := contextVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		params := &vaults.ListSecretNamesParams{OperationParams: vaultOp(cmd)}
+		sources := []vaults.SecretSource{}
+		if chain, ok := vault.(*vaults.Chain); ok {
+			if sources, err = chain.ListSecretSources(params); err != nil {
+				return err
+			}
+		} else {
+			names, err := vault.ListSecretNames(params)
+			if err != nil {
+				return err
+			}
+
+			for _, name := range names {
+				sources = append(sources, vaults.SecretSource{Name: name, Vaults: []string{vaultFlag(cmd)}})
+			}
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tVAULTS")
+		for _, s := range sources {
+			fmt.Fprintf(w, "%s\t%s\n", s.Name, strings.Join(s.Vaults, ","))
+		}
+
+		return w.Flush()
+	},
+}
+
+var secretsDeleteCmd = &cobra.Command{
+	Use:     "delete <key>...",
+	Aliases: []string{"rm"},
+	Short:   "Delete secrets",
+	Args:    cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := contextVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		for _, key := range args {
+			if err := vault.DeleteSecret(key, &vaults.DeleteSecretParams{OperationParams: vaultOp(cmd)}); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+var secretsEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the secrets of a vault in $EDITOR",
+	Long: `Edit the secrets of a vault in $VISUAL or $EDITOR. The secrets are
+decrypted to a yaml file in a private temp directory that is removed
+when the editor exits. Changed and added secrets are written back,
+removed secrets are deleted. Without --vault the default vault of the
+context is edited.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, name, err := primaryVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		names, err := vault.ListSecretNames(&vaults.ListSecretNamesParams{OperationParams: vaultOp(cmd)})
+		if err != nil {
+			return err
+		}
+
+		values, err := vault.BatchGetSecretValues(names, &vaults.GetSecretValueParams{OperationParams: vaultOp(cmd)})
+		if err != nil {
+			return err
+		}
+
+		edited, err := secrets.Edit(name, values, "")
+		if err != nil {
+			return err
+		}
+
+		changes := secrets.Diff(values, edited)
+		if changes.Empty() {
+			fmt.Fprintln(os.Stderr, "no changes")
+			return nil
+		}
+
+		if err := vault.BatchSetSecretValues(changes.Set, &vaults.SetSecretValueParams{OperationParams: vaultOp(cmd)}); err != nil {
+			return err
+		}
+
+		for _, key := range changes.Deleted {
+			if err := vault.DeleteSecret(key, &vaults.DeleteSecretParams{OperationParams: vaultOp(cmd)}); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(os.Stderr, "set %d and deleted %d secrets in vault %s\n", len(changes.Set), len(changes.Deleted), name)
+		return nil
+	},
+}
+
+var secretsImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Short: "Import the secrets of a dotenv file",
+	Long: `Import the secrets of a dotenv file into the vault of --vault or
+the default vault of the context.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, name, err := primaryVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		values, err := godotenv.Read(args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := vault.BatchSetSecretValues(values, &vaults.SetSecretValueParams{OperationParams: vaultOp(cmd)}); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "imported %d secrets into vault %s\n", len(values), name)
+		return nil
+	},
+}
+
+var secretsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the secrets as dotenv, json or shell",
+	Long: `Export the secrets as dotenv, json or shell exports. Values are
+masked unless --reveal is given or the secrets are written to a file
+with --output, which is created readable only by its owner.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		if _, err := secrets.Format(nil, format); err != nil {
+			return fmt.Errorf("--%w", err)
+		}
+
+		vault, err := contextVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		names, err := vault.ListSecretNames(&vaults.ListSecretNamesParams{OperationParams: vaultOp(cmd)})
+		if err != nil {
+			return err
+		}
+
+		values, err := vault.BatchGetSecretValues(names, &vaults.GetSecretValueParams{OperationParams: vaultOp(cmd)})
+		if err != nil {
+			return err
+		}
+
+		if ok, _ := cmd.Flags().GetBool("reveal"); !ok && output == "" {
+			values = secrets.Mask(values)
+		}
+
+		data, err := secrets.Format(values, format)
+		if err != nil {
+			return err
+		}
+
+		if output != "" {
+			return os.WriteFile(output, data, 0600)
+		}
+
+		_, err = os.Stdout.Write(data)
+		return err
+	},
+}
+
+var secretsVersionsCmd = &cobra.Command{
+	Use:   "versions <key>",
+	Short: "List the kept versions of a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := contextVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		vv, ok := vaults.Unwrap(vault).(vaults.VersionedVault)
+		if !ok {
+			return fmt.Errorf("vault %s does not keep versions of secrets", vaultFlag(cmd))
+		}
+
+		versions, err := vv.ListSecretVersions(args[0], &vaults.ListSecretVersionsParams{OperationParams: vaultOp(cmd)})
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "\tVERSION\tCREATED\tSTATUS")
+		for i, v := range versions {
+			marker, status := "", "-"
+			if i == len(versions)-1 && !v.Deleted {
+				marker = "*"
+			}
+
+			if v.Deleted {
+				status = "deleted"
+			}
+
+			created := "-"
+			if !v.Created.IsZero() {
+				created = v.Created.Local().Format(time.RFC3339)
+			}
+
+			fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", marker, v.Version, created, status)
+		}
+
+		return w.Flush()
+	},
+}
+
+var secretsRollbackCmd = &cobra.Command{
+	Use:   "rollback <key>",
+	Short: "Restore a prior version of a secret",
+	Long: `Restore a prior version of a secret as its current value. The
+rollback is recorded as a new version, so it can be undone with
+another rollback. Deleted secrets can be restored the same way.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, _ := cmd.Flags().GetInt("version")
+		if version < 1 {
+			return fmt.Errorf("--version is required and must be 1 or greater")
+		}
+
+		vault, err := contextVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		params := &vaults.SetSecretValueParams{OperationParams: vaultOp(cmd)}
+		if err := vaults.Rollback(vault, args[0], strconv.Itoa(version), params); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "restored version %d of secret %s in vault %s\n", version, args[0], vaultFlag(cmd))
+		return nil
+	},
+}
+
+var secretsRotateCmd = &cobra.Command{
+	Use:   "rotate <secret>",
+	Short: "Replace a secret with a newly generated value",
+	Long: `Replace a secret of the context with a value generated from its
+length and character rules, then run the jobs listed in its "rotate"
+field, e.g. to restart the services that use it. The jobs see the new
+value in secrets. When a job fails the previous value is written back
+to the vault.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		merged, rt, err := loadRuntime(cmd)
+		if err != nil {
+			return err
+		}
+
+		item, err := contextSecret(rt.Secrets, args[0])
+		if err != nil {
+			return err
+		}
+
+		runner := jobs.NewRunner(&rt.Jobs)
+		runner.Dir = filepath.Dir(merged.Config.File)
+		runner.Parallel, _ = cmd.Flags().GetInt("parallel")
+		for _, id := range item.Rotate {
+			if err := runner.Validate(id); err != nil {
+				return fmt.Errorf("secret %s: %w", item.Name, err)
+			}
+		}
+
+		// the jobs get the other secrets of the context as well.
+		resolver := &secrets.Resolver{Open: rt.Vault, Context: cmd.Context(), Log: os.Stderr}
+		resolved, err := resolver.Resolve(rt.Secrets)
+		if err != nil {
+			return err
+		}
+
+		rotator := &secrets.Rotator{
+			Open:    rt.Vault,
+			Context: cmd.Context(),
+			Log:     os.Stderr,
+			Hook: func(item configs.SecretItem, value string) error {
+				ctx := rt.ExecContext(env.All())
+				ctx.Context = cmd.Context()
+				ctx.Secrets = resolved.Values
+				ctx.Secrets[item.Name] = value
+				for _, id := range item.Rotate {
+					result, err := runner.Run(id, ctx)
+					if err != nil {
+						return err
+					}
+
+					printJobResult(result)
+					if result.Status != jobs.StatusSuccess {
+						return fmt.Errorf("job %s finished with status %s", result.Id, result.Status)
+					}
+				}
+
+				return nil
+			},
+		}
+
+		cmd.SilenceUsage = true
+		_, err = rotator.Rotate(item)
+		return err
+	},
+}
+
+// contextSecret returns the secret of the context by its name or its
+// vault key.
+func contextSecret(items []configs.SecretItem, name string) (configs.SecretItem, error) {
+	for _, item := range items {
+		if item.Name == name || item.VaultKey() == name {
+			return item, nil
+		}
+	}
+
+	names := make([]string, 0, len(items))
+	for _, item := range items {
+		names = append(names, item.Name)
+	}
+
+	return configs.SecretItem{}, fmt.Errorf("secret %s is not a secret of the context, available secrets: %s", name, strings.Join(names, ", "))
+}
+
+// contextVault returns the vault named by --vault in the current
+// context.
+func contextVault(cmd *cobra.Command) (vaults.SecretVault, error) {
+	_, rt, err := loadRuntime(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	name, _ := cmd.Flags().GetString("vault")
+	return rt.Vault(name)
+}
+
+// primaryVault returns the vault named by --vault or the vault that
+// the chain of the context writes to.
+func primaryVault(cmd *cobra.Command) (vaults.SecretVault, string, error) {
+	_, rt, err := loadRuntime(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name, _ := cmd.Flags().GetString("vault")
+	if name == "" && rt.Chain != nil {
+		name = rt.Chain.Primary()
+	}
+
+	vault, err := rt.Vault(name)
+	return vault, name, err
+}
+
+func vaultOp(cmd *cobra.Command) vaults.OperationParams {
+	return vaults.OperationParams{Context: cmd.Context()}
+}
+
+func reveal(cmd *cobra.Command, value string) string {
+	if ok, _ := cmd.Flags().GetBool("reveal"); ok {
+		return value
+	}
+
+	return secrets.Masked
+}
+
+// readSecretValue prompts for a value without echo when stdin is a
+// terminal and otherwise reads stdin without the trailing newline.
+func readSecretValue(key string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "value of %s: ", key)
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(data), err
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// vaultFlag returns the --vault flag, an empty flag is shown as the
+// default vault.
+func vaultFlag(cmd *cobra.Command) string {
+	name, _ := cmd.Flags().GetString("vault")
+	if name == "" {
+		return "default"
+	}
+
+	return name
+}
+
+func init() {
+	secretsCmd.PersistentFlags().StringP("vault", "V", "", "Vault of the context, defaults to the default vault")
+	secretsRollbackCmd.Flags().Int("version", 0, "Version to restore, see jolt9 secrets versions")
+	secretsRotateCmd.Flags().Int("parallel", jobs.DefaultParallel, "Number of inventory hosts a job task runs on at once")
+	secretsGetCmd.Flags().Bool("reveal", false, "Print the value instead of a mask")
+	secretsGetCmd.Flags().Int("version", 0, "Version to read, see jolt9 secrets versions")
+	secretsExportCmd.Flags().Bool("reveal", false, "Write the values instead of masks")
+	secretsExportCmd.Flags().StringP("format", "f", "dotenv", "Format of the export: dotenv, json or shell")
+	secretsExportCmd.Flags().StringP("output", "o", "", "File to write the secrets to")
+	secretsCmd.AddCommand(secretsGetCmd, secretsSetCmd, secretsListCmd, secretsDeleteCmd,
+		secretsEditCmd, secretsImportCmd, secretsExportCmd,
+		secretsVersionsCmd, secretsRollbackCmd, secretsRotateCmd)
+	rootCmd.AddCommand(secretsCmd)
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jolt9dev/jolt9/pkg/os/env"
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var sshCmd = &cobra.Command{
+	Use:   "ssh",
+	Short: "Manage the ssh keys of the inventory hosts",
+}
+
+var sshKeygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate an ssh key pair",
+	Long: `Generate an ssh key pair, ed25519 by default. With --secret the
+private key is stored in a vault of the current context under the
+secret key and the public key under <secret>_PUB, so that the key of a
+deploy user does not have to live on disk. With --file the keys are
+written to the file and <file>.pub. Without either, the keys are
+written to ~/.ssh/id_<type> like ssh-keygen does.
+
+The public key is printed and its fingerprint matches ssh-keygen -l.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		typ, _ := cmd.Flags().GetString("type")
+		bits, _ := cmd.Flags().GetInt("bits")
+		comment, _ := cmd.Flags().GetString("comment")
+		file, _ := cmd.Flags().GetString("file")
+		secret, _ := cmd.Flags().GetString("secret")
+		force, _ := cmd.Flags().GetBool("force")
+		prompt, _ := cmd.Flags().GetBool("passphrase")
+
+		if file == "" && secret == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+
+			file = filepath.Join(home, ".ssh", "id_"+typ)
+		}
+
+		if file != "" && !force {
+			if _, err := os.Stat(file); err == nil {
+				return fmt.Errorf("key file %s already exists, use --force to replace it", file)
+			}
+		}
+
+		var vault vaults.SecretVault
+		var vaultName string
+		if secret != "" {
+			var err error
+			if vault, vaultName, err = primaryVault(cmd); err != nil {
+				return err
+			}
+
+			_, err = vault.GetSecretValue(secret, &vaults.GetSecretValueParams{OperationParams: vaultOp(cmd)})
+			if err == nil && !force {
+				return fmt.Errorf("secret %s already exists, use --force to replace it", secret)
+			}
+
+			if err != nil && !errors.Is(err, vaults.ErrSecretNotFound) {
+				return err
+			}
+		}
+
+		opts := &ssh.KeyOptions{Type: ssh.KeyType(typ), Bits: bits, Comment: comment}
+		if prompt {
+			passphrase, err := readPassphrase()
+			if err != nil {
+				return err
+			}
+
+			opts.Passphrase = passphrase
+		}
+
+		kp, err := ssh.NewKeyPair(opts)
+		if err != nil {
+			return err
+		}
+
+		if file != "" {
+			if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+				return err
+			}
+
+			if err := kp.WriteToFile(file, file+".pub"); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "wrote key to %s and %s.pub\n", file, file)
+		}
+
+		if vault != nil {
+			values := map[string]string{secret: string(kp.PrivateKey), secret + "_PUB": string(kp.PublicKey)}
+			if err := vault.BatchSetSecretValues(values, &vaults.SetSecretValueParams{OperationParams: vaultOp(cmd)}); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "stored key in vault %s as %s and %s_PUB\n", vaultName, secret, secret)
+		}
+
+		fmt.Fprintln(os.Stderr, kp.String())
+		fmt.Print(string(kp.PublicKey))
+		return nil
+	},
+}
+
+// readPassphrase prompts twice for the passphrase of a new key.
+func readPassphrase() ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("--passphrase needs a terminal to prompt for the passphrase")
+	}
+
+	fmt.Fprint(os.Stderr, "Enter passphrase: ")
+	first, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprint(os.Stderr, "Enter same passphrase again: ")
+	second, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(first) != string(second) {
+		return nil, errors.New("passphrases do not match")
+	}
+
+	return first, nil
+}
+
+func defaultKeyComment() string {
+	host, _ := os.Hostname()
+	user := env.Get("USER")
+	if user == "" || host == "" {
+		return user + host
+	}
+
+	return user + "@" + host
+}
+
+func init() {
+	sshKeygenCmd.Flags().StringP("type", "t", string(ssh.KeyEd25519), "Type of the key: ed25519, ecdsa or rsa")
+	sshKeygenCmd.Flags().IntP("bits", "b", 0, "Bits of the key, 4096 for rsa and 256 for ecdsa by default")
+	sshKeygenCmd.Flags().StringP("comment", "C", defaultKeyComment(), "Comment of the key")
+	sshKeygenCmd.Flags().StringP("file", "f", "", "File to write the private key to, the public key goes to <file>.pub")
+	sshKeygenCmd.Flags().StringP("secret", "s", "", "Secret key to store the private key under in the vault")
+	sshKeygenCmd.Flags().StringP("vault", "V", "", "Vault of the context for --secret, defaults to the default vault")
+	sshKeygenCmd.Flags().Bool("passphrase", false, "Prompt for a passphrase that encrypts the private key")
+	sshKeygenCmd.Flags().Bool("force", false, "Replace an existing key file or secret")
+	sshCmd.AddCommand(sshKeygenCmd)
+	rootCmd.AddCommand(sshCmd)
+}
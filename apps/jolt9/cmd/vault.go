@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/jolt9dev/jolt9/pkg/vaults/sops"
+	"github.com/spf13/cobra"
+)
+
+var vaultCmd = &cobra.Command{
+	Use:   "vault",
+	Short: "Manage the keys of the project vaults",
+}
+
+var vaultInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Generate an age key for sops and age vaults",
+	Long: `Generate an age key and add it to the sops key file, by default
+$SOPS_AGE_KEY_FILE or sops/age/keys.txt in the user config dir. The
+public key is printed so that it can be added to the recipients of a
+vault. When the key file already holds a key, --force adds another one.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("key-file")
+		force, _ := cmd.Flags().GetBool("force")
+		if file == "" {
+			def, err := sops.DefaultAgeKeyFile()
+			if err != nil {
+				return err
+			}
+
+			file = def
+		}
+
+		if data, err := os.ReadFile(file); err == nil {
+			existing, err := sops.RecipientsFromIdentities(string(data))
+			if err != nil {
+				return fmt.Errorf("invalid key file %s: %w", file, err)
+			}
+
+			if len(existing) > 0 && !force {
+				return fmt.Errorf("key file %s already holds the key %s, use --force to add another key", file, existing[0])
+			}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		identity, recipient, err := sops.GenerateAgeKey()
+		if err != nil {
+			return err
+		}
+
+		if err := sops.AppendAgeKey(file, identity); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "added age key to %s\n", file)
+		fmt.Println(recipient)
+		return nil
+	},
+}
+
+var vaultRecipientsCmd = &cobra.Command{
+	Use:   "recipients",
+	Short: "List the recipients of a vault",
+	Long: `List the recipients of a vault of the current context. Adding or
+removing recipients re-encrypts every secret of the vault, which needs
+an identity that can decrypt them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := recipientVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		recipients, err := vault.Recipients()
+		if err != nil {
+			return err
+		}
+
+		for _, r := range recipients {
+			fmt.Println(r)
+		}
+
+		return nil
+	},
+}
+
+var vaultRecipientsAddCmd = &cobra.Command{
+	Use:   "add <recipient>...",
+	Short: "Add recipients to a vault and re-encrypt its secrets",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := recipientVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		return vault.AddRecipients(args...)
+	},
+}
+
+var vaultRecipientsRemoveCmd = &cobra.Command{
+	Use:   "remove <recipient>...",
+	Short: "Remove recipients from a vault and re-encrypt its secrets",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		vault, err := recipientVault(cmd)
+		if err != nil {
+			return err
+		}
+
+		return vault.RemoveRecipients(args...)
+	},
+}
+
+// recipientVault returns the vault named by --vault in the current
+// context, it must manage its own recipients like the age driver.
+func recipientVault(cmd *cobra.Command) (vaults.RecipientVault, error) {
+	name, _ := cmd.Flags().GetString("vault")
+	_, rt, err := loadRuntime(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	vault, err := rt.Vault(name)
+	if err != nil {
+		return nil, err
+	}
+
+	rv, ok := vaults.Unwrap(vault).(vaults.RecipientVault)
+	if !ok {
+		if name == "" {
+			name = "default"
+		}
+
+		return nil, fmt.Errorf("vault %s does not manage recipients, for sops vaults edit .sops.yaml or the age recipients of the vault", name)
+	}
+
+	return rv, nil
+}
+
+func init() {
+	vaultRecipientsCmd.PersistentFlags().StringP("vault", "V", "", "Vault of the context, defaults to the default vault")
+	vaultRecipientsCmd.AddCommand(vaultRecipientsAddCmd, vaultRecipientsRemoveCmd)
+
+	vaultInitCmd.Flags().String("key-file", "", "File to add the key to, defaults to the sops key file")
+	vaultInitCmd.Flags().Bool("force", false, "Add a key even when the key file already holds one")
+	vaultCmd.AddCommand(vaultInitCmd, vaultRecipientsCmd)
+	rootCmd.AddCommand(vaultCmd)
+}
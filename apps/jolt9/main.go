@@ -1,7 +1,3 @@
-/*
-Copyright © 2024 NAME HERE <EMAIL ADDRESS>
-
-*/
 package main
 
 import "github.com/jolt9dev/jolt9/apps/jolt9/cmd"
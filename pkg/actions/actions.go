@@ -0,0 +1,241 @@
+// Package actions contains the reusable actions that tasks
+// reference with `use` and configure with `with`.
+package actions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+)
+
+const (
+	InputString = "string"
+	InputInt    = "int"
+	InputBool   = "bool"
+)
+
+// Input declares a value an action accepts through `with`.
+type Input struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Default     string
+}
+
+// Context is passed to an action when it runs. With holds the
+// evaluated `with` values with defaults applied.
+type Context struct {
+	Context context.Context
+	Env     map[string]string
+	With    map[string]string
+	Dir     string
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+func (c *Context) String(name string) string {
+	return c.With[name]
+}
+
+func (c *Context) Int(name string) (int, error) {
+	v := strings.TrimSpace(c.With[name])
+	if v == "" {
+		return 0, nil
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("input %q expects an int, got %q", name, v)
+	}
+
+	return i, nil
+}
+
+func (c *Context) Bool(name string) (bool, error) {
+	v := strings.TrimSpace(c.With[name])
+	if v == "" {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("input %q expects a bool, got %q", name, v)
+	}
+
+	return b, nil
+}
+
+type Action interface {
+	Name() string
+
+	Inputs() []Input
+
+	Run(ctx *Context) (*exec.PsOutput, error)
+}
+
+type ActionRegistry struct {
+	data map[string]Action
+	mux  sync.RWMutex
+}
+
+var Registry = NewRegistry()
+
+func NewRegistry() *ActionRegistry {
+	return &ActionRegistry{data: make(map[string]Action)}
+}
+
+func (r *ActionRegistry) Register(action Action) {
+	r.Set(action.Name(), action)
+}
+
+func (r *ActionRegistry) Set(name string, action Action) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.data[name] = action
+}
+
+func (r *ActionRegistry) Get(name string) (Action, bool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	action, ok := r.data[name]
+	return action, ok
+}
+
+func (r *ActionRegistry) Has(name string) bool {
+	_, ok := r.Get(name)
+	return ok
+}
+
+func (r *ActionRegistry) Names() []string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	names := make([]string, 0, len(r.data))
+	for name := range r.data {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Resolve finds the action for `use`. Values starting with ./ or ../
+// or absolute paths are loaded as local actions relative to dir.
+func (r *ActionRegistry) Resolve(use string, dir string) (Action, error) {
+	if IsLocal(use) {
+		path := use
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+
+		return LoadLocalAction(path)
+	}
+
+	action, ok := r.Get(use)
+	if !ok {
+		return nil, fmt.Errorf("unknown action %q, available actions: %s", use, strings.Join(r.Names(), ", "))
+	}
+
+	return action, nil
+}
+
+func IsLocal(use string) bool {
+	return strings.HasPrefix(use, "./") || strings.HasPrefix(use, "../") || filepath.IsAbs(use)
+}
+
+func Register(action Action) {
+	Registry.Register(action)
+}
+
+func Resolve(use string, dir string) (Action, error) {
+	return Registry.Resolve(use, dir)
+}
+
+// Validate checks the `with` values against the declared inputs of
+// the action. Unknown and missing required inputs are reported and
+// literal values are type checked. Values that contain expressions
+// are only type checked once they are evaluated.
+func Validate(action Action, with map[string]string, exprs map[string]bool) error {
+	inputs := map[string]Input{}
+	for _, input := range action.Inputs() {
+		inputs[input.Name] = input
+	}
+
+	errs := []string{}
+	keys := make([]string, 0, len(with))
+	for k := range with {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		input, ok := inputs[k]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("unknown input %q", k))
+			continue
+		}
+
+		if exprs[k] {
+			continue
+		}
+
+		if err := checkType(input, with[k]); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	for _, input := range action.Inputs() {
+		if _, ok := with[input.Name]; !ok && input.Required && input.Default == "" {
+			errs = append(errs, fmt.Sprintf("missing required input %q", input.Name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("action %s: %s", action.Name(), strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+// WithDefaults returns a copy of with that includes the default
+// values for inputs that were not set.
+func WithDefaults(action Action, with map[string]string) map[string]string {
+	values := map[string]string{}
+	for _, input := range action.Inputs() {
+		if input.Default != "" {
+			values[input.Name] = input.Default
+		}
+	}
+
+	for k, v := range with {
+		values[k] = v
+	}
+
+	return values
+}
+
+func checkType(input Input, value string) error {
+	value = strings.TrimSpace(value)
+	switch input.Type {
+	case InputInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("input %q expects an int, got %q", input.Name, value)
+		}
+	case InputBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("input %q expects a bool, got %q", input.Name, value)
+		}
+	case InputString, "":
+	default:
+		return fmt.Errorf("input %q has an unknown type %q", input.Name, input.Type)
+	}
+
+	return nil
+}
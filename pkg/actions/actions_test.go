@@ -0,0 +1,126 @@
+package actions_test
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/actions"
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	action, err := actions.Resolve("jolt9/wait-for-port", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, actions.Validate(action, map[string]string{"port": "80"}, nil))
+	assert.Nil(t, actions.Validate(action, map[string]string{"port": "${{ vars.port }}"}, map[string]bool{"port": true}))
+
+	err = actions.Validate(action, map[string]string{"port": "http", "nope": "1"}, nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `input "port" expects an int`)
+	assert.Contains(t, err.Error(), `unknown input "nope"`)
+
+	err = actions.Validate(action, map[string]string{}, nil)
+	assert.Contains(t, err.Error(), `missing required input "port"`)
+
+	_, err = actions.Resolve("jolt9/nope", "")
+	assert.Contains(t, err.Error(), "jolt9/shell")
+}
+
+func TestWaitForPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	action, _ := actions.Registry.Get("jolt9/wait-for-port")
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	out, err := action.Run(&actions.Context{
+		Context: context.Background(),
+		With:    actions.WithDefaults(action, map[string]string{"host": "127.0.0.1", "port": port}),
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, out.Code)
+}
+
+func TestCopyFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "src.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	action, _ := actions.Registry.Get("jolt9/copy-file")
+	_, err := action.Run(&actions.Context{
+		Context: context.Background(),
+		Dir:     dir,
+		With:    map[string]string{"src": "src.txt", "dest": "out/dest.txt", "mode": "0600"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "out", "dest.txt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", string(data))
+}
+
+func TestLocalAction(t *testing.T) {
+	if _, ok := exec.Which("sh"); !ok {
+		t.Skip("sh not found")
+	}
+
+	dir := t.TempDir()
+	actionDir := filepath.Join(dir, "actions", "greet")
+	if err := os.MkdirAll(actionDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := os.WriteFile(filepath.Join(actionDir, "action.yaml"), []byte(`
+name: greet
+inputs:
+  name:
+    type: string
+    required: true
+  times:
+    type: int
+    default: "1"
+shell: sh
+run: echo "hello ${{ inputs.name }} $INPUT_TIMES"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	action, err := actions.Resolve("./actions/greet", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "greet", action.Name())
+	assert.NotNil(t, actions.Validate(action, map[string]string{"times": "x"}, nil))
+
+	stdout := &bytes.Buffer{}
+	out, err := action.Run(&actions.Context{
+		Context: context.Background(),
+		Env:     map[string]string{"PATH": os.Getenv("PATH")},
+		With:    actions.WithDefaults(action, map[string]string{"name": "jolt9"}),
+		Stdout:  stdout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "hello jolt9 1", strings.TrimSpace(string(out.Stdout)))
+	assert.Equal(t, "hello jolt9 1", strings.TrimSpace(stdout.String()))
+}
@@ -0,0 +1,352 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+)
+
+// builtin is an action implemented by a go function.
+type builtin struct {
+	name   string
+	inputs []Input
+	run    func(ctx *Context) (*exec.PsOutput, error)
+}
+
+func (b *builtin) Name() string {
+	return b.name
+}
+
+func (b *builtin) Inputs() []Input {
+	return b.inputs
+}
+
+func (b *builtin) Run(ctx *Context) (*exec.PsOutput, error) {
+	return b.run(ctx)
+}
+
+func init() {
+	Register(&builtin{
+		name: "jolt9/shell",
+		inputs: []Input{
+			{Name: "script", Type: InputString, Required: true, Description: "The script to run"},
+			{Name: "shell", Type: InputString, Description: "The shell to run the script with"},
+		},
+		run: runShell,
+	})
+
+	Register(&builtin{
+		name: "jolt9/ssh-run",
+		inputs: []Input{
+			{Name: "host", Type: InputString, Required: true, Description: "The host to connect to"},
+			{Name: "port", Type: InputInt, Default: "22", Description: "The ssh port"},
+			{Name: "user", Type: InputString, Required: true, Description: "The user to connect as"},
+			{Name: "key", Type: InputString, Description: "The path to a private key"},
+			{Name: "password", Type: InputString, Description: "The password of the user"},
+			{Name: "command", Type: InputString, Required: true, Description: "The command to run"},
+		},
+		run: runSsh,
+	})
+
+	Register(&builtin{
+		name: "jolt9/copy-file",
+		inputs: []Input{
+			{Name: "src", Type: InputString, Required: true, Description: "The file to copy"},
+			{Name: "dest", Type: InputString, Required: true, Description: "The destination path"},
+			{Name: "mode", Type: InputString, Description: "The octal file mode of the destination, e.g. 0644"},
+		},
+		run: copyFile,
+	})
+
+	Register(&builtin{
+		name: "jolt9/docker-compose-up",
+		inputs: []Input{
+			{Name: "file", Type: InputString, Default: "compose.yaml", Description: "The compose file"},
+			{Name: "project", Type: InputString, Description: "The compose project name"},
+			{Name: "services", Type: InputString, Description: "Space separated services to start"},
+			{Name: "detach", Type: InputBool, Default: "true", Description: "Run the containers in the background"},
+			{Name: "pull", Type: InputString, Description: "Pull policy: always, missing or never"},
+		},
+		run: composeUp,
+	})
+
+	Register(&builtin{
+		name: "jolt9/wait-for-port",
+		inputs: []Input{
+			{Name: "host", Type: InputString, Default: "localhost", Description: "The host to connect to"},
+			{Name: "port", Type: InputInt, Required: true, Description: "The tcp port"},
+			{Name: "timeout", Type: InputInt, Default: "60", Description: "Seconds to wait for the port"},
+		},
+		run: waitForPort,
+	})
+}
+
+func runShell(ctx *Context) (*exec.PsOutput, error) {
+	return RunScript(ctx.Context, ScriptParams{
+		Shell:  ctx.String("shell"),
+		Script: ctx.String("script"),
+		Env:    ctx.Env,
+		Dir:    ctx.Dir,
+		Stdout: ctx.Stdout,
+		Stderr: ctx.Stderr,
+	})
+}
+
+// sshConnectTimeout bounds connecting to the host of jolt9/ssh-run.
+const sshConnectTimeout = 30 * time.Second
+
+func runSsh(ctx *Context) (*exec.PsOutput, error) {
+	port, err := ctx.Int("port")
+	if err != nil {
+		return nil, err
+	}
+
+	auth := &ssh.Auth{}
+	if key := ctx.String("key"); key != "" {
+		auth.Keys = append(auth.Keys, key)
+	}
+
+	if password := ctx.String("password"); password != "" {
+		auth.Passwords = append(auth.Passwords, password)
+	}
+
+	out := &exec.PsOutput{
+		FileName:  "ssh",
+		Args:      []string{ctx.String("host"), ctx.String("command")},
+		StartedAt: time.Now().UTC(),
+	}
+
+	client, err := ssh.NewClient(&ssh.Config{
+		User:    ctx.String("user"),
+		Host:    ctx.String("host"),
+		Port:    port,
+		Auth:    auth,
+		Timeout: sshConnectTimeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// the command runs on a persistent connection, closing it ends a
+	// command that is still running when the task is cancelled or
+	// times out.
+	type result struct {
+		text string
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		defer client.StopPersistentConn()
+		if err := client.StartPersistentConn(sshConnectTimeout); err != nil {
+			done <- result{err: err}
+			return
+		}
+
+		if err := ctx.Context.Err(); err != nil {
+			done <- result{err: err}
+			return
+		}
+
+		text, err := client.Output(ctx.String("command"))
+		done <- result{text: text, err: err}
+	}()
+
+	var text string
+	select {
+	case res := <-done:
+		text, err = res.text, res.err
+	case <-ctx.Context.Done():
+		// closing the connection ends Output, the worker is waited for
+		// so that it does not use the client after runSsh returns.
+		client.StopPersistentConn()
+		<-done
+		err = ctx.Context.Err()
+	}
+
+	out.EndedAt = time.Now().UTC()
+	out.Stdout = []byte(text)
+	if ctx.Stdout != nil && text != "" {
+		fmt.Fprintln(ctx.Stdout, text)
+	}
+
+	if err != nil {
+		out.Code = 1
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			out.Code = exitErr.ExitCode
+		}
+	}
+
+	return out, err
+}
+
+func copyFile(ctx *Context) (*exec.PsOutput, error) {
+	out := &exec.PsOutput{
+		FileName:  "copy",
+		Args:      []string{ctx.String("src"), ctx.String("dest")},
+		StartedAt: time.Now().UTC(),
+	}
+
+	err := func() error {
+		src := resolvePath(ctx.Dir, ctx.String("src"))
+		dest := resolvePath(ctx.Dir, ctx.String("dest"))
+
+		fi, err := os.Stat(src)
+		if err != nil {
+			return err
+		}
+
+		mode := fi.Mode().Perm()
+		if m := ctx.String("mode"); m != "" {
+			parsed, err := strconv.ParseUint(m, 8, 32)
+			if err != nil {
+				return fmt.Errorf("input \"mode\" expects an octal mode, got %q", m)
+			}
+
+			mode = os.FileMode(parsed)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+
+		r, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+
+		w, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(w, r); err != nil {
+			w.Close()
+			return err
+		}
+
+		if err := w.Close(); err != nil {
+			return err
+		}
+
+		return os.Chmod(dest, mode)
+	}()
+
+	out.EndedAt = time.Now().UTC()
+	if err != nil {
+		out.Code = 1
+	}
+
+	return out, err
+}
+
+func composeUp(ctx *Context) (*exec.PsOutput, error) {
+	args := []string{"compose", "-f", ctx.String("file")}
+	if project := ctx.String("project"); project != "" {
+		args = append(args, "-p", project)
+	}
+
+	args = append(args, "up")
+	detach, err := ctx.Bool("detach")
+	if err != nil {
+		return nil, err
+	}
+
+	if detach {
+		args = append(args, "-d")
+	}
+
+	if pull := ctx.String("pull"); pull != "" {
+		args = append(args, "--pull", pull)
+	}
+
+	args = append(args, strings.Fields(ctx.String("services"))...)
+
+	docker, err := exec.Find("docker", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	script := make([]string, 0, len(args)+1)
+	script = append(script, docker)
+	script = append(script, args...)
+	cmd := exec.NewContext(ctx.Context, script[0], script[1:]...)
+	if ctx.Env != nil {
+		cmd.WithEnvMap(ctx.Env)
+	}
+
+	if ctx.Dir != "" {
+		cmd.WithCwd(ctx.Dir)
+	}
+
+	cmd.WithStdout(ctx.Stdout)
+	cmd.WithStderr(ctx.Stderr)
+
+	out := &exec.PsOutput{FileName: docker, Args: script, StartedAt: time.Now().UTC()}
+	err = cmd.Start()
+	if err == nil {
+		err = cmd.Wait()
+	}
+
+	out.EndedAt = time.Now().UTC()
+	out.Code = exitCode(cmd, err)
+	return out, err
+}
+
+func waitForPort(ctx *Context) (*exec.PsOutput, error) {
+	port, err := ctx.Int("port")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := ctx.Int("timeout")
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.JoinHostPort(ctx.String("host"), strconv.Itoa(port))
+	out := &exec.PsOutput{FileName: "wait-for-port", Args: []string{addr}, StartedAt: time.Now().UTC()}
+	deadline := out.StartedAt.Add(time.Duration(timeout) * time.Second)
+
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			out.EndedAt = time.Now().UTC()
+			return out, nil
+		}
+
+		if time.Now().UTC().After(deadline) {
+			out.EndedAt = time.Now().UTC()
+			out.Code = 1
+			return out, fmt.Errorf("timed out waiting for %s after %ds", addr, timeout)
+		}
+
+		select {
+		case <-ctx.Context.Done():
+			out.EndedAt = time.Now().UTC()
+			out.Code = 1
+			return out, ctx.Context.Err()
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func resolvePath(dir, path string) string {
+	if dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,141 @@
+package actions
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jolt9dev/jolt9/pkg/exprs"
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+	"gopkg.in/yaml.v3"
+)
+
+var ActionFileNames = []string{"action.yaml", "action.yml"}
+
+// LocalAction is an action defined as a yaml file in the repo.
+//
+//	name: greet
+//	inputs:
+//	  name:
+//	    type: string
+//	    required: true
+//	run: echo "hello ${{ inputs.name }}"
+//
+// Inputs are available to `run` as `inputs.<name>` and to the
+// script as INPUT_<NAME> environment variables. The directory of
+// the action file is available as ACTION_PATH.
+type LocalAction struct {
+	ActionName  string           `yaml:"name"`
+	Description string           `yaml:"description"`
+	InputsMap   map[string]Input `yaml:"inputs"`
+	Env         map[string]string
+	Shell       string
+	Script      string `yaml:"run"`
+
+	// File is the path the action was loaded from.
+	File string `yaml:"-"`
+}
+
+// LoadLocalAction reads an action from path. When path is a
+// directory, action.yaml or action.yml is read from it.
+func LoadLocalAction(path string) (*LocalAction, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		found := ""
+		for _, name := range ActionFileNames {
+			file := filepath.Join(path, name)
+			if _, err := os.Stat(file); err == nil {
+				found = file
+				break
+			}
+		}
+
+		if found == "" {
+			return nil, fmt.Errorf("no action.yaml found in %s", path)
+		}
+
+		path = found
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	action := &LocalAction{}
+	if err := yaml.Unmarshal(data, action); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if strings.TrimSpace(action.Script) == "" {
+		return nil, fmt.Errorf("%s: action must define run", path)
+	}
+
+	if action.ActionName == "" {
+		action.ActionName = filepath.Base(filepath.Dir(path))
+	}
+
+	action.File = path
+	return action, nil
+}
+
+func (a *LocalAction) Name() string {
+	return a.ActionName
+}
+
+func (a *LocalAction) Inputs() []Input {
+	inputs := make([]Input, 0, len(a.InputsMap))
+	for name, input := range a.InputsMap {
+		input.Name = name
+		inputs = append(inputs, input)
+	}
+
+	sort.Slice(inputs, func(i, j int) bool {
+		return inputs[i].Name < inputs[j].Name
+	})
+
+	return inputs
+}
+
+func (a *LocalAction) Run(ctx *Context) (*exec.PsOutput, error) {
+	env := map[string]string{}
+	for k, v := range ctx.Env {
+		env[k] = v
+	}
+
+	for k, v := range a.Env {
+		env[k] = v
+	}
+
+	env["ACTION_PATH"] = filepath.Dir(a.File)
+
+	inputs := map[string]interface{}{}
+	for k, v := range ctx.With {
+		inputs[k] = v
+		name := strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(k))
+		env["INPUT_"+name] = v
+	}
+
+	script, err := exprs.Interpolate(a.Script, exprs.Scope{
+		"inputs": inputs,
+		"env":    env,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", a.File, err)
+	}
+
+	return RunScript(ctx.Context, ScriptParams{
+		Shell:  a.Shell,
+		Script: exprs.ToString(script),
+		Env:    env,
+		Dir:    ctx.Dir,
+		Stdout: ctx.Stdout,
+		Stderr: ctx.Stderr,
+	})
+}
@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	ose "os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/os"
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+)
+
+const waitDelay = time.Second
+
+// ScriptParams describes a script to run with a local shell.
+type ScriptParams struct {
+	Shell  string
+	Script string
+	Env    map[string]string
+	Dir    string
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// RunScript runs the script with the shell and captures the output
+// while also writing it to Stdout and Stderr. The process is killed
+// when ctx is done.
+func RunScript(ctx context.Context, params ScriptParams) (*exec.PsOutput, error) {
+	args := shellArgs(params.Shell, params.Script)
+	cmd := exec.NewContext(ctx, args[0], args[1:]...)
+	if params.Env != nil {
+		cmd.WithEnvMap(params.Env)
+	}
+
+	if params.Dir != "" {
+		cmd.WithCwd(params.Dir)
+	}
+
+	// children of the shell may keep the output pipes open after
+	// the shell is killed, don't wait on them forever.
+	cmd.Cmd.WaitDelay = waitDelay
+
+	var stdout, stderr bytes.Buffer
+	cmd.WithStdout(tee(params.Stdout, &stdout))
+	cmd.WithStderr(tee(params.Stderr, &stderr))
+
+	out := &exec.PsOutput{
+		FileName:  args[0],
+		Args:      args,
+		StartedAt: time.Now().UTC(),
+	}
+
+	err := cmd.Start()
+	if err == nil {
+		err = cmd.Wait()
+	}
+
+	out.EndedAt = time.Now().UTC()
+	out.Stdout = stdout.Bytes()
+	out.Stderr = stderr.Bytes()
+	out.Code = exitCode(cmd, err)
+	return out, err
+}
+
+func tee(w io.Writer, buf *bytes.Buffer) io.Writer {
+	if w == nil {
+		return buf
+	}
+
+	return io.MultiWriter(w, buf)
+}
+
+func exitCode(cmd *exec.Cmd, err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var exitErr *ose.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode()
+	}
+
+	if cmd.ProcessState != nil && cmd.ProcessState.ExitCode() > 0 {
+		return cmd.ProcessState.ExitCode()
+	}
+
+	return 1
+}
+
+// shellArgs returns the command line used to run script with
+// shell. When shell is empty, bash is used when it is available
+// and sh otherwise. On windows, pwsh or powershell is used.
+func shellArgs(shell string, script string) []string {
+	if shell == "" {
+		shell = defaultShell()
+	}
+
+	name := strings.ToLower(filepath.Base(shell))
+	name = strings.TrimSuffix(name, ".exe")
+
+	switch name {
+	case "bash":
+		return []string{shell, "--noprofile", "--norc", "-eo", "pipefail", "-c", script}
+	case "sh", "zsh", "dash", "ash":
+		return []string{shell, "-e", "-c", script}
+	case "pwsh", "powershell":
+		return []string{shell, "-NoProfile", "-NonInteractive", "-Command", script}
+	case "cmd":
+		return []string{shell, "/D", "/C", script}
+	default:
+		return []string{shell, "-c", script}
+	}
+}
+
+func defaultShell() string {
+	if os.IsWindows() {
+		if _, ok := exec.Which("pwsh"); ok {
+			return "pwsh"
+		}
+
+		return "powershell"
+	}
+
+	if _, ok := exec.Which("bash"); ok {
+		return "bash"
+	}
+
+	return "sh"
+}
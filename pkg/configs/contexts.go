@@ -0,0 +1,180 @@
+package configs
+
+import "gopkg.in/yaml.v3"
+
+// Represents a deployment context such as `default` or `ha`
+// which selects the vaults, envs, dns and servers to use.
+//
+// contexts:
+//
+//	default:
+//	  vaults: [default]
+//	  envs: [default]
+//	  dns: cloudflare
+//	  servers: [node1]
+type ContextItem struct {
+	Name      string
+	Vaults    []string
+	Envs      []string
+	Dns       string
+	SshConfig string
+	Servers   []string
+	Secrets   []SecretItem
+	Jobs      JobsSection
+}
+
+type ContextsSection struct {
+	data map[string]ContextItem
+}
+
+func (c *ContextsSection) Get(name string) (ContextItem, bool) {
+	item, ok := c.data[name]
+	return item, ok
+}
+
+func (c *ContextsSection) Set(name string, item ContextItem) {
+	if c.data == nil {
+		c.data = make(map[string]ContextItem)
+	}
+
+	c.data[name] = item
+}
+
+func (c *ContextsSection) Has(name string) bool {
+	_, ok := c.data[name]
+	return ok
+}
+
+func (c *ContextsSection) Len() int {
+	return len(c.data)
+}
+
+func (c *ContextsSection) Names() []string {
+	names := make([]string, 0, len(c.data))
+	for name := range c.data {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (c *ContextsSection) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
+	}
+
+	c.data = make(map[string]ContextItem)
+
+	for i := 0; i < len(value.Content); i += 2 {
+		key := value.Content[i]
+		val := value.Content[i+1]
+
+		var item ContextItem
+		if err := val.Decode(&item); err != nil {
+			return err
+		}
+
+		item.Name = key.Value
+		c.data[key.Value] = item
+	}
+
+	return nil
+}
+
+func (c *ContextItem) UnmarshalYAML(value *yaml.Node) error {
+	// an empty context inherits everything
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!null" {
+		return nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
+	}
+
+	// keys that are not listed here such as `compose` or `traefik`
+	// are owned by other sections and are ignored.
+	for i := 0; i < len(value.Content); i += 2 {
+		key := value.Content[i]
+		val := value.Content[i+1]
+
+		var err error
+		switch key.Value {
+		case "vaults":
+			c.Vaults, err = decodeNames(val)
+		case "envs":
+			c.Envs, err = decodeNames(val)
+		case "servers":
+			c.Servers, err = decodeNames(val)
+		case "dns":
+			err = decodeScalar(val, &c.Dns)
+		case "ssh_config", "sshConfig":
+			err = decodeScalar(val, &c.SshConfig)
+		case "secrets":
+			err = val.Decode(&c.Secrets)
+		case "jobs":
+			err = val.Decode(&c.Jobs)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodeNames reads a list of names that may be written as a
+// single scalar, a sequence of scalars or a mapping with the
+// `use` or `include` keys.
+func decodeNames(value *yaml.Node) ([]string, error) {
+	switch value.Kind {
+	case yaml.ScalarNode:
+		if value.Tag == "!!null" || value.Value == "" {
+			return []string{}, nil
+		}
+
+		return []string{value.Value}, nil
+	case yaml.SequenceNode:
+		names := make([]string, 0, len(value.Content))
+		for _, item := range value.Content {
+			if item.Kind != yaml.ScalarNode {
+				return nil, nodeError(item, "expected a scalar node, got %v", kindName(item.Kind))
+			}
+
+			names = append(names, item.Value)
+		}
+
+		return names, nil
+	case yaml.MappingNode:
+		names := []string{}
+		for i := 0; i < len(value.Content); i += 2 {
+			key := value.Content[i]
+			val := value.Content[i+1]
+
+			switch key.Value {
+			case "use", "include":
+				next, err := decodeNames(val)
+				if err != nil {
+					return nil, err
+				}
+
+				names = append(names, next...)
+			default:
+				return nil, nodeError(key, "unexpected key %q", key.Value)
+			}
+		}
+
+		return names, nil
+	default:
+		return nil, nodeError(value, "expected a scalar, sequence or mapping node, got %v", kindName(value.Kind))
+	}
+}
+
+func decodeScalar(value *yaml.Node, out *string) error {
+	if value.Kind != yaml.ScalarNode {
+		return nodeError(value, "expected a scalar node, got %v", kindName(value.Kind))
+	}
+
+	*out = value.Value
+	return nil
+}
@@ -1,10 +1,6 @@
 package configs
 
-import (
-	"fmt"
-
-	"gopkg.in/yaml.v3"
-)
+import "gopkg.in/yaml.v3"
 
 type DnsDriverItem struct {
 	Name   string
@@ -24,6 +20,10 @@ func (d *DnsDriverSection) Get(name string) (DnsDriverItem, bool) {
 }
 
 func (d *DnsDriverSection) Set(name string, item DnsDriverItem) {
+	if d.data == nil {
+		d.data = make(map[string]DnsDriverItem)
+	}
+
 	d.data[name] = item
 }
 
@@ -32,9 +32,22 @@ func (d *DnsDriverSection) Has(name string) bool {
 	return ok
 }
 
+func (d *DnsDriverSection) Len() int {
+	return len(d.data)
+}
+
+func (d *DnsDriverSection) Names() []string {
+	names := make([]string, 0, len(d.data))
+	for name := range d.data {
+		names = append(names, name)
+	}
+
+	return names
+}
+
 func (d *DnsDriverSection) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.MappingNode {
-		return fmt.Errorf("expected a mapping node, got %v", value.Kind)
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
 	}
 
 	d.data = make(map[string]DnsDriverItem)
@@ -56,7 +69,7 @@ func (d *DnsDriverSection) UnmarshalYAML(value *yaml.Node) error {
 			item.Name = key.Value
 			d.data[key.Value] = item
 		} else {
-			return fmt.Errorf("expected a scalar or mapping node, got %v", val.Kind)
+			return nodeError(val, "expected a scalar or mapping node, got %v", kindName(val.Kind))
 		}
 	}
 
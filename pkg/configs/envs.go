@@ -1,7 +1,6 @@
 package configs
 
 import (
-	"fmt"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -23,6 +22,10 @@ func (e *EnvsSection) Get(name string) (EnvItem, bool) {
 }
 
 func (e *EnvsSection) Set(name string, item EnvItem) {
+	if e.data == nil {
+		e.data = make(map[string]EnvItem)
+	}
+
 	e.data[name] = item
 }
 
@@ -35,21 +38,41 @@ func (e *EnvsSection) Len() int {
 	return len(e.data)
 }
 
+func (e *EnvsSection) Names() []string {
+	names := make([]string, 0, len(e.data))
+	for name := range e.data {
+		names = append(names, name)
+	}
+
+	return names
+}
+
 func (e *EnvsSection) UnmarshalYAML(value *yaml.Node) error {
 
 	// envs:
 	//   context:
+	//     vars: ...
+	// or
+	// envs:
+	//   context: ./path/to/file.env
 	if value.Kind != yaml.MappingNode {
-		return fmt.Errorf("expected a mapping node, got %v", value.Kind)
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
 	}
 
 	e.data = make(map[string]EnvItem)
 
 	for i := 0; i < len(value.Content); i += 2 {
 		key := value.Content[i]
-		println(key.Value)
 		val := value.Content[i+1]
 
+		if val.Kind == yaml.ScalarNode {
+			e.data[key.Value] = EnvItem{
+				Vars:    map[string]string{},
+				Imports: []string{val.Value},
+			}
+			continue
+		}
+
 		var item EnvItem
 		if err := val.Decode(&item); err != nil {
 			return err
@@ -63,7 +86,7 @@ func (e *EnvsSection) UnmarshalYAML(value *yaml.Node) error {
 
 func (e *EnvItem) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.MappingNode {
-		return fmt.Errorf("expected a mapping node, got %v", value.Kind)
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
 	}
 	// envs:
 	//  context:
@@ -90,7 +113,7 @@ func (e *EnvItem) UnmarshalYAML(value *yaml.Node) error {
 					v := val.Content[j+1]
 
 					if (k.Kind != yaml.ScalarNode) || (v.Kind != yaml.ScalarNode) {
-						return fmt.Errorf("expected scalar nodes, got %v and %v", k.Kind, v.Kind)
+						return nodeError(k, "expected scalar nodes, got %v and %v", kindName(k.Kind), kindName(v.Kind))
 					}
 
 					e.Vars[k.Value] = v.Value
@@ -110,7 +133,7 @@ func (e *EnvItem) UnmarshalYAML(value *yaml.Node) error {
 					}
 
 					if item.Kind != yaml.MappingNode {
-						return fmt.Errorf("expected a mapping node or scalar string node, got %v", item.Kind)
+						return nodeError(item, "expected a mapping node or scalar string node, got %v", kindName(item.Kind))
 					}
 
 					for k := 0; k < len(item.Content); k += 2 {
@@ -118,37 +141,37 @@ func (e *EnvItem) UnmarshalYAML(value *yaml.Node) error {
 						vv := item.Content[k+1]
 
 						if (kk.Kind != yaml.ScalarNode) || (vv.Kind != yaml.ScalarNode) {
-							return fmt.Errorf("expected scalar nodes, got %v and %v", kk.Kind, vv.Kind)
+							return nodeError(kk, "expected scalar nodes, got %v and %v", kindName(kk.Kind), kindName(vv.Kind))
 						}
 
 						e.Vars[kk.Value] = vv.Value
 					}
 				}
 			} else {
-				return fmt.Errorf("expected a mapping or sequence node, got %v", val.Kind)
+				return nodeError(val, "expected a mapping or sequence node, got %v", kindName(val.Kind))
 			}
 		case "imports":
 			if val.Kind != yaml.SequenceNode {
-				return fmt.Errorf("expected a sequence node, got %v", val.Kind)
+				return nodeError(val, "expected a sequence node, got %v", kindName(val.Kind))
 			}
 
 			for j := 0; j < len(val.Content); j++ {
 				item := val.Content[j]
 
 				if item.Kind != yaml.ScalarNode {
-					return fmt.Errorf("expected a scalar node, got %v", item.Kind)
+					return nodeError(item, "expected a scalar node, got %v", kindName(item.Kind))
 				}
 
 				e.Imports = append(e.Imports, item.Value)
 			}
 		case "shared":
 			if val.Kind != yaml.ScalarNode {
-				return fmt.Errorf("expected a scalar node, got %v", val.Kind)
+				return nodeError(val, "expected a scalar node, got %v", kindName(val.Kind))
 			}
 
 			e.Shared = strings.EqualFold(val.Value, "true") || val.Value == "1"
 		default:
-			return fmt.Errorf("unexpected key %q", key.Value)
+			return nodeError(key, "unexpected key %q", key.Value)
 		}
 	}
 
@@ -0,0 +1,408 @@
+package configs
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Represents a host that jolt9 can deploy to.
+//
+//	inventory:
+//	  - name: node1
+//	    host: 10.0.0.10
+//	    user: deploy
+//	    key: ~/.ssh/deploy_ed25519
+//	    groups: [web]
+//	    vars:
+//	      role: primary
+//	    facts:
+//	      os:
+//	        platform: linux
+//	        family: debian
+type InventoryItem struct {
+	Name   string
+	Host   string
+	Port   int
+	User   string
+	Key    string
+	Groups []string
+	Vars   map[string]string
+	Facts  map[string]interface{}
+}
+
+// Fact returns the fact at the dotted path such as `os.family`.
+func (i *InventoryItem) Fact(name string) (interface{}, bool) {
+	var current interface{} = i.Facts
+	for _, part := range strings.Split(name, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func (i *InventoryItem) InGroup(name string) bool {
+	for _, g := range i.Groups {
+		if g == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Represents a named set of hosts with shared vars.
+//
+//	inventory:
+//	  hosts:
+//	    - name: web-1
+//	  groups:
+//	    web:
+//	      hosts: [web-*]
+//	      vars:
+//	        port: "8080"
+type InventoryGroup struct {
+	Name  string
+	Hosts []string
+	Vars  map[string]string
+}
+
+type InventorySection struct {
+	hosts  []InventoryItem
+	groups map[string]InventoryGroup
+}
+
+func (i *InventorySection) Get(name string) (InventoryItem, bool) {
+	for _, host := range i.hosts {
+		if host.Name == name {
+			return i.resolve(host), true
+		}
+	}
+
+	return InventoryItem{}, false
+}
+
+func (i *InventorySection) Set(name string, item InventoryItem) {
+	item.Name = name
+	for j, host := range i.hosts {
+		if host.Name == name {
+			i.hosts[j] = item
+			return
+		}
+	}
+
+	i.hosts = append(i.hosts, item)
+}
+
+// SetFacts replaces the facts of the named host.
+func (i *InventorySection) SetFacts(name string, facts map[string]interface{}) bool {
+	for j, host := range i.hosts {
+		if host.Name == name {
+			i.hosts[j].Facts = facts
+			return true
+		}
+	}
+
+	return false
+}
+
+func (i *InventorySection) Has(name string) bool {
+	_, ok := i.Get(name)
+	return ok
+}
+
+func (i *InventorySection) Len() int {
+	return len(i.hosts)
+}
+
+// Hosts returns the hosts in inventory order. The groups and vars of
+// each host include the groups that select the host.
+func (i *InventorySection) Hosts() []InventoryItem {
+	hosts := make([]InventoryItem, len(i.hosts))
+	for j, host := range i.hosts {
+		hosts[j] = i.resolve(host)
+	}
+
+	return hosts
+}
+
+// Select returns a copy of the inventory with only the named hosts.
+// Groups are kept, so that `group:` selectors still match the hosts
+// that remain.
+func (i *InventorySection) Select(names []string) *InventorySection {
+	selected := &InventorySection{hosts: []InventoryItem{}, groups: i.groups}
+	for _, host := range i.hosts {
+		for _, name := range names {
+			if host.Name == name {
+				selected.hosts = append(selected.hosts, host)
+				break
+			}
+		}
+	}
+
+	return selected
+}
+
+func (i *InventorySection) GetGroup(name string) (InventoryGroup, bool) {
+	group, ok := i.groups[name]
+	return group, ok
+}
+
+func (i *InventorySection) SetGroup(name string, group InventoryGroup) {
+	if i.groups == nil {
+		i.groups = map[string]InventoryGroup{}
+	}
+
+	group.Name = name
+	i.groups[name] = group
+}
+
+func (i *InventorySection) GroupNames() []string {
+	names := make([]string, 0, len(i.groups))
+	for name := range i.groups {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// resolve adds the groups that select host by pattern and merges
+// their vars below the vars of the host.
+func (i *InventorySection) resolve(host InventoryItem) InventoryItem {
+	groups := append([]string{}, host.Groups...)
+	for _, name := range i.GroupNames() {
+		group := i.groups[name]
+		if host.InGroup(name) {
+			continue
+		}
+
+		for _, pattern := range group.Hosts {
+			if ok, _ := path.Match(pattern, host.Name); ok {
+				groups = append(groups, name)
+				break
+			}
+		}
+	}
+
+	vars := map[string]string{}
+	for _, name := range groups {
+		for k, v := range i.groups[name].Vars {
+			vars[k] = v
+		}
+	}
+
+	for k, v := range host.Vars {
+		vars[k] = v
+	}
+
+	host.Groups = groups
+	host.Vars = vars
+	return host
+}
+
+// Match returns the hosts selected by any of the selectors. Hosts are
+// returned in inventory order and only once. A selector is one of
+//
+//	node1               a host name
+//	web-*               a glob matched against host names
+//	group:web           the hosts of a group
+//	facts.os.family=debian
+//	                    hosts whose fact equals the value, `!=` negates
+func (i *InventorySection) Match(selectors []string) ([]InventoryItem, error) {
+	matched := []InventoryItem{}
+	for _, host := range i.Hosts() {
+		for _, selector := range selectors {
+			ok, err := matchHost(&host, selector)
+			if err != nil {
+				return nil, err
+			}
+
+			if ok {
+				matched = append(matched, host)
+				break
+			}
+		}
+	}
+
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("no inventory hosts match %v", selectors)
+	}
+
+	return matched, nil
+}
+
+func matchHost(host *InventoryItem, selector string) (bool, error) {
+	selector = strings.TrimSpace(selector)
+	if group, ok := strings.CutPrefix(selector, "group:"); ok {
+		return host.InGroup(group), nil
+	}
+
+	if fact, ok := strings.CutPrefix(selector, "facts."); ok {
+		negate := false
+		name, value, found := strings.Cut(fact, "!=")
+		if found {
+			negate = true
+		} else {
+			name, value, found = strings.Cut(fact, "=")
+		}
+
+		if !found {
+			v, ok := host.Fact(fact)
+			return ok && v != nil, nil
+		}
+
+		v, ok := host.Fact(strings.TrimSpace(name))
+		equal := ok && strings.EqualFold(fmt.Sprint(v), strings.TrimSpace(value))
+		return equal != negate, nil
+	}
+
+	ok, err := path.Match(selector, host.Name)
+	if err != nil {
+		return false, fmt.Errorf("invalid host selector %q: %w", selector, err)
+	}
+
+	return ok, nil
+}
+
+func (i *InventorySection) UnmarshalYAML(value *yaml.Node) error {
+	// inventory:
+	//   - name: node1
+	//     host: 10.0.0.10
+	// or
+	// inventory:
+	//   node1: 10.0.0.10
+	//   node2:
+	//     host: 10.0.0.11
+	// or
+	// inventory:
+	//   hosts: ...
+	//   groups:
+	//     web: [node1, node2]
+	i.hosts = make([]InventoryItem, 0)
+	i.groups = map[string]InventoryGroup{}
+
+	if value.Kind == yaml.MappingNode && isStructuredInventory(value) {
+		for j := 0; j < len(value.Content); j += 2 {
+			key := value.Content[j]
+			val := value.Content[j+1]
+
+			switch key.Value {
+			case "hosts":
+				if err := i.decodeHosts(val); err != nil {
+					return err
+				}
+			case "groups":
+				if err := i.decodeGroups(val); err != nil {
+					return err
+				}
+			default:
+				return nodeError(key, "unknown inventory key %q, expected hosts or groups", key.Value)
+			}
+		}
+
+		return nil
+	}
+
+	return i.decodeHosts(value)
+}
+
+// isStructuredInventory reports whether the mapping uses the hosts
+// and groups keys rather than mapping host names to hosts.
+func isStructuredInventory(value *yaml.Node) bool {
+	for j := 0; j < len(value.Content); j += 2 {
+		key := value.Content[j].Value
+		val := value.Content[j+1]
+		if key == "hosts" && val.Kind != yaml.ScalarNode {
+			return true
+		}
+
+		if key == "groups" && val.Kind == yaml.MappingNode {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (i *InventorySection) decodeHosts(value *yaml.Node) error {
+	switch value.Kind {
+	case yaml.SequenceNode:
+		for _, val := range value.Content {
+			var item InventoryItem
+			if err := val.Decode(&item); err != nil {
+				return err
+			}
+
+			if item.Name == "" {
+				return nodeError(val, "inventory host is missing a name")
+			}
+
+			i.hosts = append(i.hosts, item)
+		}
+	case yaml.MappingNode:
+		for j := 0; j < len(value.Content); j += 2 {
+			key := value.Content[j]
+			val := value.Content[j+1]
+
+			var item InventoryItem
+			if val.Kind == yaml.ScalarNode {
+				item.Host = val.Value
+			} else if err := val.Decode(&item); err != nil {
+				return err
+			}
+
+			item.Name = key.Value
+			i.hosts = append(i.hosts, item)
+		}
+	default:
+		return nodeError(value, "expected a sequence or mapping node, got %v", kindName(value.Kind))
+	}
+
+	return nil
+}
+
+func (i *InventorySection) decodeGroups(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
+	}
+
+	for j := 0; j < len(value.Content); j += 2 {
+		key := value.Content[j]
+		val := value.Content[j+1]
+
+		group := InventoryGroup{Name: key.Value}
+		switch val.Kind {
+		case yaml.ScalarNode, yaml.SequenceNode:
+			hosts, err := decodeNames(val)
+			if err != nil {
+				return err
+			}
+
+			group.Hosts = hosts
+		case yaml.MappingNode:
+			if err := val.Decode(&group); err != nil {
+				return err
+			}
+
+			group.Name = key.Value
+		default:
+			return nodeError(val, "expected a scalar, sequence or mapping node, got %v", kindName(val.Kind))
+		}
+
+		i.groups[key.Value] = group
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package configs_test
+
+import (
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestInventorySection(t *testing.T) {
+	yamlData := `
+inventory:
+  hosts:
+    - name: node1
+      host: 10.0.0.10
+      user: deploy
+      port: 2222
+      key: ~/.ssh/deploy
+      groups: [db]
+      vars:
+        role: primary
+      facts:
+        os:
+          family: debian
+          id: ubuntu
+    - name: web-1
+      host: 10.0.0.11
+      facts:
+        os:
+          family: rhel
+    - name: web-2
+      host: 10.0.0.12
+  groups:
+    web:
+      hosts: [web-*]
+      vars:
+        role: web
+        port: "8080"
+    db: [node1]
+`
+	var cfg struct {
+		Inventory configs.InventorySection `yaml:"inventory"`
+	}
+
+	err := yaml.Unmarshal([]byte(yamlData), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	inv := cfg.Inventory
+	assert.Equal(t, 3, inv.Len())
+	assert.Equal(t, []string{"db", "web"}, inv.GroupNames())
+
+	node1, ok := inv.Get("node1")
+	assert.True(t, ok)
+	assert.Equal(t, 2222, node1.Port)
+	assert.Equal(t, "~/.ssh/deploy", node1.Key)
+	assert.Equal(t, []string{"db"}, node1.Groups)
+	assert.Equal(t, "primary", node1.Vars["role"])
+
+	family, ok := node1.Fact("os.family")
+	assert.True(t, ok)
+	assert.Equal(t, "debian", family)
+
+	web1, _ := inv.Get("web-1")
+	assert.Equal(t, []string{"web"}, web1.Groups)
+	assert.Equal(t, "web", web1.Vars["role"])
+
+	names := func(selectors ...string) []string {
+		hosts, err := inv.Match(selectors)
+		if err != nil {
+			return nil
+		}
+
+		result := []string{}
+		for _, host := range hosts {
+			result = append(result, host.Name)
+		}
+
+		return result
+	}
+
+	assert.Equal(t, []string{"web-1", "web-2"}, names("group:web"))
+	assert.Equal(t, []string{"node1"}, names("facts.os.family=debian"))
+	assert.Equal(t, []string{"node1", "web-1"}, names("facts.os.family"))
+	assert.Equal(t, []string{"web-1", "web-2"}, names("facts.os.family!=debian"))
+	assert.Equal(t, []string{"node1", "web-2"}, names("node1", "web-2"))
+	assert.Nil(t, names("nope-*"))
+
+	_, err = inv.Match([]string{"["})
+	assert.NotNil(t, err)
+}
+
+func TestInventorySectionShorthand(t *testing.T) {
+	var cfg struct {
+		Inventory configs.InventorySection `yaml:"inventory"`
+	}
+
+	err := yaml.Unmarshal([]byte(`
+inventory:
+  node1: 10.0.0.10
+  hosts: 10.0.0.11
+`), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, ok := cfg.Inventory.Get("hosts")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.11", host.Host)
+
+	err = yaml.Unmarshal([]byte(`
+inventory:
+  hosts: []
+  nope: 1
+`), &cfg)
+	assert.NotNil(t, err)
+}
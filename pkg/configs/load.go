@@ -0,0 +1,103 @@
+package configs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+var ProjectFileNames = []string{"jolt9.yaml", "jolt9.yml"}
+
+var ErrProjectFileNotFound = errors.New("jolt9.yaml not found")
+
+// FindProjectFile walks up from dir until it finds a jolt9.yaml
+// or jolt9.yml file.
+func FindProjectFile(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		for _, name := range ProjectFileNames {
+			file := filepath.Join(dir, name)
+			if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+				return file, nil
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ErrProjectFileNotFound
+		}
+
+		dir = parent
+	}
+}
+
+// Load reads the project config. When path is empty, the
+// jolt9.yaml is discovered from the current working directory.
+// When path is a directory, the search starts from that directory.
+func Load(path string) (*ProjectConfig, error) {
+	if path == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+
+		path = cwd
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if fi.IsDir() {
+		path, err = FindProjectFile(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data, path)
+}
+
+// Parse decodes the project config from data. The file is only
+// used to report errors and is stored on the returned config.
+func Parse(data []byte, file string) (*ProjectConfig, error) {
+	cfg := &ProjectConfig{}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(cfg); err != nil && err != io.EOF {
+		return nil, wrapParseError(err, file)
+	}
+
+	cfg.File = file
+	cfg.Jobs.setFile(file)
+	for _, name := range cfg.Contexts.Names() {
+		item, _ := cfg.Contexts.Get(name)
+		item.Jobs.setFile(file)
+		cfg.Contexts.Set(name, item)
+	}
+
+	return cfg, nil
+}
+
+func wrapParseError(err error, file string) error {
+	var pe *ParseError
+	if errors.As(err, &pe) {
+		pe.File = file
+		return pe
+	}
+
+	return &ParseError{File: file, Err: err}
+}
@@ -0,0 +1,138 @@
+package configs_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseProjectConfig(t *testing.T) {
+	yamlData := `
+id: "@org/app"
+version: 1.0.0
+
+vaults:
+  default: "sops:./default.secrets.env"
+
+envs:
+  default: "default.env"
+
+dns:
+  cloudflare: "cloudflare:?CF_API_TOKEN=${CF_API_TOKEN}"
+
+traefik:
+  default:
+    acme:
+      email: ${{ secrets.ACME_EMAIL }}
+      dns: cloudflare
+
+jobs:
+  before_deploy:
+    - run: echo "before deploy"
+      timeout: 30
+    - other
+
+contexts:
+  ha:
+    envs:
+      use: "./ha.env"
+    servers: [node1]
+    dns: cloudflare
+
+inventory:
+  - name: node1
+    host: 10.0.0.10
+    facts:
+      os:
+        platform: linux
+`
+
+	cfg, err := configs.Parse([]byte(yamlData), "jolt9.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "@org/app", cfg.Id)
+	assert.Equal(t, "1.0.0", cfg.Version)
+	assert.Equal(t, "jolt9.yaml", cfg.File)
+	assert.True(t, cfg.Vaults.Has("default"))
+	assert.True(t, cfg.Dns.Has("cloudflare"))
+
+	env, ok := cfg.Envs.Get("default")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"default.env"}, env.Imports)
+
+	traefik, ok := cfg.Traefik.Get("default")
+	assert.True(t, ok)
+	assert.True(t, traefik.Acme.Email.IsExpr())
+	assert.Equal(t, "cloudflare", traefik.Acme.Dns)
+
+	job, ok := cfg.Jobs.Get("before_deploy")
+	assert.True(t, ok)
+	assert.Equal(t, "before_deploy", job.Id)
+	assert.Equal(t, 2, len(job.Tasks))
+	assert.Equal(t, "echo \"before deploy\"", job.Tasks[0].Task.Run.Evaluated)
+	assert.Equal(t, 30, job.Tasks[0].Task.Timeout.Evaluated)
+	assert.Equal(t, "other", job.Tasks[1].Ref)
+
+	ha, ok := cfg.Contexts.Get("ha")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"./ha.env"}, ha.Envs)
+	assert.Equal(t, []string{"node1"}, ha.Servers)
+	assert.Equal(t, "cloudflare", ha.Dns)
+
+	node1, ok := cfg.Inventory.Get("node1")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.10", node1.Host)
+	assert.NotNil(t, node1.Facts["os"])
+}
+
+func TestParseProjectConfigError(t *testing.T) {
+	yamlData := `
+envs:
+  default:
+    unknown: true
+`
+
+	_, err := configs.Parse([]byte(yamlData), "jolt9.yaml")
+	assert.NotNil(t, err)
+
+	var pe *configs.ParseError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, "jolt9.yaml", pe.File)
+	assert.Equal(t, 4, pe.Line)
+	assert.Equal(t, 5, pe.Column)
+	assert.Contains(t, err.Error(), "jolt9.yaml:4:5:")
+}
+
+func TestLoadDiscoversProjectFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(root, "jolt9.yaml")
+	if err := os.WriteFile(file, []byte("id: test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := configs.FindProjectFile(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, file, found)
+
+	cfg, err := configs.Load(nested)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "test", cfg.Id)
+	assert.Equal(t, file, cfg.File)
+}
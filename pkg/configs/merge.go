@@ -0,0 +1,223 @@
+package configs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/jolt9dev/jolt9/pkg/os/paths"
+)
+
+const (
+	AppName        = "jolt9"
+	ConfigFileName = "config.yaml"
+)
+
+// ConfigLayer is a single configuration file that takes part in
+// the merge. Layers are merged in order, later layers win.
+type ConfigLayer struct {
+	Name   string
+	File   string
+	Config *ProjectConfig
+
+	// Project marks the layer that owns the project. Vaults, envs,
+	// dns and traefik entries from other layers are only merged
+	// when they are marked as shared.
+	Project bool
+}
+
+// MergedConfig is the effective configuration along with the
+// file each entry came from, keyed by `section.name` such as
+// `vaults.default`.
+type MergedConfig struct {
+	Config  *ProjectConfig
+	Origins map[string]string
+}
+
+func (m *MergedConfig) Origin(key string) string {
+	return m.Origins[key]
+}
+
+// SystemConfigFile returns the path of the system wide config,
+// /etc/jolt9/config.yaml on posix systems.
+func SystemConfigFile() (string, error) {
+	dir, err := paths.AppConfigDir(AppName)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, ConfigFileName), nil
+}
+
+// UserConfigFile returns the path of the user config,
+// ~/.config/jolt9/config.yaml on linux.
+func UserConfigFile() (string, error) {
+	dir, err := paths.AppHomeConfigDir(AppName)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, ConfigFileName), nil
+}
+
+// LoadLayers loads the system, user and project configs in order
+// of precedence. Missing system or user configs are skipped. The
+// project path is resolved the same way as Load.
+func LoadLayers(projectPath string) ([]ConfigLayer, error) {
+	layers := []ConfigLayer{}
+
+	systemFile, err := SystemConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	userFile, err := UserConfigFile()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range []struct{ name, file string }{{"system", systemFile}, {"user", userFile}} {
+		cfg, err := loadOptional(l.file)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg != nil {
+			layers = append(layers, ConfigLayer{Name: l.name, File: l.file, Config: cfg})
+		}
+	}
+
+	project, err := Load(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	layers = append(layers, ConfigLayer{Name: "project", File: project.File, Config: project, Project: true})
+	return layers, nil
+}
+
+// LoadMerged loads all layers and merges them.
+func LoadMerged(projectPath string) (*MergedConfig, error) {
+	layers, err := LoadLayers(projectPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return Merge(layers...), nil
+}
+
+func loadOptional(file string) (*ProjectConfig, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return Parse(data, file)
+}
+
+// Merge layers the configs in order, later layers override entries
+// with the same name from earlier layers.
+func Merge(layers ...ConfigLayer) *MergedConfig {
+	merged := &MergedConfig{
+		Config:  &ProjectConfig{},
+		Origins: map[string]string{},
+	}
+
+	cfg := merged.Config
+	for _, layer := range layers {
+		src := layer.Config
+		if src == nil {
+			continue
+		}
+
+		if layer.Project {
+			cfg.File = layer.File
+		}
+
+		if src.Id != "" {
+			cfg.Id = src.Id
+			merged.Origins["id"] = layer.File
+		}
+
+		if src.Version != "" {
+			cfg.Version = src.Version
+			merged.Origins["version"] = layer.File
+		}
+
+		if src.Context.Default != "" {
+			cfg.Context.Default = src.Context.Default
+			merged.Origins["context.default"] = layer.File
+		}
+
+		for _, name := range src.Vaults.Names() {
+			item, _ := src.Vaults.Get(name)
+			if layer.Project || item.Shared {
+				cfg.Vaults.Set(name, item)
+				merged.Origins["vaults."+name] = layer.File
+			}
+		}
+
+		for _, name := range src.Envs.Names() {
+			item, _ := src.Envs.Get(name)
+			if layer.Project || item.Shared {
+				cfg.Envs.Set(name, item)
+				merged.Origins["envs."+name] = layer.File
+			}
+		}
+
+		for _, name := range src.Dns.Names() {
+			item, _ := src.Dns.Get(name)
+			if layer.Project || item.Shared {
+				cfg.Dns.Set(name, item)
+				merged.Origins["dns."+name] = layer.File
+			}
+		}
+
+		for _, name := range src.Traefik.Names() {
+			item, _ := src.Traefik.Get(name)
+			if layer.Project || item.Shared {
+				cfg.Traefik.Set(name, item)
+				merged.Origins["traefik."+name] = layer.File
+			}
+		}
+
+		for _, name := range src.Contexts.Names() {
+			item, _ := src.Contexts.Get(name)
+			cfg.Contexts.Set(name, item)
+			merged.Origins["contexts."+name] = layer.File
+		}
+
+		for _, name := range src.Jobs.Names() {
+			item, _ := src.Jobs.Get(name)
+			cfg.Jobs.Set(name, item)
+			merged.Origins["jobs."+name] = layer.File
+		}
+
+		for _, host := range src.Inventory.Hosts() {
+			cfg.Inventory.Set(host.Name, host)
+			merged.Origins["inventory."+host.Name] = layer.File
+		}
+
+		for _, name := range src.Inventory.GroupNames() {
+			group, _ := src.Inventory.GetGroup(name)
+			cfg.Inventory.SetGroup(name, group)
+			merged.Origins["inventory.groups."+name] = layer.File
+		}
+
+		if len(src.Compose.Include) > 0 {
+			cfg.Compose.Include = src.Compose.Include
+			merged.Origins["compose.include"] = layer.File
+		}
+
+		if len(src.Compose.Exclude) > 0 {
+			cfg.Compose.Exclude = src.Compose.Exclude
+			merged.Origins["compose.exclude"] = layer.File
+		}
+	}
+
+	return merged
+}
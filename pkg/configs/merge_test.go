@@ -0,0 +1,77 @@
+package configs_test
+
+import (
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeLayers(t *testing.T) {
+	system, err := configs.Parse([]byte(`
+vaults:
+  private: "sops:./private.env"
+  global:
+    uri: "sops:./global.env"
+    shared: true
+dns:
+  cloudflare:
+    uri: "cloudflare:"
+    shared: true
+inventory:
+  - name: node1
+    host: 10.0.0.10
+`), "/etc/jolt9/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := configs.Parse([]byte(`
+envs:
+  default:
+    vars:
+      NAME: user
+    shared: true
+`), "/home/user/.config/jolt9/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := configs.Parse([]byte(`
+id: app
+envs:
+  default:
+    vars:
+      NAME: project
+vaults:
+  local: "sops:./local.env"
+`), "/src/app/jolt9.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := configs.Merge(
+		configs.ConfigLayer{Name: "system", File: system.File, Config: system},
+		configs.ConfigLayer{Name: "user", File: user.File, Config: user},
+		configs.ConfigLayer{Name: "project", File: project.File, Config: project, Project: true},
+	)
+
+	cfg := merged.Config
+	assert.Equal(t, "app", cfg.Id)
+	assert.Equal(t, "/src/app/jolt9.yaml", cfg.File)
+
+	assert.False(t, cfg.Vaults.Has("private"))
+	assert.True(t, cfg.Vaults.Has("global"))
+	assert.True(t, cfg.Vaults.Has("local"))
+	assert.Equal(t, "/etc/jolt9/config.yaml", merged.Origin("vaults.global"))
+	assert.Equal(t, "/src/app/jolt9.yaml", merged.Origin("vaults.local"))
+
+	env, ok := cfg.Envs.Get("default")
+	assert.True(t, ok)
+	assert.Equal(t, "project", env.Vars["NAME"])
+	assert.Equal(t, "/src/app/jolt9.yaml", merged.Origin("envs.default"))
+
+	assert.True(t, cfg.Dns.Has("cloudflare"))
+	assert.True(t, cfg.Inventory.Has("node1"))
+	assert.Equal(t, "/etc/jolt9/config.yaml", merged.Origin("inventory.node1"))
+}
@@ -5,43 +5,37 @@ type ComposeSection struct {
 	Exclude []string `yaml:"exclude"`
 }
 
-type ContextsSection struct {
-}
-
-type TraefikSection struct {
-	Ingnore bool
-	Enabled bool
-}
-
-type ContextItem struct {
-	Vaults    []string
-	Envs      []string
-	Dns       string
-	SshConfig string
-	Servers   []string
-}
-
 type UseEnvsSection struct {
 	Vars    []string
 	Include []string
 }
 
-type SecretItem struct {
-	Name     string
-	Key      string
-	Generate bool
-	Special  string
-	Digits   bool
-	Lower    bool
-	Upper    bool
-}
-
 type UseVaultsSection struct {
 	Include []string
 	Secrets []SecretItem
 }
 
+// ContextSettings holds the defaults for selecting a context.
+//
+//	context:
+//	  default: ha
+type ContextSettings struct {
+	Default string
+}
+
 type ProjectConfig struct {
-	Vaults VaultsSection
-	Envs   EnvsSection
+	Id        string
+	Version   string
+	Context   ContextSettings
+	Vaults    VaultsSection
+	Envs      EnvsSection
+	Dns       DnsDriverSection
+	Traefik   TraefikSection
+	Compose   ComposeSection
+	Contexts  ContextsSection
+	Inventory InventorySection
+	Jobs      JobsSection
+
+	// File is the path the config was loaded from.
+	File string `yaml:"-"`
 }
@@ -0,0 +1,74 @@
+package configs
+
+import "gopkg.in/yaml.v3"
+
+// Represents a secret that a context requires.
+//
+//	secrets:
+//	  - ACME_EMAIL
+//	  - name: PG_PASSWORD
+//	    key: postgres-password # defaults to the name
+//	    vault: default         # defaults to the default vault
+//	    gen: true              # generate when missing
+//	    length: 24
+//	    digits: true
+//	    lower: true
+//	    upper: true
+//	    special: "-_"          # or true for the default set
+//	    rotate: [restart_db]   # jobs to run after the secret is rotated
+type SecretItem struct {
+	Name     string
+	Key      string
+	Vault    string
+	Generate bool
+	Length   int
+	Special  string
+	Digits   bool
+	Lower    bool
+	Upper    bool
+
+	// Rotate are the ids of the jobs that apply a rotated value, e.g.
+	// by restarting the services that use the secret.
+	Rotate []string
+}
+
+// VaultKey returns the key of the secret in the vault.
+func (s *SecretItem) VaultKey() string {
+	if s.Key != "" {
+		return s.Key
+	}
+
+	return s.Name
+}
+
+func (s *SecretItem) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		s.Name = value.Value
+		return nil
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return nodeError(value, "expected a scalar or mapping node, got %v", kindName(value.Kind))
+	}
+
+	type plain SecretItem
+	var item struct {
+		plain `yaml:",inline"`
+		Gen   *bool `yaml:"gen"`
+	}
+
+	if err := value.Decode(&item); err != nil {
+		return err
+	}
+
+	*s = SecretItem(item.plain)
+	if item.Gen != nil {
+		s.Generate = *item.Gen
+	}
+
+	if s.Name == "" {
+		return nodeError(value, "secret is missing a name")
+	}
+
+	return nil
+}
@@ -1,6 +1,11 @@
 package configs
 
-import "strings"
+import (
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
 
 type TaskSection struct {
 	Id      string
@@ -12,6 +17,8 @@ type TaskSection struct {
 	With    map[string]*ExprStringItem
 	Force   *ExprBoolItem
 	Run     *ExprStringItem
+	Shell   string
+	On      Targets
 }
 
 type TaskDirectiveElement struct {
@@ -22,10 +29,34 @@ type TaskDirectiveElement struct {
 type JobSection struct {
 	Id      string
 	Name    string
-	Env     map[string]*ExprIntItem
+	Env     map[string]*ExprStringItem
 	Timeout *ExprIntItem
 	Force   *ExprBoolItem
+	On      Targets
 	Tasks   []TaskDirectiveElement
+
+	// File is the config file the job was read from, it is set by
+	// Parse so that expression errors point back to the file.
+	File string
+}
+
+// Targets are the inventory hosts a task runs on, written as a
+// single pattern or a list of patterns such as `[node1, web-*]`.
+// Tasks without targets run locally.
+type Targets []string
+
+func (t *Targets) UnmarshalYAML(value *yaml.Node) error {
+	names, err := decodeNames(value)
+	if err != nil {
+		return err
+	}
+
+	*t = names
+	return nil
+}
+
+type JobsSection struct {
+	data map[string]JobSection
 }
 
 type ExprValueItem struct {
@@ -33,6 +64,10 @@ type ExprValueItem struct {
 	isExpr *bool
 	Raw    string
 	Kind   string
+
+	// Line and Column of the yaml node the value was read from.
+	Line   int
+	Column int
 }
 
 func (e *ExprValueItem) HasValue() bool {
@@ -53,7 +88,7 @@ func (e *ExprValueItem) IsBool() bool {
 
 func (e *ExprValueItem) IsExpr() bool {
 	if e.isExpr == nil {
-		if (len(e.Raw) > 0) && (strings.Index(e.Raw, "${{") == 0) {
+		if strings.Contains(e.Raw, "${{") {
 			b := true
 			e.isExpr = &b
 		} else {
@@ -65,9 +100,41 @@ func (e *ExprValueItem) IsExpr() bool {
 	return *e.isExpr
 }
 
+func (e *ExprValueItem) decode(value *yaml.Node, kind string) error {
+	if value.Kind != yaml.ScalarNode {
+		return nodeError(value, "expected a scalar node, got %v", kindName(value.Kind))
+	}
+
+	e.Raw = value.Value
+	e.Kind = kind
+	e.Line = value.Line
+	e.Column = value.Column
+	e.isExpr = nil
+	return nil
+}
+
 type ExprBoolItem struct {
 	ExprValueItem
-	Evalutated bool
+	Evaluated bool
+}
+
+func (e *ExprBoolItem) UnmarshalYAML(value *yaml.Node) error {
+	if err := e.decode(value, "bool"); err != nil {
+		return err
+	}
+
+	if e.IsExpr() {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(strings.TrimSpace(e.Raw))
+	if err != nil {
+		return nodeError(value, "expected a bool, got %q", e.Raw)
+	}
+
+	e.Value = b
+	e.Evaluated = b
+	return nil
 }
 
 type ExprIntItem struct {
@@ -75,7 +142,154 @@ type ExprIntItem struct {
 	Evaluated int
 }
 
+func (e *ExprIntItem) UnmarshalYAML(value *yaml.Node) error {
+	if err := e.decode(value, "int"); err != nil {
+		return err
+	}
+
+	if e.IsExpr() {
+		return nil
+	}
+
+	i, err := strconv.Atoi(strings.TrimSpace(e.Raw))
+	if err != nil {
+		return nodeError(value, "expected an int, got %q", e.Raw)
+	}
+
+	e.Value = i
+	e.Evaluated = i
+	return nil
+}
+
 type ExprStringItem struct {
 	ExprValueItem
 	Evaluated string
 }
+
+func (e *ExprStringItem) UnmarshalYAML(value *yaml.Node) error {
+	if err := e.decode(value, "string"); err != nil {
+		return err
+	}
+
+	if e.IsExpr() {
+		return nil
+	}
+
+	e.Value = e.Raw
+	e.Evaluated = e.Raw
+	return nil
+}
+
+func (t *TaskDirectiveElement) UnmarshalYAML(value *yaml.Node) error {
+	// tasks:
+	//   - other_job
+	//   - run: echo "hello"
+	switch value.Kind {
+	case yaml.ScalarNode:
+		t.Ref = value.Value
+		return nil
+	case yaml.MappingNode:
+		task := &TaskSection{}
+		if err := value.Decode(task); err != nil {
+			return err
+		}
+
+		t.Task = task
+		return nil
+	default:
+		return nodeError(value, "expected a scalar or mapping node, got %v", kindName(value.Kind))
+	}
+}
+
+func (j *JobSection) UnmarshalYAML(value *yaml.Node) error {
+	// jobs:
+	//   name:
+	//     - run: echo "hello"
+	// or
+	// jobs:
+	//   name:
+	//     timeout: 60
+	//     tasks:
+	//       - run: echo "hello"
+	if value.Kind == yaml.SequenceNode {
+		return value.Decode(&j.Tasks)
+	}
+
+	if value.Kind != yaml.MappingNode {
+		return nodeError(value, "expected a sequence or mapping node, got %v", kindName(value.Kind))
+	}
+
+	type plain JobSection
+	var job plain
+	if err := value.Decode(&job); err != nil {
+		return err
+	}
+
+	*j = JobSection(job)
+	return nil
+}
+
+func (j *JobsSection) Get(name string) (JobSection, bool) {
+	item, ok := j.data[name]
+	return item, ok
+}
+
+func (j *JobsSection) Set(name string, item JobSection) {
+	if j.data == nil {
+		j.data = make(map[string]JobSection)
+	}
+
+	j.data[name] = item
+}
+
+// setFile sets the file of every job.
+func (j *JobsSection) setFile(file string) {
+	for name, job := range j.data {
+		job.File = file
+		j.data[name] = job
+	}
+}
+
+func (j *JobsSection) Has(name string) bool {
+	_, ok := j.data[name]
+	return ok
+}
+
+func (j *JobsSection) Len() int {
+	return len(j.data)
+}
+
+func (j *JobsSection) Names() []string {
+	names := make([]string, 0, len(j.data))
+	for name := range j.data {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (j *JobsSection) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
+	}
+
+	j.data = make(map[string]JobSection)
+
+	for i := 0; i < len(value.Content); i += 2 {
+		key := value.Content[i]
+		val := value.Content[i+1]
+
+		var item JobSection
+		if err := val.Decode(&item); err != nil {
+			return err
+		}
+
+		if item.Id == "" {
+			item.Id = key.Value
+		}
+
+		j.data[key.Value] = item
+	}
+
+	return nil
+}
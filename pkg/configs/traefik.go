@@ -0,0 +1,87 @@
+package configs
+
+import "gopkg.in/yaml.v3"
+
+type TraefikAcmeItem struct {
+	Email *ExprStringItem `yaml:"email"`
+	Dns   string          `yaml:"dns"`
+}
+
+// Represents a named traefik configuration. `static` is passed
+// through to traefik as is.
+//
+// traefik:
+//
+//	default:
+//	  acme:
+//	    email: ${{ secrets.ACME_EMAIL }}
+//	    dns: cloudflare
+//	  static:
+//	    log:
+//	      level: DEBUG
+type TraefikItem struct {
+	Name    string
+	Ignore  bool                   `yaml:"ignore"`
+	Enabled bool                   `yaml:"enabled"`
+	Shared  bool                   `yaml:"shared"`
+	Acme    *TraefikAcmeItem       `yaml:"acme"`
+	Static  map[string]interface{} `yaml:"static"`
+}
+
+type TraefikSection struct {
+	data map[string]TraefikItem
+}
+
+func (t *TraefikSection) Get(name string) (TraefikItem, bool) {
+	item, ok := t.data[name]
+	return item, ok
+}
+
+func (t *TraefikSection) Set(name string, item TraefikItem) {
+	if t.data == nil {
+		t.data = make(map[string]TraefikItem)
+	}
+
+	t.data[name] = item
+}
+
+func (t *TraefikSection) Has(name string) bool {
+	_, ok := t.data[name]
+	return ok
+}
+
+func (t *TraefikSection) Len() int {
+	return len(t.data)
+}
+
+func (t *TraefikSection) Names() []string {
+	names := make([]string, 0, len(t.data))
+	for name := range t.data {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (t *TraefikSection) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind != yaml.MappingNode {
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
+	}
+
+	t.data = make(map[string]TraefikItem)
+
+	for i := 0; i < len(value.Content); i += 2 {
+		key := value.Content[i]
+		val := value.Content[i+1]
+
+		var item TraefikItem
+		if err := val.Decode(&item); err != nil {
+			return err
+		}
+
+		item.Name = key.Value
+		t.data[key.Value] = item
+	}
+
+	return nil
+}
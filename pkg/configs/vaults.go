@@ -1,7 +1,6 @@
 package configs
 
 import (
-	"fmt"
 	"strings"
 
 	"gopkg.in/yaml.v3"
@@ -55,6 +54,10 @@ func (v *VaultsSection) Get(name string) (VaultItem, bool) {
 }
 
 func (v *VaultsSection) Set(name string, item VaultItem) {
+	if v.data == nil {
+		v.data = make(map[string]VaultItem)
+	}
+
 	v.data[name] = item
 }
 
@@ -78,7 +81,7 @@ func (v *VaultsSection) Names() []string {
 
 func (v *VaultsSection) UnmarshalYAML(value *yaml.Node) error {
 	if value.Kind != yaml.MappingNode {
-		return fmt.Errorf("expected a mapping node, got %v", value.Kind)
+		return nodeError(value, "expected a mapping node, got %v", kindName(value.Kind))
 	}
 
 	v.data = make(map[string]VaultItem)
@@ -107,7 +110,7 @@ func (v *VaultsSection) UnmarshalYAML(value *yaml.Node) error {
 			item.Name = key.Value
 			v.data[key.Value] = item
 		} else {
-			return fmt.Errorf("expected a scalar or mapping node, got %v", val.Kind)
+			return nodeError(val, "expected a scalar or mapping node, got %v", kindName(val.Kind))
 		}
 	}
 
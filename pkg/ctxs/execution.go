@@ -1,9 +1,19 @@
 package ctxs
 
-import "context"
+import (
+	"context"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+)
 
 type ExecContext struct {
-	Env     map[string]string
-	Secrets map[string]string
-	Context context.Context
+	Env       map[string]string
+	Secrets   map[string]string
+	Vars      map[string]string
+	Inventory *configs.InventorySection
+	Context   context.Context
+
+	// SshConfig is the ssh_config file of the context, empty for the
+	// default files.
+	SshConfig string
 }
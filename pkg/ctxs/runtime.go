@@ -0,0 +1,303 @@
+package ctxs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+
+	// register the built-in vault drivers.
+	_ "github.com/jolt9dev/jolt9/pkg/vaults/age"
+	_ "github.com/jolt9dev/jolt9/pkg/vaults/hcvault"
+	_ "github.com/jolt9dev/jolt9/pkg/vaults/sops"
+)
+
+const DefaultContext = "default"
+
+// Runtime is a context resolved against the project config. Names
+// that the context refers to are looked up so that commands work
+// with vault clients, env values, the dns driver and hosts directly.
+type Runtime struct {
+	// Name of the resolved context.
+	Name    string
+	Context configs.ContextItem
+
+	// Dir is the project directory, relative paths are resolved from it.
+	Dir string
+
+	// Vaults maps the vault names of the context to their clients.
+	Vaults map[string]vaults.SecretVault
+
+	// Chain reads through the vaults in the order of the context and
+	// writes to the `default` vault or the first vault. It is nil
+	// when the context has no vaults.
+	Chain *vaults.Chain
+
+	// Env is the merged env of the context, later envs override
+	// earlier ones.
+	Env map[string]string
+
+	// Dns is the dns driver of the context or nil.
+	Dns *configs.DnsDriverItem
+
+	// SshConfig is the absolute path of the ssh_config file or empty.
+	SshConfig string
+
+	// Hosts are the inventory hosts selected by `servers`.
+	Hosts []configs.InventoryItem
+
+	// Jobs are the project jobs with the jobs of the context.
+	Jobs configs.JobsSection
+
+	Secrets   []configs.SecretItem
+	Inventory *configs.InventorySection
+}
+
+// Resolve builds the runtime for the named context. The `default`
+// context does not need to be declared, it then uses the `default`
+// vault, env and dns entries when they exist and all hosts.
+func Resolve(cfg *configs.ProjectConfig, name string) (*Runtime, error) {
+	if name == "" {
+		name = DefaultContext
+	}
+
+	item, ok := cfg.Contexts.Get(name)
+	if !ok {
+		if name != DefaultContext {
+			names := cfg.Contexts.Names()
+			sort.Strings(names)
+			return nil, fmt.Errorf("context %q not found, available contexts: %s", name, strings.Join(names, ", "))
+		}
+
+		item = configs.ContextItem{Name: DefaultContext}
+	}
+
+	rt := &Runtime{
+		Name:      name,
+		Context:   item,
+		Vaults:    map[string]vaults.SecretVault{},
+		Env:       map[string]string{},
+		Secrets:   item.Secrets,
+		Inventory: &cfg.Inventory,
+	}
+
+	if cfg.File != "" {
+		rt.Dir = filepath.Dir(cfg.File)
+	}
+
+	if err := rt.resolveVaults(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := rt.resolveEnvs(cfg); err != nil {
+		return nil, err
+	}
+
+	if item.Dns != "" || cfg.Dns.Has(DefaultContext) {
+		dnsName := withDefault(item.Dns)
+		dns, ok := cfg.Dns.Get(dnsName)
+		if !ok {
+			return nil, fmt.Errorf("context %q: dns %q not found", name, dnsName)
+		}
+
+		dns.Name = dnsName
+		rt.Dns = &dns
+	}
+
+	if item.SshConfig != "" {
+		rt.SshConfig = rt.path(item.SshConfig)
+	}
+
+	if len(item.Servers) > 0 {
+		hosts, err := cfg.Inventory.Match(item.Servers)
+		if err != nil {
+			return nil, fmt.Errorf("context %q: %w", name, err)
+		}
+
+		rt.Hosts = hosts
+	} else {
+		rt.Hosts = cfg.Inventory.Hosts()
+	}
+
+	for _, id := range cfg.Jobs.Names() {
+		job, _ := cfg.Jobs.Get(id)
+		rt.Jobs.Set(id, job)
+	}
+
+	for _, id := range item.Jobs.Names() {
+		job, _ := item.Jobs.Get(id)
+		rt.Jobs.Set(id, job)
+	}
+
+	return rt, nil
+}
+
+func (r *Runtime) resolveVaults(cfg *configs.ProjectConfig) error {
+	names := r.Context.Vaults
+	if len(names) == 0 && cfg.Vaults.Has(DefaultContext) {
+		names = []string{DefaultContext}
+	}
+
+	links := []vaults.ChainLink{}
+	primary := ""
+	for _, name := range names {
+		item, ok := cfg.Vaults.Get(name)
+		if !ok {
+			return fmt.Errorf("context %q: vault %q not found", r.Name, name)
+		}
+
+		item.Name = name
+		vault, err := vaults.OpenIn(item, r.Dir)
+		if err != nil {
+			return fmt.Errorf("context %q: %w", r.Name, err)
+		}
+
+		// jobs on parallel hosts share the vault, the cache decrypts
+		// the secrets of the context once.
+		r.Vaults[name] = vaults.Cached(vault, vaults.CacheOptions{
+			Prefetch: r.secretKeys(name, len(names) == 1),
+		})
+
+		links = append(links, vaults.ChainLink{Name: name, Vault: r.Vaults[name]})
+		if name == DefaultContext {
+			primary = name
+		}
+	}
+
+	if len(links) == 0 {
+		return nil
+	}
+
+	chain, err := vaults.NewChain(links, primary)
+	if err != nil {
+		return fmt.Errorf("context %q: %w", r.Name, err)
+	}
+
+	r.Chain = chain
+	return nil
+}
+
+// secretKeys returns the vault keys of the context secrets stored in
+// the named vault. Secrets without a vault use the default vault or
+// the only vault of the context.
+func (r *Runtime) secretKeys(vault string, only bool) []string {
+	keys := []string{}
+	for _, item := range r.Secrets {
+		if item.Vault == vault || (item.Vault == "" && (vault == DefaultContext || only)) {
+			keys = append(keys, item.VaultKey())
+		}
+	}
+
+	return keys
+}
+
+func (r *Runtime) resolveEnvs(cfg *configs.ProjectConfig) error {
+	names := r.Context.Envs
+	if len(names) == 0 && cfg.Envs.Has(DefaultContext) {
+		names = []string{DefaultContext}
+	}
+
+	for _, name := range names {
+		item, ok := cfg.Envs.Get(name)
+		if !ok {
+			return fmt.Errorf("context %q: env %q not found", r.Name, name)
+		}
+
+		for _, file := range item.Imports {
+			vars, err := godotenv.Read(r.path(file))
+			if err != nil {
+				return fmt.Errorf("context %q: env %q: %w", r.Name, name, err)
+			}
+
+			for k, v := range vars {
+				r.Env[k] = v
+			}
+		}
+
+		for k, v := range item.Vars {
+			r.Env[k] = v
+		}
+	}
+
+	return nil
+}
+
+// Vault returns the client of the named vault. An empty name returns
+// the chain of the vaults of the context, which reads through every
+// vault and writes to the `default` vault or the first vault.
+func (r *Runtime) Vault(name string) (vaults.SecretVault, error) {
+	if name == "" {
+		if r.Chain == nil {
+			return nil, fmt.Errorf("context %q has no default vault", r.Name)
+		}
+
+		return r.Chain, nil
+	}
+
+	v, ok := r.Vaults[name]
+	if !ok {
+		return nil, fmt.Errorf("context %q does not use vault %q", r.Name, name)
+	}
+
+	return v, nil
+}
+
+// ExecContext creates the execution context for jobs. The env of the
+// context is layered over base, which is usually the process env. The
+// inventory only has the hosts selected by `servers`, so that tasks
+// never run on hosts outside of the context.
+func (r *Runtime) ExecContext(base map[string]string) *ExecContext {
+	env := map[string]string{}
+	for k, v := range base {
+		env[k] = v
+	}
+
+	for k, v := range r.Env {
+		env[k] = v
+	}
+
+	inventory := r.Inventory
+	if inventory != nil && len(r.Context.Servers) > 0 {
+		names := make([]string, len(r.Hosts))
+		for i, host := range r.Hosts {
+			names[i] = host.Name
+		}
+
+		inventory = inventory.Select(names)
+	}
+
+	return &ExecContext{
+		Env:       env,
+		Secrets:   map[string]string{},
+		Vars:      map[string]string{"context": r.Name},
+		Inventory: inventory,
+		SshConfig: r.SshConfig,
+	}
+}
+
+func (r *Runtime) path(p string) string {
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, p[2:])
+		}
+	}
+
+	if filepath.IsAbs(p) || r.Dir == "" {
+		return p
+	}
+
+	return filepath.Join(r.Dir, p)
+}
+
+func withDefault(name string) string {
+	if name == "" {
+		return DefaultContext
+	}
+
+	return name
+}
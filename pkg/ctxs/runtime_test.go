@@ -0,0 +1,157 @@
+package ctxs_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/stretchr/testify/assert"
+)
+
+const projectConfig = `
+context:
+  default: staging
+
+vaults:
+  default: "sops:./default.secrets.env"
+  ha: "sops:./ha.secrets.env"
+
+envs:
+  default:
+    vars:
+      A: "1"
+  ha: ./ha.env
+
+dns:
+  default: hostsfile
+  cloudflare: "cloudflare:?CF_API_TOKEN=${CF_API_TOKEN}"
+
+inventory:
+  node1: 10.0.0.1
+  node2: 10.0.0.2
+
+contexts:
+  staging:
+    servers: [node1]
+  ha:
+    vaults: [default, ha]
+    envs: [default, ha]
+    dns: cloudflare
+    ssh_config: ./ssh_config
+    jobs:
+      deploy:
+        - run: echo ha
+
+jobs:
+  deploy:
+    - run: echo default
+  build:
+    - run: echo build
+`
+
+func parse(t *testing.T) *configs.ProjectConfig {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ha.env"), []byte("A=2\nB=3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := configs.Parse([]byte(projectConfig), filepath.Join(dir, "jolt9.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cfg
+}
+
+func TestResolve(t *testing.T) {
+	cfg := parse(t)
+
+	rt, err := ctxs.Resolve(cfg, "ha")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "ha", rt.Name)
+	assert.Equal(t, 2, len(rt.Vaults))
+	assert.Equal(t, map[string]string{"A": "2", "B": "3"}, rt.Env)
+	assert.Equal(t, "cloudflare", rt.Dns.Name)
+	assert.Equal(t, filepath.Join(rt.Dir, "ssh_config"), rt.SshConfig)
+	assert.Equal(t, 2, len(rt.Hosts))
+
+	job, _ := rt.Jobs.Get("deploy")
+	assert.Equal(t, "echo ha", job.Tasks[0].Task.Run.Raw)
+	assert.True(t, rt.Jobs.Has("build"))
+
+	_, err = rt.Vault("")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"default", "ha"}, rt.Chain.Names())
+	assert.Equal(t, "default", rt.Chain.Primary())
+	_, err = rt.Vault("nope")
+	assert.NotNil(t, err)
+
+	ctx := rt.ExecContext(map[string]string{"A": "0", "PATH": "/bin"})
+	assert.Equal(t, "2", ctx.Env["A"])
+	assert.Equal(t, "/bin", ctx.Env["PATH"])
+	assert.Equal(t, "ha", ctx.Vars["context"])
+
+	rt, err = ctxs.Resolve(cfg, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"default"}, keys(rt.Vaults))
+	assert.Equal(t, "1", rt.Env["A"])
+	assert.Equal(t, "default", rt.Dns.Name)
+	assert.Equal(t, 1, len(rt.Hosts))
+	assert.Equal(t, "node1", rt.Hosts[0].Name)
+	assert.Equal(t, 1, rt.ExecContext(nil).Inventory.Len())
+
+	_, err = ctxs.Resolve(cfg, "default")
+	assert.Nil(t, err)
+
+	_, err = ctxs.Resolve(cfg, "nope")
+	assert.Contains(t, err.Error(), "ha, staging")
+}
+
+func TestSelectContext(t *testing.T) {
+	cfg := parse(t)
+	store := &ctxs.ContextStore{File: filepath.Join(t.TempDir(), "contexts.json")}
+	t.Setenv(ctxs.EnvContext, "")
+
+	name, source, err := ctxs.SelectContext("", cfg, store)
+	assert.Nil(t, err)
+	assert.Equal(t, "staging", name)
+	assert.Equal(t, ctxs.SourceConfig, source)
+
+	assert.Nil(t, store.Set(filepath.Dir(cfg.File), "ha"))
+	name, source, _ = ctxs.SelectContext("", cfg, store)
+	assert.Equal(t, "ha", name)
+	assert.Equal(t, ctxs.SourceProject, source)
+
+	t.Setenv(ctxs.EnvContext, "staging")
+	name, source, _ = ctxs.SelectContext("", cfg, store)
+	assert.Equal(t, "staging", name)
+	assert.Equal(t, ctxs.SourceEnv, source)
+
+	name, source, _ = ctxs.SelectContext("ha", cfg, store)
+	assert.Equal(t, "ha", name)
+	assert.Equal(t, ctxs.SourceFlag, source)
+
+	t.Setenv(ctxs.EnvContext, "")
+	assert.Nil(t, store.Set(filepath.Dir(cfg.File), ""))
+	cfg.Context.Default = ""
+	name, source, _ = ctxs.SelectContext("", cfg, store)
+	assert.Equal(t, "default", name)
+	assert.Equal(t, ctxs.SourceDefault, source)
+}
+
+func keys[T any](m map[string]T) []string {
+	result := []string{}
+	for k := range m {
+		result = append(result, k)
+	}
+
+	return result
+}
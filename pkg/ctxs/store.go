@@ -0,0 +1,136 @@
+package ctxs
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/os/env"
+	"github.com/jolt9dev/jolt9/pkg/os/paths"
+)
+
+// EnvContext is the environment variable that selects the context
+// when the --context flag is not set.
+const EnvContext = "J9_CONTEXT"
+
+// Where the name of a context was selected from.
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceProject = "project"
+	SourceConfig  = "config"
+	SourceDefault = "default"
+)
+
+// ContextStore persists the active context of each project in a
+// json file that maps project directories to context names.
+type ContextStore struct {
+	File string
+}
+
+// NewContextStore returns the store in paths.AppHomeDataDir.
+func NewContextStore() (*ContextStore, error) {
+	dir, err := paths.AppHomeDataDir(configs.AppName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContextStore{File: filepath.Join(dir, "contexts.json")}, nil
+}
+
+func (s *ContextStore) read() (map[string]string, error) {
+	data, err := os.ReadFile(s.File)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]string{}, nil
+		}
+
+		return nil, err
+	}
+
+	active := map[string]string{}
+	if err := json.Unmarshal(data, &active); err != nil {
+		return nil, err
+	}
+
+	return active, nil
+}
+
+// Get returns the active context of the project directory or an
+// empty string when none was set.
+func (s *ContextStore) Get(projectDir string) (string, error) {
+	active, err := s.read()
+	if err != nil {
+		return "", err
+	}
+
+	return active[projectKey(projectDir)], nil
+}
+
+// Set stores the active context of the project directory, an empty
+// name removes it.
+func (s *ContextStore) Set(projectDir, name string) error {
+	active, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	key := projectKey(projectDir)
+	if name == "" {
+		delete(active, key)
+	} else {
+		active[key] = name
+	}
+
+	data, err := json.MarshalIndent(active, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.File), 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(s.File, data, 0644)
+}
+
+func projectKey(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+
+	return dir
+}
+
+// SelectContext returns the name of the context to use and where it
+// was selected from. The flag wins over J9_CONTEXT, which wins over
+// the context set with `jolt9 context use`, then `context.default`
+// of the config and finally `default`.
+func SelectContext(flag string, cfg *configs.ProjectConfig, store *ContextStore) (string, string, error) {
+	if flag != "" {
+		return flag, SourceFlag, nil
+	}
+
+	if name := env.Get(EnvContext); name != "" {
+		return name, SourceEnv, nil
+	}
+
+	if store != nil && cfg.File != "" {
+		name, err := store.Get(filepath.Dir(cfg.File))
+		if err != nil {
+			return "", "", err
+		}
+
+		if name != "" {
+			return name, SourceProject, nil
+		}
+	}
+
+	if cfg.Context.Default != "" {
+		return cfg.Context.Default, SourceConfig, nil
+	}
+
+	return DefaultContext, SourceDefault, nil
+}
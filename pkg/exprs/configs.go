@@ -0,0 +1,228 @@
+package exprs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+)
+
+// EvalError is returned when an expression from a config file
+// fails to evaluate. File is the config file of the expression, Line
+// and Column point at the yaml node.
+type EvalError struct {
+	File   string
+	Expr   string
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *EvalError) Error() string {
+	pos := ""
+	if e.Line != 0 {
+		pos = fmt.Sprintf("%d:%d", e.Line, e.Column)
+	}
+
+	if e.File != "" {
+		pos = strings.TrimSuffix(e.File+":"+pos, ":")
+	}
+
+	if pos == "" {
+		return fmt.Sprintf("expression %q: %v", e.Expr, e.Err)
+	}
+
+	return fmt.Sprintf("%s: expression %q: %v", pos, e.Expr, e.Err)
+}
+
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
+
+// InFile sets the file of err when it is an EvalError without one,
+// other errors are returned as is.
+func InFile(err error, file string) error {
+	var ee *EvalError
+	if file != "" && errors.As(err, &ee) && ee.File == "" {
+		ee.File = file
+	}
+
+	return err
+}
+
+func itemError(item *configs.ExprValueItem, err error) error {
+	return &EvalError{Expr: item.Raw, Line: item.Line, Column: item.Column, Err: err}
+}
+
+// NewScope creates the scope for an execution context which exposes
+// `env`, `secrets`, `vars` and `inventory`.
+func NewScope(ctx *ctxs.ExecContext) Scope {
+	scope := Scope{
+		"env":       map[string]interface{}{},
+		"secrets":   map[string]interface{}{},
+		"vars":      map[string]interface{}{},
+		"inventory": map[string]interface{}{},
+	}
+
+	if ctx == nil {
+		return scope
+	}
+
+	scope["env"] = normalize(ctx.Env)
+	scope["secrets"] = normalize(ctx.Secrets)
+	scope["vars"] = normalize(ctx.Vars)
+
+	if ctx.Inventory != nil {
+		hosts := map[string]interface{}{}
+		for _, host := range ctx.Inventory.Hosts() {
+			hosts[host.Name] = map[string]interface{}{
+				"name":   host.Name,
+				"host":   host.Host,
+				"port":   host.Port,
+				"user":   host.User,
+				"groups": host.Groups,
+				"vars":   host.Vars,
+				"facts":  host.Facts,
+			}
+		}
+
+		scope["inventory"] = hosts
+	}
+
+	return scope
+}
+
+func evalItem(item *configs.ExprValueItem, scope Scope) (interface{}, error) {
+	v, err := Interpolate(item.Raw, scope)
+	if err != nil {
+		return nil, itemError(item, err)
+	}
+
+	return v, nil
+}
+
+// EvalString evaluates the item and stores the result in Evaluated.
+func EvalString(item *configs.ExprStringItem, scope Scope) error {
+	if item == nil || !item.IsExpr() {
+		return nil
+	}
+
+	v, err := evalItem(&item.ExprValueItem, scope)
+	if err != nil {
+		return err
+	}
+
+	item.Value = v
+	item.Evaluated = ToString(v)
+	return nil
+}
+
+// EvalInt evaluates the item and stores the result in Evaluated.
+func EvalInt(item *configs.ExprIntItem, scope Scope) error {
+	if item == nil || !item.IsExpr() {
+		return nil
+	}
+
+	v, err := evalItem(&item.ExprValueItem, scope)
+	if err != nil {
+		return err
+	}
+
+	f := ToNumber(v)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return itemError(&item.ExprValueItem, fmt.Errorf("expected an int, got %q", ToString(v)))
+	}
+
+	item.Value = int(f)
+	item.Evaluated = int(f)
+	return nil
+}
+
+// EvalBool evaluates the item and stores the result in Evaluated.
+func EvalBool(item *configs.ExprBoolItem, scope Scope) error {
+	if item == nil || !item.IsExpr() {
+		return nil
+	}
+
+	v, err := evalItem(&item.ExprValueItem, scope)
+	if err != nil {
+		return err
+	}
+
+	item.Value = Truthy(v)
+	item.Evaluated = Truthy(v)
+	return nil
+}
+
+// EvalCondition evaluates an `if` item. The `${{ }}` is optional
+// and a missing condition is true.
+func EvalCondition(item *configs.ExprStringItem, scope Scope) (bool, error) {
+	if item == nil || strings.TrimSpace(item.Raw) == "" {
+		return true, nil
+	}
+
+	raw := strings.TrimSpace(item.Raw)
+	if !strings.HasPrefix(raw, "${{") {
+		raw = "${{ " + raw + " }}"
+	}
+
+	v, err := Interpolate(raw, scope)
+	if err != nil {
+		return false, itemError(&item.ExprValueItem, err)
+	}
+
+	return Truthy(v), nil
+}
+
+// EvalTask evaluates the expressions of a task. `if` is left for
+// the caller to evaluate with EvalCondition.
+func EvalTask(task *configs.TaskSection, scope Scope) error {
+	if task == nil {
+		return nil
+	}
+
+	for _, v := range task.Env {
+		if err := EvalString(v, scope); err != nil {
+			return err
+		}
+	}
+
+	for _, v := range task.With {
+		if err := EvalString(v, scope); err != nil {
+			return err
+		}
+	}
+
+	if err := EvalInt(task.Timeout, scope); err != nil {
+		return err
+	}
+
+	if err := EvalBool(task.Force, scope); err != nil {
+		return err
+	}
+
+	return EvalString(task.Run, scope)
+}
+
+// EvalJob evaluates the job level expressions of a job. Tasks are
+// evaluated separately as they may depend on the job's env.
+func EvalJob(job *configs.JobSection, scope Scope) error {
+	if job == nil {
+		return nil
+	}
+
+	for _, v := range job.Env {
+		if err := EvalString(v, scope); err != nil {
+			return InFile(err, job.File)
+		}
+	}
+
+	if err := EvalInt(job.Timeout, scope); err != nil {
+		return InFile(err, job.File)
+	}
+
+	return InFile(EvalBool(job.Force, scope), job.File)
+}
@@ -0,0 +1,396 @@
+package exprs
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Scope holds the named values an expression can reference such
+// as `secrets`, `env`, `vars` and `inventory`.
+type Scope map[string]interface{}
+
+// Evaluate parses and evaluates a single expression without the
+// surrounding `${{ }}`.
+func Evaluate(expr string, scope Scope) (interface{}, error) {
+	n, err := parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.eval(scope)
+}
+
+// Interpolate evaluates every `${{ }}` block in template. When the
+// template is a single block, the value is returned as is, otherwise
+// the blocks are converted to strings and joined with the text
+// around them. A block ends at the first `}}` that is not part of a
+// string of the expression.
+func Interpolate(template string, scope Scope) (interface{}, error) {
+	sb := strings.Builder{}
+	rest := template
+	blocks := 0
+	var value interface{}
+	text := false
+
+	for {
+		start := strings.Index(rest, "${{")
+		if start == -1 {
+			sb.WriteString(rest)
+			text = text || strings.TrimSpace(rest) != ""
+			break
+		}
+
+		n, end, err := parseBlock(rest[start+3:])
+		if err != nil {
+			return nil, err
+		}
+
+		v, err := n.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		text = text || strings.TrimSpace(rest[:start]) != ""
+		blocks++
+		value = v
+		sb.WriteString(rest[:start])
+		sb.WriteString(ToString(v))
+		rest = rest[start+3+end+2:]
+	}
+
+	if blocks == 1 && !text {
+		return value, nil
+	}
+
+	return sb.String(), nil
+}
+
+func (n *literalNode) eval(scope Scope) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n *identNode) eval(scope Scope) (interface{}, error) {
+	return lookup(map[string]interface{}(scope), n.name), nil
+}
+
+func (n *indexNode) eval(scope Scope) (interface{}, error) {
+	target, err := n.target.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := n.index.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := normalize(target).(type) {
+	case map[string]interface{}:
+		return lookup(t, ToString(index)), nil
+	case []interface{}:
+		f := ToNumber(index)
+		if math.IsNaN(f) || f < 0 || int(f) >= len(t) {
+			return nil, nil
+		}
+
+		return t[int(f)], nil
+	}
+
+	// accessing a property on null or a primitive yields null
+	return nil, nil
+}
+
+func (n *unaryNode) eval(scope Scope) (interface{}, error) {
+	v, err := n.operand.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return !Truthy(v), nil
+}
+
+func (n *binaryNode) eval(scope Scope) (interface{}, error) {
+	left, err := n.left.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || short circuit and return the operand that decided
+	// the result rather than a bool.
+	switch n.op {
+	case "&&":
+		if !Truthy(left) {
+			return left, nil
+		}
+
+		return n.right.eval(scope)
+	case "||":
+		if Truthy(left) {
+			return left, nil
+		}
+
+		return n.right.eval(scope)
+	}
+
+	right, err := n.right.eval(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return Equal(left, right), nil
+	case "!=":
+		return !Equal(left, right), nil
+	}
+
+	c, ok := compare(left, right)
+	if !ok {
+		return false, nil
+	}
+
+	switch n.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+
+	return nil, fmt.Errorf("unknown operator %q", n.op)
+}
+
+func (n *callNode) eval(scope Scope) (interface{}, error) {
+	fn, ok := lookupFunction(n.name)
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", n.name)
+	}
+
+	args := make([]interface{}, 0, len(n.args))
+	for _, a := range n.args {
+		v, err := a.eval(scope)
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, v)
+	}
+
+	v, err := fn(args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", n.name, err)
+	}
+
+	return v, nil
+}
+
+// lookup finds the key in m, falling back to a case insensitive match.
+func lookup(m map[string]interface{}, key string) interface{} {
+	if v, ok := m[key]; ok {
+		return v
+	}
+
+	for k, v := range m {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+
+	return nil
+}
+
+// normalize converts maps and slices of any element type into
+// map[string]interface{} and []interface{} so the evaluator
+// only has to deal with a handful of types.
+func normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, string, float64, map[string]interface{}, []interface{}:
+		return v
+	case map[string]string:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = v
+		}
+		return m
+	case []string:
+		s := make([]interface{}, len(t))
+		for i, v := range t {
+			s[i] = v
+		}
+		return s
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32:
+		return rv.Float()
+	case reflect.Map:
+		m := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = iter.Value().Interface()
+		}
+		return m
+	case reflect.Slice, reflect.Array:
+		s := make([]interface{}, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			s[i] = rv.Index(i).Interface()
+		}
+		return s
+	}
+
+	return v
+}
+
+// Truthy reports whether v is considered true. null, false, 0,
+// NaN and the empty string are false, everything else is true.
+func Truthy(v interface{}) bool {
+	switch t := normalize(v).(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case float64:
+		return t != 0 && !math.IsNaN(t)
+	case string:
+		return t != ""
+	}
+
+	return true
+}
+
+// ToNumber converts v to a number, returning NaN when the value
+// can not be converted.
+func ToNumber(v interface{}) float64 {
+	switch t := normalize(v).(type) {
+	case nil:
+		return 0
+	case bool:
+		if t {
+			return 1
+		}
+		return 0
+	case float64:
+		return t
+	case string:
+		s := strings.TrimSpace(t)
+		if s == "" {
+			return 0
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return math.NaN()
+		}
+
+		return f
+	}
+
+	return math.NaN()
+}
+
+// ToString converts v to a string. Objects and arrays are
+// converted to json.
+func ToString(v interface{}) string {
+	switch t := normalize(v).(type) {
+	case nil:
+		return ""
+	case bool:
+		return strconv.FormatBool(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case string:
+		return t
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+
+	return string(b)
+}
+
+// Equal compares two values. Strings are compared case insensitive
+// and values of different types are compared as numbers.
+func Equal(left, right interface{}) bool {
+	l := normalize(left)
+	r := normalize(right)
+
+	switch lv := l.(type) {
+	case nil:
+		if r == nil {
+			return true
+		}
+	case string:
+		if rv, ok := r.(string); ok {
+			return strings.EqualFold(lv, rv)
+		}
+	case bool:
+		if rv, ok := r.(bool); ok {
+			return lv == rv
+		}
+	case float64:
+		if rv, ok := r.(float64); ok {
+			return lv == rv
+		}
+	case map[string]interface{}, []interface{}:
+		return reflect.DeepEqual(l, r)
+	}
+
+	if isComposite(r) {
+		return false
+	}
+
+	return ToNumber(l) == ToNumber(r)
+}
+
+func compare(left, right interface{}) (int, bool) {
+	l := normalize(left)
+	r := normalize(right)
+
+	if ls, ok := l.(string); ok {
+		if rs, ok := r.(string); ok {
+			return strings.Compare(strings.ToLower(ls), strings.ToLower(rs)), true
+		}
+	}
+
+	if isComposite(l) || isComposite(r) {
+		return 0, false
+	}
+
+	lf := ToNumber(l)
+	rf := ToNumber(r)
+	if math.IsNaN(lf) || math.IsNaN(rf) {
+		return 0, false
+	}
+
+	switch {
+	case lf < rf:
+		return -1, true
+	case lf > rf:
+		return 1, true
+	}
+
+	return 0, true
+}
+
+func isComposite(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,160 @@
+package exprs_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/jolt9dev/jolt9/pkg/exprs"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEvaluate(t *testing.T) {
+	inventory := &configs.InventorySection{}
+	inventory.Set("node1", configs.InventoryItem{
+		Host:  "10.0.0.10",
+		Facts: map[string]interface{}{"os": map[string]interface{}{"family": "debian"}},
+	})
+
+	scope := exprs.NewScope(&ctxs.ExecContext{
+		Env:       map[string]string{"STAGE": "prod", "REPLICAS": "3"},
+		Secrets:   map[string]string{"ACME_EMAIL": "ops@example.com"},
+		Vars:      map[string]string{"domain": "example.com"},
+		Inventory: inventory,
+	})
+
+	tests := []struct {
+		expr     string
+		expected interface{}
+	}{
+		{"secrets.ACME_EMAIL", "ops@example.com"},
+		{"env.STAGE == 'PROD'", true},
+		{"env.REPLICAS > 2", true},
+		{"env.MISSING || 'fallback'", "fallback"},
+		{"env.STAGE && vars.domain", "example.com"},
+		{"!env.MISSING", true},
+		{"inventory.node1.host", "10.0.0.10"},
+		{"inventory['node1'].facts.os.family", "debian"},
+		{"format('{0}.{1}', 'www', vars.domain)", "www.example.com"},
+		{"contains('Hello World', 'world')", true},
+		{"startsWith(vars.domain, 'example')", true},
+		{"toJSON(vars)", "{\n  \"domain\": \"example.com\"\n}"},
+		{"(1 < 2) && (2 <= 2)", true},
+		{"'it''s'", "it's"},
+	}
+
+	for _, test := range tests {
+		v, err := exprs.Evaluate(test.expr, scope)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expr, err)
+		}
+
+		assert.Equal(t, test.expected, v, test.expr)
+	}
+
+	s, err := exprs.Interpolate("https://${{ vars.domain }}:${{ 443 }}", scope)
+	assert.Nil(t, err)
+	assert.Equal(t, "https://example.com:443", s)
+
+	_, err = exprs.Evaluate("unknown(1)", scope)
+	assert.NotNil(t, err)
+
+	_, err = exprs.Evaluate("'a' }} 'b'", scope)
+	assert.NotNil(t, err)
+
+	_, err = exprs.Evaluate("env.STAGE ==", scope)
+	assert.NotNil(t, err)
+}
+
+func TestEvalTask(t *testing.T) {
+	yamlData := `
+run: echo ${{ env.NAME }}
+timeout: ${{ vars.timeout }}
+force: ${{ env.NAME == 'jolt9' }}
+env:
+  GREETING: hello ${{ env.NAME }}
+if: ${{ nope( }}
+`
+
+	task := &configs.TaskSection{}
+	if err := yaml.NewDecoder(strings.NewReader(yamlData)).Decode(task); err != nil {
+		t.Fatal(err)
+	}
+
+	scope := exprs.NewScope(&ctxs.ExecContext{
+		Env:  map[string]string{"NAME": "jolt9"},
+		Vars: map[string]string{"timeout": "30"},
+	})
+
+	if err := exprs.EvalTask(task, scope); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "echo jolt9", task.Run.Evaluated)
+	assert.Equal(t, 30, task.Timeout.Evaluated)
+	assert.True(t, task.Force.Evaluated)
+	assert.Equal(t, "hello jolt9", task.Env["GREETING"].Evaluated)
+
+	_, err := exprs.EvalCondition(task.If, scope)
+	assert.NotNil(t, err)
+
+	var evalErr *exprs.EvalError
+	assert.True(t, errors.As(err, &evalErr))
+	assert.Equal(t, 7, evalErr.Line)
+	assert.Equal(t, 5, evalErr.Column)
+
+	err = exprs.InFile(err, "jolt9.yaml")
+	assert.Equal(t, "jolt9.yaml", evalErr.File)
+	assert.True(t, strings.HasPrefix(err.Error(), "jolt9.yaml:7:5: expression "), err.Error())
+}
+
+func TestInterpolate(t *testing.T) {
+	scope := exprs.NewScope(&ctxs.ExecContext{
+		Vars: map[string]string{"domain": "example.com"},
+	})
+
+	tests := []struct {
+		template string
+		expected interface{}
+	}{
+		{"${{ format('{0}}}', vars.domain) }}", "example.com}"},
+		{"${{ 443 }}", float64(443)},
+		{"  ${{ 1 < 2 }} ", true},
+		{"a ${{ '}}' }} b ${{ vars.domain }}", "a }} b example.com"},
+		{"${{ 'x' }}${{ 'y' }}", "xy"},
+		{"no blocks }}", "no blocks }}"},
+	}
+
+	for _, test := range tests {
+		v, err := exprs.Interpolate(test.template, scope)
+		if err != nil {
+			t.Fatalf("%s: %v", test.template, err)
+		}
+
+		assert.Equal(t, test.expected, v, test.template)
+	}
+
+	_, err := exprs.Interpolate("${{ vars.domain ", scope)
+	assert.ErrorContains(t, err, "missing closing }}")
+
+	_, err = exprs.Interpolate("${{ '}} }}", scope)
+	assert.ErrorContains(t, err, "unterminated string")
+}
+
+func TestEvalJobFile(t *testing.T) {
+	job := &configs.JobSection{}
+	if err := yaml.Unmarshal([]byte("env:\n  NAME: ${{ nope( }}\n"), job); err != nil {
+		t.Fatal(err)
+	}
+
+	job.File = "deploy/jolt9.yaml"
+	err := exprs.EvalJob(job, exprs.NewScope(&ctxs.ExecContext{}))
+
+	var evalErr *exprs.EvalError
+	assert.True(t, errors.As(err, &evalErr))
+	assert.Equal(t, "deploy/jolt9.yaml", evalErr.File)
+	assert.True(t, strings.HasPrefix(err.Error(), "deploy/jolt9.yaml:2:9: "), err.Error())
+}
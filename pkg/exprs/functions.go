@@ -0,0 +1,194 @@
+package exprs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Function is a function that can be called from an expression.
+type Function func(args ...interface{}) (interface{}, error)
+
+var (
+	functions = map[string]Function{
+		"contains":   contains,
+		"startsWith": startsWith,
+		"endsWith":   endsWith,
+		"format":     format,
+		"join":       join,
+		"toJSON":     toJSON,
+		"fromJSON":   fromJSON,
+	}
+	functionsMux sync.RWMutex
+)
+
+// RegisterFunction adds or replaces a function that is available
+// to all expressions. Function names are case insensitive.
+func RegisterFunction(name string, fn Function) {
+	functionsMux.Lock()
+	defer functionsMux.Unlock()
+	functions[name] = fn
+}
+
+func lookupFunction(name string) (Function, bool) {
+	functionsMux.RLock()
+	defer functionsMux.RUnlock()
+
+	if fn, ok := functions[name]; ok {
+		return fn, true
+	}
+
+	for k, fn := range functions {
+		if strings.EqualFold(k, name) {
+			return fn, true
+		}
+	}
+
+	return nil, false
+}
+
+func expectArgs(args []interface{}, min, max int) error {
+	if len(args) < min || (max >= 0 && len(args) > max) {
+		if min == max {
+			return fmt.Errorf("expected %d argument(s), got %d", min, len(args))
+		}
+
+		return fmt.Errorf("expected between %d and %d argument(s), got %d", min, max, len(args))
+	}
+
+	return nil
+}
+
+func contains(args ...interface{}) (interface{}, error) {
+	if err := expectArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	if list, ok := normalize(args[0]).([]interface{}); ok {
+		for _, item := range list {
+			if Equal(item, args[1]) {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+
+	return strings.Contains(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+}
+
+func startsWith(args ...interface{}) (interface{}, error) {
+	if err := expectArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	return strings.HasPrefix(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+}
+
+func endsWith(args ...interface{}) (interface{}, error) {
+	if err := expectArgs(args, 2, 2); err != nil {
+		return nil, err
+	}
+
+	return strings.HasSuffix(strings.ToLower(ToString(args[0])), strings.ToLower(ToString(args[1]))), nil
+}
+
+// format replaces {0}, {1}, ... with the arguments. Braces are
+// escaped by doubling them.
+func format(args ...interface{}) (interface{}, error) {
+	if err := expectArgs(args, 1, -1); err != nil {
+		return nil, err
+	}
+
+	template := []rune(ToString(args[0]))
+	sb := strings.Builder{}
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		if c == '{' {
+			if i+1 < len(template) && template[i+1] == '{' {
+				sb.WriteRune('{')
+				i++
+				continue
+			}
+
+			end := i + 1
+			for end < len(template) && template[end] != '}' {
+				end++
+			}
+
+			if end == len(template) {
+				return nil, fmt.Errorf("unclosed { at position %d", i)
+			}
+
+			index, err := strconv.Atoi(string(template[i+1 : end]))
+			if err != nil || index < 0 || index+1 >= len(args) {
+				return nil, fmt.Errorf("invalid argument index %q", string(template[i+1:end]))
+			}
+
+			sb.WriteString(ToString(args[index+1]))
+			i = end
+			continue
+		}
+
+		if c == '}' {
+			if i+1 < len(template) && template[i+1] == '}' {
+				i++
+			}
+		}
+
+		sb.WriteRune(c)
+	}
+
+	return sb.String(), nil
+}
+
+func join(args ...interface{}) (interface{}, error) {
+	if err := expectArgs(args, 1, 2); err != nil {
+		return nil, err
+	}
+
+	sep := ","
+	if len(args) == 2 {
+		sep = ToString(args[1])
+	}
+
+	list, ok := normalize(args[0]).([]interface{})
+	if !ok {
+		return ToString(args[0]), nil
+	}
+
+	parts := make([]string, 0, len(list))
+	for _, item := range list {
+		parts = append(parts, ToString(item))
+	}
+
+	return strings.Join(parts, sep), nil
+}
+
+func toJSON(args ...interface{}) (interface{}, error) {
+	if err := expectArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	b, err := json.MarshalIndent(args[0], "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return string(b), nil
+}
+
+func fromJSON(args ...interface{}) (interface{}, error) {
+	if err := expectArgs(args, 1, 1); err != nil {
+		return nil, err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(ToString(args[0])), &v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
@@ -0,0 +1,153 @@
+package exprs
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+func (t token) is(kind tokenKind, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+var punctuation = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ".", ","}
+
+func tokenize(expr string) ([]token, error) {
+	tokens, _, err := lex(expr, false)
+	return tokens, err
+}
+
+// tokenizeBlock tokenizes the expression at the start of s, which
+// follows the `${{` of a block, up to the `}}` that closes it. A `}}`
+// inside a string does not close the block. It returns the tokens and
+// the byte offset of the closing `}}` in s.
+func tokenizeBlock(s string) ([]token, int, error) {
+	tokens, end, err := lex(s, true)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if end == -1 {
+		return nil, 0, fmt.Errorf("missing closing }} for expression")
+	}
+
+	return tokens, end, nil
+}
+
+// lex tokenizes expr, in block mode it stops at the first `}}`
+// outside of a string and returns its byte offset, otherwise -1.
+func lex(expr string, block bool) ([]token, int, error) {
+	tokens := []token{}
+	runes := []rune(expr)
+	i := 0
+
+	for i < len(runes) {
+		c := runes[i]
+
+		if block && c == '}' && i+1 < len(runes) && runes[i+1] == '}' {
+			tokens = append(tokens, token{kind: tokEOF, pos: i})
+			return tokens, len(string(runes[:i])), nil
+		}
+
+		if unicode.IsSpace(c) {
+			i++
+			continue
+		}
+
+		if c == '\'' {
+			// strings use single quotes, a quote is escaped by doubling it.
+			start := i
+			sb := strings.Builder{}
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+
+					closed = true
+					i++
+					break
+				}
+
+				sb.WriteRune(runes[i])
+				i++
+			}
+
+			if !closed {
+				return nil, -1, fmt.Errorf("unterminated string at position %d", start)
+			}
+
+			tokens = append(tokens, token{kind: tokString, value: sb.String(), pos: start})
+			continue
+		}
+
+		if unicode.IsDigit(c) || (c == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])) {
+			start := i
+			i++
+			for i < len(runes) {
+				r := runes[i]
+				if unicode.IsDigit(r) || r == '.' || r == 'e' || r == 'E' {
+					i++
+					continue
+				}
+
+				if (r == '+' || r == '-') && (runes[i-1] == 'e' || runes[i-1] == 'E') {
+					i++
+					continue
+				}
+
+				break
+			}
+
+			tokens = append(tokens, token{kind: tokNumber, value: string(runes[start:i]), pos: start})
+			continue
+		}
+
+		if unicode.IsLetter(c) || c == '_' {
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '-') {
+				i++
+			}
+
+			tokens = append(tokens, token{kind: tokIdent, value: string(runes[start:i]), pos: start})
+			continue
+		}
+
+		matched := false
+		for _, p := range punctuation {
+			if strings.HasPrefix(string(runes[i:]), p) {
+				tokens = append(tokens, token{kind: tokPunct, value: p, pos: i})
+				i += len([]rune(p))
+				matched = true
+				break
+			}
+		}
+
+		if !matched {
+			return nil, -1, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokEOF, pos: len(runes)})
+	return tokens, -1, nil
+}
@@ -0,0 +1,302 @@
+package exprs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type node interface {
+	eval(scope Scope) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+type identNode struct {
+	name string
+}
+
+type indexNode struct {
+	target node
+	index  node
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+type unaryNode struct {
+	op      string
+	operand node
+}
+
+type binaryNode struct {
+	op          string
+	left, right node
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func parse(expr string) (node, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseTokens(tokens)
+}
+
+// parseBlock parses the expression of a `${{ }}` block, s starts after
+// the `${{`. It returns the byte offset of the closing `}}` in s.
+func parseBlock(s string) (node, int, error) {
+	tokens, end, err := tokenizeBlock(s)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	n, err := parseTokens(tokens)
+	return n, end, err
+}
+
+func parseTokens(tokens []token) (node, error) {
+	p := &parser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected token %q at position %d", t.value, t.pos)
+	}
+
+	return n, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+
+	return t
+}
+
+func (p *parser) expect(value string) error {
+	t := p.next()
+	if !t.is(tokPunct, value) {
+		if t.kind == tokEOF {
+			return fmt.Errorf("expected %q but reached the end of the expression", value)
+		}
+
+		return fmt.Errorf("expected %q at position %d, got %q", value, t.pos, t.value)
+	}
+
+	return nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().is(tokPunct, "||") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: "||", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseEquality()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().is(tokPunct, "&&") {
+		p.next()
+		right, err := p.parseEquality()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: "&&", left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseEquality() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().is(tokPunct, "==") || p.peek().is(tokPunct, "!=") {
+		op := p.next().value
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		if t.kind != tokPunct || (t.value != "<" && t.value != "<=" && t.value != ">" && t.value != ">=") {
+			return left, nil
+		}
+
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		left = &binaryNode{op: t.value, left: left, right: right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().is(tokPunct, "!") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		return &unaryNode{op: "!", operand: operand}, nil
+	}
+
+	return p.parsePostfix()
+}
+
+func (p *parser) parsePostfix() (node, error) {
+	n, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t := p.peek()
+		switch {
+		case t.is(tokPunct, "."):
+			p.next()
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, fmt.Errorf("expected a property name at position %d", name.pos)
+			}
+
+			n = &indexNode{target: n, index: &literalNode{value: name.value}}
+		case t.is(tokPunct, "["):
+			p.next()
+			index, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+
+			n = &indexNode{target: n, index: index}
+		default:
+			return n, nil
+		}
+	}
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q at position %d", t.value, t.pos)
+		}
+
+		return &literalNode{value: f}, nil
+	case tokString:
+		return &literalNode{value: t.value}, nil
+	case tokIdent:
+		switch strings.ToLower(t.value) {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+
+		if p.peek().is(tokPunct, "(") {
+			p.next()
+			args := []node{}
+			if !p.peek().is(tokPunct, ")") {
+				for {
+					arg, err := p.parseOr()
+					if err != nil {
+						return nil, err
+					}
+
+					args = append(args, arg)
+					if !p.peek().is(tokPunct, ",") {
+						break
+					}
+
+					p.next()
+				}
+			}
+
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+
+			return &callNode{name: t.value, args: args}, nil
+		}
+
+		return &identNode{name: t.value}, nil
+	case tokPunct:
+		if t.value == "(" {
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+
+			return n, nil
+		}
+	case tokEOF:
+		return nil, fmt.Errorf("unexpected end of the expression")
+	}
+
+	return nil, fmt.Errorf("unexpected token %q at position %d", t.value, t.pos)
+}
@@ -0,0 +1,185 @@
+package inventory
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/os/paths"
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	setup "github.com/jolt9dev/jolt9/pkg/vm/setup"
+)
+
+// CachedFacts are the facts of a host as stored in the cache.
+type CachedFacts struct {
+	Host        string                 `json:"host"`
+	RefreshedAt time.Time              `json:"refreshed_at"`
+	Facts       map[string]interface{} `json:"facts"`
+}
+
+// FactsCache stores the facts of hosts as json files in Dir.
+type FactsCache struct {
+	Dir string
+}
+
+// NewFactsCache returns the cache of the project in the facts directory
+// under paths.AppCacheDir. Each project config file has its own
+// directory, keyed by a hash of its absolute path, so that projects
+// with hosts of the same name do not share facts.
+func NewFactsCache(project string) (*FactsCache, error) {
+	dir, err := paths.AppCacheDir(configs.AppName)
+	if err != nil {
+		return nil, err
+	}
+
+	dir = filepath.Join(dir, "facts")
+	if project != "" {
+		if abs, err := filepath.Abs(project); err == nil {
+			project = abs
+		}
+
+		sum := sha256.Sum256([]byte(project))
+		dir = filepath.Join(dir, hex.EncodeToString(sum[:8]))
+	}
+
+	return &FactsCache{Dir: dir}, nil
+}
+
+func (c *FactsCache) file(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name)
+	return filepath.Join(c.Dir, name+".json")
+}
+
+// Get returns the cached facts of the host or nil when the host has
+// no cached facts.
+func (c *FactsCache) Get(name string) (*CachedFacts, error) {
+	data, err := os.ReadFile(c.file(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	cached := &CachedFacts{}
+	if err := json.Unmarshal(data, cached); err != nil {
+		return nil, err
+	}
+
+	return cached, nil
+}
+
+func (c *FactsCache) Set(name string, facts map[string]interface{}) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(&CachedFacts{
+		Host:        name,
+		RefreshedAt: time.Now().UTC(),
+		Facts:       facts,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(c.file(name), data, 0644)
+}
+
+// Apply adds the cached facts to the hosts of the inventory. Facts
+// declared in the config take precedence over cached facts.
+func (c *FactsCache) Apply(inventory *configs.InventorySection) error {
+	for _, host := range inventory.Hosts() {
+		cached, err := c.Get(host.Name)
+		if err != nil {
+			return err
+		}
+
+		if cached == nil {
+			continue
+		}
+
+		inventory.SetFacts(host.Name, MergeFacts(cached.Facts, host.Facts))
+	}
+
+	return nil
+}
+
+// Refresh gathers the facts of host over ssh and stores them in the
+// cache. The host is resolved with the ssh_config files, see Connect.
+func (c *FactsCache) Refresh(host configs.InventoryItem, files ...string) (map[string]interface{}, error) {
+	client, err := Connect(host, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	facts, err := GatherFacts(client)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := c.Set(host.Name, facts); err != nil {
+		return nil, err
+	}
+
+	return facts, nil
+}
+
+// GatherFacts reads the os facts of the host behind client.
+func GatherFacts(client ssh.Client) (map[string]interface{}, error) {
+	info, err := setup.GetOsInfo(client)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"os": OsFacts(info)}, nil
+}
+
+// OsFacts converts the os-release info into the `facts.os` map. The
+// family is the first ID_LIKE entry or the id itself, so debian and
+// ubuntu both have the family debian.
+func OsFacts(info *setup.OsInfo) map[string]interface{} {
+	family := info.Id
+	if fields := strings.Fields(info.IdLike); len(fields) > 0 {
+		family = fields[0]
+	}
+
+	return map[string]interface{}{
+		"platform":    "linux",
+		"family":      family,
+		"id":          info.Id,
+		"version":     info.Version,
+		"version_id":  info.VersionId,
+		"codename":    info.VersionCodename,
+		"pretty_name": info.PrettyName,
+	}
+}
+
+// MergeFacts merges override into base, nested maps are merged
+// recursively.
+func MergeFacts(base, override map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range base {
+		merged[k] = v
+	}
+
+	for k, v := range override {
+		b, ok1 := merged[k].(map[string]interface{})
+		o, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			merged[k] = MergeFacts(b, o)
+			continue
+		}
+
+		merged[k] = v
+	}
+
+	return merged
+}
@@ -0,0 +1,148 @@
+package inventory_test
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/inventory"
+	setup "github.com/jolt9dev/jolt9/pkg/vm/setup"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestFactsCache(t *testing.T) {
+	cache := &inventory.FactsCache{Dir: t.TempDir()}
+
+	cached, err := cache.Get("node1")
+	assert.Nil(t, err)
+	assert.Nil(t, cached)
+
+	facts := map[string]interface{}{
+		"os": inventory.OsFacts(&setup.OsInfo{Id: "ubuntu", IdLike: "debian", VersionId: "24.04"}),
+	}
+
+	if err := cache.Set("node1", facts); err != nil {
+		t.Fatal(err)
+	}
+
+	cached, err = cache.Get("node1")
+	assert.Nil(t, err)
+	assert.Equal(t, "node1", cached.Host)
+	assert.False(t, cached.RefreshedAt.IsZero())
+
+	inv := &configs.InventorySection{}
+	inv.Set("node1", configs.InventoryItem{
+		Host:  "10.0.0.10",
+		Facts: map[string]interface{}{"os": map[string]interface{}{"platform": "bsd"}},
+	})
+	inv.Set("node2", configs.InventoryItem{Host: "10.0.0.11"})
+
+	if err := cache.Apply(inv); err != nil {
+		t.Fatal(err)
+	}
+
+	host, _ := inv.Get("node1")
+	family, _ := host.Fact("os.family")
+	platform, _ := host.Fact("os.platform")
+	assert.Equal(t, "debian", family)
+	assert.Equal(t, "bsd", platform)
+
+	hosts, err := inv.Match([]string{"facts.os.id=ubuntu"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(hosts))
+}
+
+func TestNewFactsCache(t *testing.T) {
+	a, err := inventory.NewFactsCache("/projects/a/jolt9.yaml")
+	assert.Nil(t, err)
+	b, err := inventory.NewFactsCache("/projects/b/jolt9.yaml")
+	assert.Nil(t, err)
+	again, err := inventory.NewFactsCache("/projects/a/jolt9.yaml")
+	assert.Nil(t, err)
+
+	assert.NotEqual(t, a.Dir, b.Dir)
+	assert.Equal(t, a.Dir, again.Dir)
+	assert.Equal(t, filepath.Dir(a.Dir), filepath.Dir(b.Dir))
+}
+
+func TestRefreshSshConfig(t *testing.T) {
+	// a closed port, the refresh must dial the port of the ssh_config.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	port := l.Addr().(*net.TCPAddr).Port
+	l.Close()
+
+	file := filepath.Join(t.TempDir(), "ssh_config")
+	data := fmt.Sprintf("Host node1\n  HostName 127.0.0.1\n  Port %d\n  User deploy\n", port)
+	assert.Nil(t, os.WriteFile(file, []byte(data), 0600))
+
+	cache := &inventory.FactsCache{Dir: t.TempDir()}
+	_, err = cache.Refresh(configs.InventoryItem{Name: "node1"}, file)
+	assert.ErrorContains(t, err, fmt.Sprintf("127.0.0.1:%d", port))
+}
+
+func TestResolveSshConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ssh_config")
+	content := "Host node1\n    HostName 10.0.0.10\n    User admin\n    Port 2200\n"
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := inventory.ResolveSshConfig(configs.InventoryItem{Name: "node1", User: "deploy"}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "10.0.0.10", cfg.Host)
+	assert.Equal(t, "deploy", cfg.User)
+	assert.Equal(t, 2200, cfg.Port)
+
+	// the key of the host replaces the identity files but keeps the
+	// agent of IdentityAgent.
+	dir, err := os.MkdirTemp("", "j9-agent-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "agent.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skip("unix sockets are not supported:", err)
+	}
+
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go agent.ServeAgent(agent.NewKeyring(), conn)
+		}
+	}()
+
+	content += "    IdentityFile ~/.ssh/node1\n    IdentityAgent " + socket + "\n"
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err = inventory.ResolveSshConfig(configs.InventoryItem{Name: "node1", Port: 2222, Key: "/keys/deploy"}, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "admin", cfg.User)
+	assert.Equal(t, 2222, cfg.Port)
+	assert.Equal(t, []string{"/keys/deploy"}, cfg.IdentityFiles)
+	assert.Equal(t, []string{"/keys/deploy"}, cfg.Auth.Keys)
+	assert.NotNil(t, cfg.Auth.Agent)
+}
@@ -0,0 +1,73 @@
+// Package inventory connects to the hosts of the `inventory` section
+// and keeps their facts up to date.
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+)
+
+// ResolveSshConfig returns the ssh config for host with the options
+// of the ssh_config files, ~/.ssh/config and /etc/ssh/ssh_config when
+// no files are given. The host or name of host is the alias that is
+// looked up, its user, port and key win over ssh_config.
+func ResolveSshConfig(host configs.InventoryItem, files ...string) (*ssh.Config, error) {
+	alias := host.Host
+	if alias == "" {
+		alias = host.Name
+	}
+
+	cfg, err := ssh.FindConfig(alias, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	if host.User != "" {
+		cfg.User = host.User
+	}
+
+	if host.Port != 0 {
+		cfg.Port = host.Port
+	}
+
+	// the key replaces the identity files, the agent of IdentityAgent
+	// or SSH_AUTH_SOCK is still used.
+	if host.Key != "" {
+		cfg.IdentityFiles = []string{expandHome(host.Key)}
+		if cfg.Auth == nil {
+			cfg.Auth = &ssh.Auth{}
+		}
+
+		cfg.Auth.Keys = cfg.IdentityFiles
+	}
+
+	return cfg, nil
+}
+
+// Connect opens an ssh client for host with the given ssh_config
+// files.
+func Connect(host configs.InventoryItem, files ...string) (ssh.Client, error) {
+	cfg, err := ResolveSshConfig(host, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewClient(cfg)
+}
+
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
@@ -0,0 +1,376 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/jolt9dev/jolt9/pkg/inventory"
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const DefaultParallel = 5
+
+// RemoteParams describes a script to run on an inventory host.
+type RemoteParams struct {
+	Host   configs.InventoryItem
+	Shell  string
+	Script string
+	Env    map[string]string
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// SshConfig is the ssh_config file that resolves the host, empty
+	// for ~/.ssh/config and /etc/ssh/ssh_config.
+	SshConfig string
+}
+
+// Remote runs scripts on inventory hosts.
+type Remote interface {
+	Run(ctx context.Context, params RemoteParams) (*exec.PsOutput, error)
+}
+
+// SshRemote runs scripts over ssh using pkg/ssh. Hosts are resolved
+// with the ssh_config of the params. When Auth is nil, the key of the
+// host, the IdentityFile of ssh_config or the default keys in ~/.ssh
+// are used.
+type SshRemote struct {
+	Auth    *ssh.Auth
+	HostKey gossh.HostKeyCallback
+	Timeout time.Duration
+}
+
+// HostResult is the outcome of a task on a single host.
+type HostResult struct {
+	Host   string
+	Status Status
+	Output *exec.PsOutput
+	Err    error
+}
+
+func (r *SshRemote) Run(ctx context.Context, params RemoteParams) (*exec.PsOutput, error) {
+	cfg, err := inventory.ResolveSshConfig(params.Host, params.SshConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Auth != nil {
+		cfg.Auth = r.Auth
+	}
+
+	if r.Timeout != 0 {
+		cfg.Timeout = r.Timeout
+	}
+
+	if r.HostKey != nil {
+		cfg.HostKey = r.HostKey
+	}
+
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	command := remoteCommand(params.Shell, params.Script, params.Env)
+	out := &exec.PsOutput{
+		FileName:  cfg.Host,
+		Args:      []string{command},
+		StartedAt: time.Now().UTC(),
+	}
+
+	stdout, stderr, stdin, err := client.Start(command)
+	if err != nil {
+		out.EndedAt = time.Now().UTC()
+		out.Code = 1
+		return out, err
+	}
+
+	stdin.Close()
+
+	var outBuf, errBuf bytes.Buffer
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(tee(params.Stdout, &outBuf), stdout)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(tee(params.Stderr, &errBuf), stderr)
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		wg.Wait()
+		done <- client.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// closing the connection ends the copies and Wait, the buffers
+		// are only read once they are done.
+		client.StopPersistentConn()
+		<-done
+		err = ctx.Err()
+	}
+
+	out.EndedAt = time.Now().UTC()
+	out.Stdout = outBuf.Bytes()
+	out.Stderr = errBuf.Bytes()
+	if err != nil {
+		out.Code = 1
+		var exitErr *gossh.ExitError
+		if errors.As(err, &exitErr) {
+			out.Code = exitErr.ExitStatus()
+		}
+	}
+
+	return out, err
+}
+
+// remoteCommand exports env and runs script with the remote shell.
+func remoteCommand(shell, script string, vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	sb := strings.Builder{}
+	for _, k := range keys {
+		sb.WriteString(fmt.Sprintf("export %s=%s\n", k, shellQuote(vars[k])))
+	}
+
+	sb.WriteString(script)
+
+	if shell == "" {
+		shell = "sh"
+	}
+
+	flags := "-e -c"
+	if filepath.Base(shell) == "bash" {
+		flags = "-eo pipefail -c"
+	}
+
+	return fmt.Sprintf("%s %s %s", shell, flags, shellQuote(sb.String()))
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+func tee(w io.Writer, buf *bytes.Buffer) io.Writer {
+	if w == nil {
+		return buf
+	}
+
+	return io.MultiWriter(w, buf)
+}
+
+// prefixWriter writes each line prefixed with the host name. The
+// mutex is shared between hosts so that lines don't interleave.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mux    *sync.Mutex
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, prefix string, mux *sync.Mutex) *prefixWriter {
+	return &prefixWriter{w: w, prefix: prefix, mux: mux}
+}
+
+func (p *prefixWriter) Write(b []byte) (int, error) {
+	p.buf = append(p.buf, b...)
+	for {
+		i := bytes.IndexByte(p.buf, '\n')
+		if i == -1 {
+			break
+		}
+
+		if err := p.writeLine(p.buf[:i+1]); err != nil {
+			return 0, err
+		}
+
+		p.buf = p.buf[i+1:]
+	}
+
+	return len(b), nil
+}
+
+// Flush writes any remaining partial line.
+func (p *prefixWriter) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+
+	line := append(p.buf, '\n')
+	p.buf = nil
+	return p.writeLine(line)
+}
+
+func (p *prefixWriter) writeLine(line []byte) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	_, err := fmt.Fprintf(p.w, "[%s] %s", p.prefix, line)
+	return err
+}
+
+// executeRemote runs the script of task on every inventory host that
+// matches targets. Hosts only receive the env declared by the job and
+// task, not the local environment, plus JOLT9_HOST and JOLT9_HOST_NAME.
+func (r *Runner) executeRemote(ctx context.Context, task *configs.TaskSection, targets configs.Targets, vars map[string]string, ec *ctxs.ExecContext) (*exec.PsOutput, []*HostResult, error) {
+	if task.Use != "" {
+		return nil, nil, fmt.Errorf("task %q: use is not supported on inventory hosts", taskName(task))
+	}
+
+	if task.Run == nil || strings.TrimSpace(task.Run.Evaluated) == "" {
+		return nil, nil, fmt.Errorf("task %q must define run", taskName(task))
+	}
+
+	if ec == nil || ec.Inventory == nil {
+		return nil, nil, fmt.Errorf("task %q targets %v but no inventory is configured", taskName(task), []string(targets))
+	}
+
+	hosts, err := ec.Inventory.Match(targets)
+	if err != nil {
+		return nil, nil, fmt.Errorf("task %q: %w", taskName(task), err)
+	}
+
+	remote := r.Remote
+	if remote == nil {
+		remote = &SshRemote{}
+	}
+
+	parallel := r.Parallel
+	if parallel <= 0 {
+		parallel = DefaultParallel
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	mux := &sync.Mutex{}
+	sem := make(chan struct{}, parallel)
+	results := make([]*HostResult, len(hosts))
+	var wg sync.WaitGroup
+
+	for i, host := range hosts {
+		res := &HostResult{Host: host.Name}
+		results[i] = res
+
+		// hosts start in inventory order, at most parallel at a time.
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+
+		if ctx.Err() != nil {
+			res.Status = StatusCancelled
+			res.Err = ctx.Err()
+			continue
+		}
+
+		wg.Add(1)
+		go func(res *HostResult, host configs.InventoryItem) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			hostEnv := copyMap(vars)
+			hostEnv["JOLT9_HOST"] = host.Host
+			if hostEnv["JOLT9_HOST"] == "" {
+				hostEnv["JOLT9_HOST"] = host.Name
+			}
+			hostEnv["JOLT9_HOST_NAME"] = host.Name
+
+			stdout := newPrefixWriter(r.Stdout, host.Name, mux)
+			stderr := newPrefixWriter(r.Stderr, host.Name, mux)
+			out, err := remote.Run(ctx, RemoteParams{
+				Host:      host,
+				Shell:     task.Shell,
+				Script:    task.Run.Evaluated,
+				Env:       hostEnv,
+				Stdout:    stdout,
+				Stderr:    stderr,
+				SshConfig: ec.SshConfig,
+			})
+			stdout.Flush()
+			stderr.Flush()
+
+			res.Output = out
+			res.Status = StatusSuccess
+			if err != nil {
+				res.Err = err
+				res.Status = StatusFailure
+				if errors.Is(err, context.Canceled) {
+					res.Status = StatusCancelled
+				}
+
+				if r.FailFast {
+					cancel()
+				}
+			}
+		}(res, host)
+	}
+
+	wg.Wait()
+	return aggregate(results)
+}
+
+// aggregate combines the host results into a single output. The code
+// is the highest exit code of all hosts.
+func aggregate(results []*HostResult) (*exec.PsOutput, []*HostResult, error) {
+	out := &exec.PsOutput{}
+	failed := []string{}
+	var stdout, stderr bytes.Buffer
+
+	for _, res := range results {
+		if res.Err != nil {
+			failed = append(failed, res.Host)
+			if out.Code == 0 {
+				out.Code = 1
+			}
+		}
+
+		o := res.Output
+		if o == nil {
+			continue
+		}
+
+		if o.Code > out.Code {
+			out.Code = o.Code
+		}
+
+		if out.StartedAt.IsZero() || o.StartedAt.Before(out.StartedAt) {
+			out.StartedAt = o.StartedAt
+		}
+
+		if o.EndedAt.After(out.EndedAt) {
+			out.EndedAt = o.EndedAt
+		}
+
+		stdout.Write(o.Stdout)
+		stderr.Write(o.Stderr)
+	}
+
+	out.Stdout = stdout.Bytes()
+	out.Stderr = stderr.Bytes()
+
+	if len(failed) > 0 {
+		return out, results, fmt.Errorf("failed on %d of %d hosts: %s", len(failed), len(results), strings.Join(failed, ", "))
+	}
+
+	return out, results, nil
+}
@@ -0,0 +1,209 @@
+package jobs_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/jolt9dev/jolt9/pkg/jobs"
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeRemote struct {
+	mux     sync.Mutex
+	envs    map[string]map[string]string
+	fail    map[string]int
+	delay   time.Duration
+	running int
+	peak    int
+}
+
+func (f *fakeRemote) Run(ctx context.Context, params jobs.RemoteParams) (*exec.PsOutput, error) {
+	f.mux.Lock()
+	f.envs[params.Host.Name] = params.Env
+	f.running++
+	if f.running > f.peak {
+		f.peak = f.running
+	}
+	f.mux.Unlock()
+
+	defer func() {
+		f.mux.Lock()
+		f.running--
+		f.mux.Unlock()
+	}()
+
+	out := &exec.PsOutput{StartedAt: time.Now().UTC()}
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		out.EndedAt = time.Now().UTC()
+		out.Code = 1
+		return out, ctx.Err()
+	}
+
+	line := fmt.Sprintf("hello from %s\n", params.Env["JOLT9_HOST"])
+	params.Stdout.Write([]byte(line))
+	out.Stdout = []byte(line)
+	out.EndedAt = time.Now().UTC()
+	if code, ok := f.fail[params.Host.Name]; ok {
+		out.Code = code
+		return out, fmt.Errorf("exit status %d", code)
+	}
+
+	return out, nil
+}
+
+const remoteConfig = `
+inventory:
+  web-1: 10.0.0.1
+  web-2: 10.0.0.2
+  db-1: 10.0.0.3
+jobs:
+  deploy:
+    on: web-*
+    env:
+      NAME: jolt9
+    tasks:
+      - run: echo "hello"
+      - run: echo "db"
+        on: [db-1]
+`
+
+func newRemoteRunner(t *testing.T, remote *fakeRemote) (*jobs.Runner, *ctxs.ExecContext, *bytes.Buffer) {
+	cfg, err := configs.Parse([]byte(remoteConfig), "jolt9.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	runner := jobs.NewRunner(&cfg.Jobs)
+	runner.Remote = remote
+	runner.Stdout = out
+	runner.Stderr = out
+	return runner, &ctxs.ExecContext{
+		Env:       map[string]string{"SECRET_LOCAL": "x"},
+		Inventory: &cfg.Inventory,
+	}, out
+}
+
+func TestRunJobOnHosts(t *testing.T) {
+	remote := &fakeRemote{envs: map[string]map[string]string{}}
+	runner, ctx, out := newRemoteRunner(t, remote)
+
+	result, err := runner.Run("deploy", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusSuccess, result.Status)
+	assert.Equal(t, 2, len(result.Tasks[0].Hosts))
+	assert.Equal(t, "web-1", result.Tasks[0].Hosts[0].Host)
+	assert.Equal(t, 1, len(result.Tasks[1].Hosts))
+	assert.Equal(t, "db-1", result.Tasks[1].Hosts[0].Host)
+
+	assert.Equal(t, "jolt9", remote.envs["web-1"]["NAME"])
+	assert.Equal(t, "10.0.0.2", remote.envs["web-2"]["JOLT9_HOST"])
+	assert.Equal(t, "web-2", remote.envs["web-2"]["JOLT9_HOST_NAME"])
+	assert.NotContains(t, remote.envs["web-1"], "SECRET_LOCAL")
+
+	assert.Contains(t, out.String(), "[web-1] hello from 10.0.0.1\n")
+	assert.Contains(t, out.String(), "[db-1] hello from 10.0.0.3\n")
+	assert.Equal(t, 2, strings.Count(string(result.Tasks[0].Output.Stdout), "hello from"))
+}
+
+func TestRunJobOnHostsFailure(t *testing.T) {
+	remote := &fakeRemote{
+		envs:  map[string]map[string]string{},
+		fail:  map[string]int{"web-1": 4},
+		delay: 50 * time.Millisecond,
+	}
+	runner, ctx, _ := newRemoteRunner(t, remote)
+	runner.Parallel = 1
+
+	result, err := runner.Run("deploy", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task := result.Tasks[0]
+	assert.Equal(t, jobs.StatusFailure, result.Status)
+	assert.Equal(t, 4, task.Code())
+	assert.Equal(t, jobs.StatusFailure, task.Hosts[0].Status)
+	assert.Equal(t, jobs.StatusSuccess, task.Hosts[1].Status)
+	assert.Contains(t, task.Err.Error(), "web-1")
+	assert.Equal(t, 1, remote.peak)
+	assert.Equal(t, jobs.StatusSkipped, result.Tasks[1].Status)
+
+	remote = &fakeRemote{
+		envs:  map[string]map[string]string{},
+		fail:  map[string]int{"web-1": 1},
+		delay: 50 * time.Millisecond,
+	}
+	runner, ctx, _ = newRemoteRunner(t, remote)
+	runner.Parallel = 1
+	runner.FailFast = true
+
+	result, err = runner.Run("deploy", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusCancelled, result.Tasks[0].Hosts[1].Status)
+}
+
+func TestRunJobOnHostsWithoutInventory(t *testing.T) {
+	remote := &fakeRemote{envs: map[string]map[string]string{}}
+	runner, _, _ := newRemoteRunner(t, remote)
+
+	result, err := runner.Run("deploy", &ctxs.ExecContext{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusFailure, result.Status)
+	assert.Contains(t, result.Tasks[0].Err.Error(), "no inventory")
+}
+
+func TestRunJobOnContextServers(t *testing.T) {
+	cfg, err := configs.Parse([]byte(remoteConfig+`
+contexts:
+  canary:
+    servers: [web-2]
+`), "jolt9.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rt, err := ctxs.Resolve(cfg, "canary")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &fakeRemote{envs: map[string]map[string]string{}}
+	runner := jobs.NewRunner(&rt.Jobs)
+	runner.Remote = remote
+	runner.Stdout = &bytes.Buffer{}
+	runner.Stderr = &bytes.Buffer{}
+
+	result, err := runner.Run("deploy", rt.ExecContext(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, len(result.Tasks[0].Hosts))
+	assert.Equal(t, "web-2", result.Tasks[0].Hosts[0].Host)
+	assert.Equal(t, 1, len(remote.envs))
+	assert.Contains(t, remote.envs, "web-2")
+
+	// db-1 is not a server of the context.
+	assert.Equal(t, jobs.StatusFailure, result.Tasks[1].Status)
+	assert.Contains(t, result.Tasks[1].Err.Error(), "no inventory hosts match")
+}
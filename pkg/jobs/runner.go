@@ -0,0 +1,554 @@
+// Package jobs runs the jobs and tasks defined in the `jobs`
+// section of a jolt9.yaml file.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/actions"
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/jolt9dev/jolt9/pkg/exprs"
+	"github.com/jolt9dev/jolt9/pkg/os/env"
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+)
+
+type Status string
+
+const (
+	StatusSuccess   Status = "success"
+	StatusFailure   Status = "failure"
+	StatusSkipped   Status = "skipped"
+	StatusCancelled Status = "cancelled"
+)
+
+var ErrJobNotFound = errors.New("job not found")
+
+type TaskResult struct {
+	Id     string
+	Name   string
+	Status Status
+	Forced bool
+	Output *exec.PsOutput
+	Err    error
+
+	// Hosts holds the per host results of tasks that ran `on`
+	// inventory hosts. Output is then the aggregate of all hosts.
+	Hosts []*HostResult
+}
+
+// Code returns the exit code of the task or -1 when the task
+// did not run.
+func (t *TaskResult) Code() int {
+	if t.Output == nil {
+		return -1
+	}
+
+	return t.Output.Code
+}
+
+func (t *TaskResult) Duration() time.Duration {
+	if t.Output == nil {
+		return 0
+	}
+
+	return t.Output.EndedAt.Sub(t.Output.StartedAt)
+}
+
+type JobResult struct {
+	Id        string
+	Status    Status
+	Tasks     []*TaskResult
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+func (j *JobResult) Duration() time.Duration {
+	return j.EndedAt.Sub(j.StartedAt)
+}
+
+type Runner struct {
+	jobs *configs.JobsSection
+
+	// Actions resolves the `use` of tasks, actions.Registry by default.
+	Actions *actions.ActionRegistry
+
+	// Dir is the working directory of tasks and the directory local
+	// actions are resolved from.
+	Dir    string
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Remote runs tasks that target inventory hosts, ssh by default.
+	Remote Remote
+
+	// Parallel is the number of hosts a task runs on at the same
+	// time, DefaultParallel when zero.
+	Parallel int
+
+	// FailFast cancels the remaining hosts of a task once a host
+	// failed.
+	FailFast bool
+}
+
+type runState struct {
+	ctx     context.Context
+	timeout int    // timeout of the job in seconds, 0 when it has none
+	file    string // config file of the running job for eval errors
+	exec    *ctxs.ExecContext
+	env     map[string]string
+	vars    map[string]string
+	on      configs.Targets
+	stack   []string
+	result  *JobResult
+	force   bool
+	failed  bool
+}
+
+func NewRunner(jobs *configs.JobsSection) *Runner {
+	return &Runner{
+		jobs:    jobs,
+		Actions: actions.Registry,
+		Remote:  &SshRemote{},
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}
+}
+
+// Run executes the named job. The returned error is only set when
+// the job could not be started, failed tasks are reported through
+// the status of the result.
+func (r *Runner) Run(name string, ctx *ctxs.ExecContext) (*JobResult, error) {
+	job, ok := r.jobs.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	if job.Id == "" {
+		job.Id = name
+	}
+
+	return r.RunJob(&job, ctx)
+}
+
+// RunJob executes the tasks of job sequentially.
+func (r *Runner) RunJob(job *configs.JobSection, ctx *ctxs.ExecContext) (*JobResult, error) {
+	if ctx == nil {
+		ctx = &ctxs.ExecContext{}
+	}
+
+	parent := ctx.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+
+	if err := r.validate(job, []string{job.Id}); err != nil {
+		return nil, err
+	}
+
+	state := &runState{
+		exec:   ctx,
+		env:    map[string]string{},
+		vars:   map[string]string{},
+		stack:  []string{job.Id},
+		file:   job.File,
+		result: &JobResult{Id: job.Id, Status: StatusSuccess, StartedAt: time.Now().UTC()},
+	}
+
+	base := ctx.Env
+	if base == nil {
+		base = env.All()
+	}
+
+	for k, v := range base {
+		state.env[k] = v
+	}
+
+	if err := r.applyJob(job, state); err != nil {
+		return nil, err
+	}
+
+	c := parent
+	if job.Timeout != nil && job.Timeout.Evaluated > 0 {
+		var cancel context.CancelFunc
+		c, cancel = context.WithTimeout(parent, time.Duration(job.Timeout.Evaluated)*time.Second)
+		defer cancel()
+		state.timeout = job.Timeout.Evaluated
+	}
+
+	state.ctx = c
+	err := r.runTasks(job.Tasks, state)
+	state.result.EndedAt = time.Now().UTC()
+	if err != nil {
+		return nil, err
+	}
+
+	return state.result, nil
+}
+
+func (r *Runner) applyJob(job *configs.JobSection, state *runState) error {
+	scope := r.scope(state)
+	if err := exprs.EvalJob(job, scope); err != nil {
+		return err
+	}
+
+	for k, v := range job.Env {
+		if v != nil {
+			state.env[k] = v.Evaluated
+			state.vars[k] = v.Evaluated
+		}
+	}
+
+	if len(job.On) > 0 {
+		state.on = job.On
+	}
+
+	if job.Force != nil && job.Force.Evaluated {
+		state.force = true
+	}
+
+	return nil
+}
+
+func (r *Runner) scope(state *runState) exprs.Scope {
+	scope := exprs.NewScope(state.exec)
+	env := map[string]interface{}{}
+	for k, v := range state.env {
+		env[k] = v
+	}
+
+	status := StatusSuccess
+	if state.failed {
+		status = StatusFailure
+	}
+
+	scope["env"] = env
+	scope["job"] = map[string]interface{}{
+		"id":     state.result.Id,
+		"status": string(status),
+	}
+
+	return scope
+}
+
+func (r *Runner) runTasks(tasks []configs.TaskDirectiveElement, state *runState) error {
+	for _, el := range tasks {
+		if el.Ref != "" {
+			if err := r.runRef(el.Ref, state); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if el.Task == nil {
+			continue
+		}
+
+		result := r.runTask(el.Task, state)
+		state.result.Tasks = append(state.result.Tasks, result)
+
+		if result.Status == StatusFailure || result.Status == StatusCancelled {
+			if result.Forced || state.force {
+				continue
+			}
+
+			state.failed = true
+			state.result.Status = result.Status
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) runRef(ref string, state *runState) error {
+	for _, id := range state.stack {
+		if id == ref {
+			return fmt.Errorf("job %q references itself through %s", ref, strings.Join(append(state.stack, ref), " -> "))
+		}
+	}
+
+	job, ok := r.jobs.Get(ref)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, ref)
+	}
+
+	env := state.env
+	vars := state.vars
+	on := state.on
+	force := state.force
+	file := state.file
+	state.env = copyMap(env)
+	state.vars = copyMap(vars)
+
+	if err := r.applyJob(&job, state); err != nil {
+		return err
+	}
+
+	state.stack = append(state.stack, ref)
+	if job.File != "" {
+		state.file = job.File
+	}
+
+	err := r.runTasks(job.Tasks, state)
+	state.stack = state.stack[:len(state.stack)-1]
+	state.env = env
+	state.vars = vars
+	state.on = on
+	state.force = force
+	state.file = file
+	return err
+}
+
+func (r *Runner) runTask(task *configs.TaskSection, state *runState) *TaskResult {
+	result := &TaskResult{Id: task.Id, Name: taskName(task), Status: StatusSuccess}
+	result.Forced = task.Force != nil && task.Force.Evaluated
+
+	if state.ctx.Err() != nil {
+		result.Status = StatusCancelled
+		result.Err = state.ctx.Err()
+		return result
+	}
+
+	// once a task failed, only tasks with an explicit condition run
+	// so that `if: job.status == 'failure'` can be used for cleanup.
+	if state.failed && task.If == nil {
+		result.Status = StatusSkipped
+		return result
+	}
+
+	scope := r.scope(state)
+	ok, err := exprs.EvalCondition(task.If, scope)
+	if err != nil {
+		return fail(result, exprs.InFile(err, state.file))
+	}
+
+	if !ok {
+		result.Status = StatusSkipped
+		return result
+	}
+
+	env := copyMap(state.env)
+	vars := copyMap(state.vars)
+	for k, v := range task.Env {
+		if err := exprs.EvalString(v, scope); err != nil {
+			return fail(result, exprs.InFile(err, state.file))
+		}
+
+		if v != nil {
+			env[k] = v.Evaluated
+			vars[k] = v.Evaluated
+		}
+	}
+
+	scope["env"] = mapToScope(env)
+	if err := exprs.EvalTask(task, scope); err != nil {
+		return fail(result, exprs.InFile(err, state.file))
+	}
+
+	result.Forced = task.Force != nil && task.Force.Evaluated
+
+	ctx := state.ctx
+	taskTimeout := 0
+	if task.Timeout != nil && task.Timeout.Evaluated > 0 {
+		taskTimeout = task.Timeout.Evaluated
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(taskTimeout)*time.Second)
+		defer cancel()
+	}
+
+	on := task.On
+	if len(on) == 0 {
+		on = state.on
+	}
+
+	fmt.Fprintf(r.Stdout, "==> %s\n", result.Name)
+	var out *exec.PsOutput
+	if len(on) > 0 {
+		out, result.Hosts, err = r.executeRemote(ctx, task, on, vars, state.exec)
+	} else {
+		out, err = r.execute(ctx, task, env)
+	}
+
+	result.Output = out
+	if err != nil {
+		result.Err = err
+		result.Status = StatusFailure
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			switch {
+			case state.timeout > 0 && state.ctx.Err() != nil:
+				// the deadline of the job passed before the one of the task.
+				result.Err = fmt.Errorf("task %q timed out after the job timeout of %ds", result.Name, state.timeout)
+			case taskTimeout > 0:
+				result.Err = fmt.Errorf("task %q timed out after %ds", result.Name, taskTimeout)
+			default:
+				result.Err = fmt.Errorf("task %q timed out: %w", result.Name, err)
+			}
+		} else if errors.Is(ctx.Err(), context.Canceled) {
+			result.Status = StatusCancelled
+		}
+	}
+
+	return result
+}
+
+func (r *Runner) execute(ctx context.Context, task *configs.TaskSection, env map[string]string) (*exec.PsOutput, error) {
+	actx := &actions.Context{
+		Context: ctx,
+		Env:     env,
+		Dir:     r.Dir,
+		Stdout:  r.Stdout,
+		Stderr:  r.Stderr,
+	}
+
+	if task.Use == "" {
+		if task.Run == nil || strings.TrimSpace(task.Run.Evaluated) == "" {
+			return nil, fmt.Errorf("task %q must define run or use", taskName(task))
+		}
+
+		return actions.RunScript(ctx, actions.ScriptParams{
+			Shell:  task.Shell,
+			Script: task.Run.Evaluated,
+			Env:    env,
+			Dir:    r.Dir,
+			Stdout: r.Stdout,
+			Stderr: r.Stderr,
+		})
+	}
+
+	action, err := r.Actions.Resolve(task.Use, r.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	with := map[string]string{}
+	for k, v := range task.With {
+		if v != nil {
+			with[k] = v.Evaluated
+		}
+	}
+
+	if err := actions.Validate(action, with, nil); err != nil {
+		return nil, err
+	}
+
+	actx.With = actions.WithDefaults(action, with)
+	return action.Run(actx)
+}
+
+// Validate resolves the actions used by the job and checks their
+// `with` inputs without running anything. RunJob validates the job
+// before the first task starts.
+func (r *Runner) Validate(name string) error {
+	job, ok := r.jobs.Get(name)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	return r.validate(&job, []string{name})
+}
+
+func (r *Runner) validate(job *configs.JobSection, stack []string) error {
+	for _, el := range job.Tasks {
+		if el.Ref != "" {
+			for _, id := range stack {
+				if id == el.Ref {
+					return fmt.Errorf("job %q references itself through %s", el.Ref, strings.Join(append(stack, el.Ref), " -> "))
+				}
+			}
+
+			ref, ok := r.jobs.Get(el.Ref)
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrJobNotFound, el.Ref)
+			}
+
+			if err := r.validate(&ref, append(stack, el.Ref)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		task := el.Task
+		if task == nil || task.Use == "" {
+			continue
+		}
+
+		action, err := r.Actions.Resolve(task.Use, r.Dir)
+		if err != nil {
+			return fmt.Errorf("task %q: %w", taskName(task), err)
+		}
+
+		with := map[string]string{}
+		isExpr := map[string]bool{}
+		for k, v := range task.With {
+			if v == nil {
+				continue
+			}
+
+			with[k] = v.Raw
+			isExpr[k] = v.IsExpr()
+		}
+
+		if err := actions.Validate(action, with, isExpr); err != nil {
+			return fmt.Errorf("task %q: %w", taskName(task), err)
+		}
+	}
+
+	return nil
+}
+
+func fail(result *TaskResult, err error) *TaskResult {
+	result.Status = StatusFailure
+	result.Err = err
+	return result
+}
+
+func taskName(task *configs.TaskSection) string {
+	if task.Name != "" {
+		return task.Name
+	}
+
+	if task.Id != "" {
+		return task.Id
+	}
+
+	if task.Use != "" {
+		return task.Use
+	}
+
+	if task.Run != nil {
+		line := strings.TrimSpace(task.Run.Raw)
+		if i := strings.Index(line, "\n"); i != -1 {
+			line = line[:i]
+		}
+
+		return line
+	}
+
+	return "task"
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+
+	return c
+}
+
+func mapToScope(m map[string]string) map[string]interface{} {
+	s := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		s[k] = v
+	}
+
+	return s
+}
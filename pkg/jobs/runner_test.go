@@ -0,0 +1,199 @@
+package jobs_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/ctxs"
+	"github.com/jolt9dev/jolt9/pkg/jobs"
+	"github.com/jolt9dev/jolt9/pkg/os/exec"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRunner(t *testing.T, yamlData string) (*jobs.Runner, *bytes.Buffer) {
+	if _, ok := exec.Which("sh"); !ok {
+		t.Skip("sh not found")
+	}
+
+	cfg, err := configs.Parse([]byte(yamlData), "jolt9.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	runner := jobs.NewRunner(&cfg.Jobs)
+	runner.Stdout = out
+	runner.Stderr = out
+	return runner, out
+}
+
+func TestRunJob(t *testing.T) {
+	runner, out := newRunner(t, `
+jobs:
+  shared:
+    - run: echo "shared $NAME"
+      shell: sh
+  deploy:
+    env:
+      NAME: jolt9
+    tasks:
+      - run: echo "hello $NAME $GREETING"
+        shell: sh
+        env:
+          GREETING: ${{ vars.greeting }}
+      - shared
+      - run: exit 3
+        shell: sh
+        force: true
+      - run: echo "skipped"
+        shell: sh
+        if: ${{ env.NAME != 'jolt9' }}
+`)
+
+	result, err := runner.Run("deploy", &ctxs.ExecContext{
+		Env:  map[string]string{"PATH": "/usr/bin:/bin"},
+		Vars: map[string]string{"greeting": "hi"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusSuccess, result.Status)
+	assert.Equal(t, 4, len(result.Tasks))
+	assert.Equal(t, jobs.StatusSuccess, result.Tasks[0].Status)
+	assert.Equal(t, "hello jolt9 hi", strings.TrimSpace(string(result.Tasks[0].Output.Stdout)))
+	assert.Equal(t, "shared jolt9", strings.TrimSpace(string(result.Tasks[1].Output.Stdout)))
+	assert.Equal(t, jobs.StatusFailure, result.Tasks[2].Status)
+	assert.Equal(t, 3, result.Tasks[2].Code())
+	assert.True(t, result.Tasks[2].Forced)
+	assert.Equal(t, jobs.StatusSkipped, result.Tasks[3].Status)
+	assert.Contains(t, out.String(), "hello jolt9 hi")
+}
+
+func TestRunJobFailureAndTimeout(t *testing.T) {
+	runner, _ := newRunner(t, `
+jobs:
+  deploy:
+    - run: sleep 5
+      shell: sh
+      timeout: 1
+    - run: echo "not run"
+      shell: sh
+    - run: echo "cleanup"
+      shell: sh
+      if: job.status == 'failure'
+`)
+
+	result, err := runner.Run("deploy", &ctxs.ExecContext{
+		Env: map[string]string{"PATH": "/usr/bin:/bin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusFailure, result.Status)
+	assert.Equal(t, jobs.StatusFailure, result.Tasks[0].Status)
+	assert.Contains(t, result.Tasks[0].Err.Error(), "timed out")
+	assert.Less(t, result.Tasks[0].Duration().Seconds(), 4.0)
+	assert.Equal(t, jobs.StatusSkipped, result.Tasks[1].Status)
+	assert.Equal(t, jobs.StatusSuccess, result.Tasks[2].Status)
+}
+
+func TestRunJobTimeout(t *testing.T) {
+	runner, _ := newRunner(t, `
+jobs:
+  deploy:
+    timeout: 1
+    tasks:
+      - run: sleep 3
+        shell: sh
+      - run: sleep 3
+        shell: sh
+        timeout: 10
+`)
+
+	result, err := runner.Run("deploy", &ctxs.ExecContext{
+		Env: map[string]string{"PATH": "/usr/bin:/bin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusFailure, result.Tasks[0].Status)
+	assert.Contains(t, result.Tasks[0].Err.Error(), "timed out after the job timeout of 1s")
+	assert.Less(t, result.Tasks[0].Duration().Seconds(), 2.5)
+	assert.Equal(t, jobs.StatusCancelled, result.Tasks[1].Status)
+}
+
+func TestRunJobEvalError(t *testing.T) {
+	runner, _ := newRunner(t, `
+jobs:
+  shared:
+    - run: echo ${{ nope( }}
+      shell: sh
+  deploy:
+    - shared
+`)
+
+	result, err := runner.Run("deploy", &ctxs.ExecContext{
+		Env: map[string]string{"PATH": "/usr/bin:/bin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusFailure, result.Tasks[0].Status)
+	assert.True(t, strings.HasPrefix(result.Tasks[0].Err.Error(), "jolt9.yaml:4:12: expression "), result.Tasks[0].Err.Error())
+}
+
+func TestRunJobCycle(t *testing.T) {
+	runner, _ := newRunner(t, `
+jobs:
+  a:
+    - b
+  b:
+    - a
+`)
+
+	_, err := runner.Run("a", nil)
+	assert.NotNil(t, err)
+
+	_, err = runner.Run("missing", nil)
+	assert.ErrorIs(t, err, jobs.ErrJobNotFound)
+}
+
+func TestRunJobValidatesActions(t *testing.T) {
+	runner, _ := newRunner(t, `
+jobs:
+  deploy:
+    - run: echo "should not run"
+    - use: jolt9/wait-for-port
+      with:
+        port: http
+`)
+
+	_, err := runner.Run("deploy", nil)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `input "port" expects an int`)
+
+	runner, _ = newRunner(t, `
+jobs:
+  deploy:
+    - use: jolt9/shell
+      with:
+        shell: sh
+        script: echo "from action"
+`)
+
+	result, err := runner.Run("deploy", &ctxs.ExecContext{
+		Env: map[string]string{"PATH": "/usr/bin:/bin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, jobs.StatusSuccess, result.Status)
+	assert.Equal(t, "from action", strings.TrimSpace(string(result.Tasks[0].Output.Stdout)))
+}
@@ -2,6 +2,7 @@ package exec
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"os"
 	"os/exec"
@@ -30,6 +31,12 @@ func New(name string, args ...string) *Cmd {
 	return &Cmd{Cmd: cmd}
 }
 
+// NewContext creates a new Cmd that is killed when ctx is done.
+func NewContext(ctx context.Context, name string, args ...string) *Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	return &Cmd{Cmd: cmd}
+}
+
 func SetLigger(f func(cmd *Cmd)) {
 	logger = f
 }
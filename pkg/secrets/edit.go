@@ -0,0 +1,165 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// Masked replaces secret values in output that does not reveal them.
+const Masked = "********"
+
+// Formats are the formats that Format writes.
+var Formats = []string{"dotenv", "json", "shell"}
+
+var shellName = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// Mask returns a copy of values with every value replaced by Masked.
+func Mask(values map[string]string) map[string]string {
+	masked := make(map[string]string, len(values))
+	for k := range values {
+		masked[k] = Masked
+	}
+
+	return masked
+}
+
+// Format writes values as dotenv, json or shell exports. Shell exports
+// are single quoted and names that are not valid variable names have
+// the invalid characters replaced with `_`, e.g. prod:PG_PASSWORD is
+// exported as prod_PG_PASSWORD.
+func Format(values map[string]string, format string) ([]byte, error) {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		return append(data, '\n'), nil
+	case "shell":
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		sb := strings.Builder{}
+		for _, k := range keys {
+			quoted := "'" + strings.ReplaceAll(values[k], "'", `'\''`) + "'"
+			fmt.Fprintf(&sb, "export %s=%s\n", shellName.ReplaceAllString(k, "_"), quoted)
+		}
+
+		return []byte(sb.String()), nil
+	case "dotenv":
+		data, err := godotenv.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(data + "\n"), nil
+	}
+
+	return nil, fmt.Errorf("format must be %s, got %q", strings.Join(Formats, ", "), format)
+}
+
+// Changes are the secrets to write back to a vault after an edit.
+type Changes struct {
+	Set     map[string]string // changed and added secrets
+	Deleted []string          // removed secrets in sorted order
+}
+
+// Empty reports whether the edit changed nothing.
+func (c Changes) Empty() bool {
+	return len(c.Set) == 0 && len(c.Deleted) == 0
+}
+
+// Diff returns the changes from the current to the edited secrets.
+func Diff(current, edited map[string]string) Changes {
+	changes := Changes{Set: map[string]string{}, Deleted: []string{}}
+	for k, v := range edited {
+		if old, ok := current[k]; !ok || old != v {
+			changes.Set[k] = v
+		}
+	}
+
+	for k := range current {
+		if _, ok := edited[k]; !ok {
+			changes.Deleted = append(changes.Deleted, k)
+		}
+	}
+
+	sort.Strings(changes.Deleted)
+	return changes
+}
+
+// Edit writes values to a yaml file in a private temp directory, opens
+// it with the editor command and returns the edited values. The
+// directory is removed when the editor exits. An empty editor uses
+// Editor().
+func Edit(vault string, values map[string]string, editor string) (map[string]string, error) {
+	if editor == "" {
+		editor = Editor()
+	}
+
+	dir, err := os.MkdirTemp("", "jolt9-secrets-")
+	if err != nil {
+		return nil, err
+	}
+
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, vault+".yaml")
+	data, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	header := fmt.Sprintf("# secrets of vault %s, removed secrets are deleted from the vault.\n", vault)
+	if err := os.WriteFile(file, append([]byte(header), data...), 0600); err != nil {
+		return nil, err
+	}
+
+	args := strings.Fields(editor)
+	c := exec.Command(args[0], append(args[1:], file)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("editor %s failed, no secrets were changed: %w", args[0], err)
+	}
+
+	data, err = os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	edited := map[string]string{}
+	if err := yaml.Unmarshal(data, &edited); err != nil {
+		return nil, fmt.Errorf("invalid secrets, no secrets were changed: %w", err)
+	}
+
+	return edited, nil
+}
+
+// Editor returns $VISUAL or $EDITOR, notepad on windows and vi
+// otherwise.
+func Editor() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if v := strings.TrimSpace(os.Getenv(name)); v != "" {
+			return v
+		}
+	}
+
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+
+	return "vi"
+}
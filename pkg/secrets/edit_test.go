@@ -0,0 +1,104 @@
+package secrets_test
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/joho/godotenv"
+	"github.com/jolt9dev/jolt9/pkg/secrets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	values := map[string]string{
+		"PG_PASSWORD":     "it's $secret",
+		"prod:API_TOKEN":  "abc",
+		"MULTI_LINE":      "a\nb",
+		"name-with.chars": "x",
+	}
+
+	data, err := secrets.Format(values, "shell")
+	assert.Nil(t, err)
+	assert.Equal(t, `export MULTI_LINE='a
+b'
+export PG_PASSWORD='it'\''s $secret'
+export name_with_chars='x'
+export prod_API_TOKEN='abc'
+`, string(data))
+
+	if _, err := exec.LookPath("sh"); err == nil {
+		script := string(data) + `printf '%s|%s' "$PG_PASSWORD" "$MULTI_LINE"`
+		out, err := exec.Command("sh", "-c", script).Output()
+		assert.Nil(t, err)
+		assert.Equal(t, "it's $secret|a\nb", string(out))
+	}
+
+	data, err = secrets.Format(map[string]string{"PG_PASSWORD": values["PG_PASSWORD"], "MULTI_LINE": values["MULTI_LINE"]}, "dotenv")
+	assert.Nil(t, err)
+	parsed, err := godotenv.Unmarshal(string(data))
+	assert.Nil(t, err)
+	assert.Equal(t, "it's $secret", parsed["PG_PASSWORD"])
+	assert.Equal(t, "a\nb", parsed["MULTI_LINE"])
+
+	data, err = secrets.Format(values, "json")
+	assert.Nil(t, err)
+	decoded := map[string]string{}
+	assert.Nil(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, values, decoded)
+
+	_, err = secrets.Format(values, "toml")
+	assert.ErrorContains(t, err, "format must be dotenv, json, shell")
+
+	masked := secrets.Mask(values)
+	assert.Equal(t, len(values), len(masked))
+	assert.Equal(t, secrets.Masked, masked["PG_PASSWORD"])
+	assert.Equal(t, "abc", values["prod:API_TOKEN"])
+}
+
+func TestDiff(t *testing.T) {
+	current := map[string]string{"A": "1", "B": "2", "C": "3"}
+	changes := secrets.Diff(current, map[string]string{"A": "1", "B": "changed", "D": "new"})
+	assert.Equal(t, map[string]string{"B": "changed", "D": "new"}, changes.Set)
+	assert.Equal(t, []string{"C"}, changes.Deleted)
+	assert.False(t, changes.Empty())
+
+	assert.True(t, secrets.Diff(current, map[string]string{"A": "1", "B": "2", "C": "3"}).Empty())
+}
+
+func TestEdit(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not found")
+	}
+
+	// the editor checks the file it gets and replaces its content.
+	dir := t.TempDir()
+	editor := filepath.Join(dir, "editor")
+	assert.Nil(t, os.WriteFile(editor, []byte(`#!/bin/sh
+grep -q '^A: "1"$' "$1" || exit 3
+printf 'A: "1"\nB: changed\nD: "it'"'"'s new"\n' > "$1"
+`), 0700))
+
+	current := map[string]string{"A": "1", "B": "2", "C": "3"}
+	edited, err := secrets.Edit("default", current, editor)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"A": "1", "B": "changed", "D": "it's new"}, edited)
+
+	changes := secrets.Diff(current, edited)
+	assert.Equal(t, map[string]string{"B": "changed", "D": "it's new"}, changes.Set)
+	assert.Equal(t, []string{"C"}, changes.Deleted)
+
+	// a failing editor or invalid yaml changes nothing.
+	_, err = secrets.Edit("default", map[string]string{"A": "2"}, editor)
+	assert.ErrorContains(t, err, "no secrets were changed")
+
+	assert.Nil(t, os.WriteFile(editor, []byte("#!/bin/sh\nprintf 'A: [\\n' > \"$1\"\n"), 0700))
+	_, err = secrets.Edit("default", current, editor)
+	assert.ErrorContains(t, err, "invalid secrets")
+
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", editor+" --wait")
+	assert.Equal(t, editor+" --wait", secrets.Editor())
+}
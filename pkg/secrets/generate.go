@@ -0,0 +1,125 @@
+// Package secrets resolves the secrets that a context requires from
+// its vaults and generates the ones that are missing.
+package secrets
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+)
+
+const (
+	DefaultLength = 32
+
+	LowerChars   = "abcdefghijklmnopqrstuvwxyz"
+	UpperChars   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DigitChars   = "0123456789"
+	SpecialChars = "!@#$%^&*()-_=+[]{}:,.?"
+)
+
+// Charsets returns the character classes of item. Every class gets
+// at least one character in a generated secret. When none of lower,
+// upper and digits is enabled, all three are used. Special adds to
+// these classes, it is either the set of special characters to use or
+// `true` for SpecialChars.
+func Charsets(item configs.SecretItem) []string {
+	sets := []string{}
+	if item.Lower {
+		sets = append(sets, LowerChars)
+	}
+
+	if item.Upper {
+		sets = append(sets, UpperChars)
+	}
+
+	if item.Digits {
+		sets = append(sets, DigitChars)
+	}
+
+	if len(sets) == 0 {
+		sets = []string{LowerChars, UpperChars, DigitChars}
+	}
+
+	switch strings.ToLower(item.Special) {
+	case "", "false", "no":
+	case "true", "yes":
+		sets = append(sets, SpecialChars)
+	default:
+		sets = append(sets, item.Special)
+	}
+
+	return sets
+}
+
+// Generate creates a random secret for item using crypto/rand.
+func Generate(item configs.SecretItem) (string, error) {
+	length := item.Length
+	if length == 0 {
+		length = DefaultLength
+	}
+
+	sets := Charsets(item)
+	if length < len(sets) {
+		return "", fmt.Errorf("secret %s: length %d is less than the %d required character classes", item.Name, length, len(sets))
+	}
+
+	all := []rune(strings.Join(sets, ""))
+	secret := make([]rune, 0, length)
+
+	// one character of each class, then fill from all classes.
+	for _, set := range sets {
+		r, err := pick([]rune(set))
+		if err != nil {
+			return "", err
+		}
+
+		secret = append(secret, r)
+	}
+
+	for len(secret) < length {
+		r, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+
+		secret = append(secret, r)
+	}
+
+	// shuffle so that the class characters are not always first.
+	for i := len(secret) - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return "", err
+		}
+
+		secret[i], secret[j] = secret[j], secret[i]
+	}
+
+	return string(secret), nil
+}
+
+func pick(chars []rune) (rune, error) {
+	if len(chars) == 0 {
+		return 0, errors.New("empty character set")
+	}
+
+	i, err := randInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+
+	return chars[i], nil
+}
+
+func randInt(max int) (int, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(max)))
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n.Int64()), nil
+}
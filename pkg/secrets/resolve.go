@@ -0,0 +1,88 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+// OpenVault returns the vault client for a vault name. An empty name
+// selects the default vault.
+type OpenVault func(name string) (vaults.SecretVault, error)
+
+// Resolver reads the secrets of a context from their vaults.
+type Resolver struct {
+	Open OpenVault
+
+	// Context is passed to the vaults, nil uses context.Background().
+	Context context.Context
+
+	// Log receives a line for each generated secret. Only names are
+	// written, never values.
+	Log io.Writer
+}
+
+// Result holds the resolved secret values by name and the names of
+// the secrets that were generated.
+type Result struct {
+	Values    map[string]string
+	Generated []string
+}
+
+// Resolve reads every item from its vault. When a secret is missing
+// and the item has `gen: true`, a value is generated and written to
+// the vault with SetSecretValue, otherwise an error is returned.
+func (r *Resolver) Resolve(items []configs.SecretItem) (*Result, error) {
+	result := &Result{Values: map[string]string{}}
+	op := vaults.OperationParams{Context: r.Context}
+	for _, item := range items {
+		vault, err := r.Open(item.Vault)
+		if err != nil {
+			return nil, fmt.Errorf("secret %s: %w", item.Name, err)
+		}
+
+		key := item.VaultKey()
+		value, err := vault.GetSecretValue(key, &vaults.GetSecretValueParams{OperationParams: op})
+		if err == nil {
+			result.Values[item.Name] = value
+			continue
+		}
+
+		if !errors.Is(err, vaults.ErrSecretNotFound) {
+			return nil, fmt.Errorf("secret %s: %w", item.Name, err)
+		}
+
+		if !item.Generate {
+			return nil, fmt.Errorf("secret %s is missing from vault %s", item.Name, vaultName(item))
+		}
+
+		value, err = Generate(item)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := vault.SetSecretValue(key, value, &vaults.SetSecretValueParams{OperationParams: op}); err != nil {
+			return nil, fmt.Errorf("secret %s: %w", item.Name, err)
+		}
+
+		result.Values[item.Name] = value
+		result.Generated = append(result.Generated, item.Name)
+		if r.Log != nil {
+			fmt.Fprintf(r.Log, "generated secret %s in vault %s\n", item.Name, vaultName(item))
+		}
+	}
+
+	return result, nil
+}
+
+func vaultName(item configs.SecretItem) string {
+	if item.Vault == "" {
+		return "default"
+	}
+
+	return item.Vault
+}
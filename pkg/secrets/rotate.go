@@ -0,0 +1,99 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+// RotateHook applies the new value of a rotated secret, usually by
+// running the rotate jobs of the item. When it fails the previous
+// value of the secret is restored.
+type RotateHook func(item configs.SecretItem, value string) error
+
+// Rotator replaces secrets with newly generated values.
+type Rotator struct {
+	Open OpenVault
+
+	// Hook runs after the new value is written, it may be nil.
+	Hook RotateHook
+
+	// Context is passed to the vaults, nil uses context.Background().
+	Context context.Context
+
+	// Log receives a line for each step. Only names are written,
+	// never values.
+	Log io.Writer
+}
+
+// Rotate generates a new value for item with its generation rules and
+// writes it to the vault of item. The previous value is restored when
+// the hook fails, a secret that did not exist is deleted again. The
+// new value is returned.
+func (r *Rotator) Rotate(item configs.SecretItem) (string, error) {
+	vault, err := r.Open(item.Vault)
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %w", item.Name, err)
+	}
+
+	op := vaults.OperationParams{Context: r.Context}
+	key := item.VaultKey()
+	previous, err := vault.GetSecretValue(key, &vaults.GetSecretValueParams{OperationParams: op})
+	existed := err == nil
+	if err != nil && !errors.Is(err, vaults.ErrSecretNotFound) {
+		return "", fmt.Errorf("secret %s: %w", item.Name, err)
+	}
+
+	value, err := Generate(item)
+	if err != nil {
+		return "", err
+	}
+
+	if err := vault.SetSecretValue(key, value, &vaults.SetSecretValueParams{OperationParams: op}); err != nil {
+		return "", fmt.Errorf("secret %s: %w", item.Name, err)
+	}
+
+	r.logf("rotated secret %s in vault %s\n", item.Name, vaultName(item))
+	if r.Hook == nil {
+		return value, nil
+	}
+
+	hookErr := r.Hook(item, value)
+	if hookErr == nil {
+		return value, nil
+	}
+
+	// the restore runs even when the context was cancelled during the
+	// hook, the secret must not keep a value that was not applied.
+	restore := vaults.OperationParams{Context: context.WithoutCancel(r.context())}
+	if existed {
+		err = vault.SetSecretValue(key, previous, &vaults.SetSecretValueParams{OperationParams: restore})
+	} else {
+		err = vault.DeleteSecret(key, &vaults.DeleteSecretParams{OperationParams: restore})
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("secret %s: rotation failed: %w, restoring the previous value failed as well: %w", item.Name, hookErr, err)
+	}
+
+	r.logf("restored the previous value of secret %s\n", item.Name)
+	return "", fmt.Errorf("secret %s: rotation failed, the previous value was restored: %w", item.Name, hookErr)
+}
+
+func (r *Rotator) context() context.Context {
+	if r.Context == nil {
+		return context.Background()
+	}
+
+	return r.Context
+}
+
+func (r *Rotator) logf(format string, args ...interface{}) {
+	if r.Log != nil {
+		fmt.Fprintf(r.Log, format, args...)
+	}
+}
@@ -0,0 +1,156 @@
+package secrets_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/secrets"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+type memoryVault struct {
+	vaults.SecretVault
+	data map[string]string
+}
+
+func (m *memoryVault) GetSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
+	if v, ok := m.data[key]; ok {
+		return v, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+}
+
+func (m *memoryVault) SetSecretValue(key, value string, params *vaults.SetSecretValueParams) error {
+	m.data[key] = value
+	return nil
+}
+
+func (m *memoryVault) DeleteSecret(key string, params *vaults.DeleteSecretParams) error {
+	delete(m.data, key)
+	return nil
+}
+
+func TestGenerate(t *testing.T) {
+	secret, err := secrets.Generate(configs.SecretItem{Name: "a"})
+	assert.Nil(t, err)
+	assert.Equal(t, secrets.DefaultLength, len(secret))
+	assert.True(t, strings.ContainsAny(secret, secrets.LowerChars))
+	assert.True(t, strings.ContainsAny(secret, secrets.UpperChars))
+	assert.True(t, strings.ContainsAny(secret, secrets.DigitChars))
+
+	for i := 0; i < 20; i++ {
+		secret, err = secrets.Generate(configs.SecretItem{Name: "b", Length: 4, Digits: true, Special: "-_"})
+		assert.Nil(t, err)
+		assert.Equal(t, 4, len(secret))
+		assert.True(t, strings.ContainsAny(secret, secrets.DigitChars))
+		assert.True(t, strings.ContainsAny(secret, "-_"))
+		assert.False(t, strings.ContainsAny(secret, secrets.LowerChars+secrets.UpperChars))
+	}
+
+	// special adds to the default classes.
+	for i := 0; i < 20; i++ {
+		secret, err = secrets.Generate(configs.SecretItem{Name: "d", Length: 8, Special: "true"})
+		assert.Nil(t, err)
+		assert.True(t, strings.ContainsAny(secret, secrets.LowerChars))
+		assert.True(t, strings.ContainsAny(secret, secrets.UpperChars))
+		assert.True(t, strings.ContainsAny(secret, secrets.DigitChars))
+		assert.True(t, strings.ContainsAny(secret, secrets.SpecialChars))
+	}
+
+	assert.Equal(t, []string{secrets.DigitChars}, secrets.Charsets(configs.SecretItem{Digits: true}))
+
+	other, _ := secrets.Generate(configs.SecretItem{Name: "a"})
+	first, _ := secrets.Generate(configs.SecretItem{Name: "a"})
+	assert.NotEqual(t, first, other)
+
+	_, err = secrets.Generate(configs.SecretItem{Name: "c", Length: 2, Lower: true, Upper: true, Digits: true})
+	assert.NotNil(t, err)
+}
+
+func TestResolve(t *testing.T) {
+	var items []configs.SecretItem
+	err := yaml.Unmarshal([]byte(`
+- ACME_EMAIL
+- name: PG_PASSWORD
+  key: pg-password
+  gen: true
+  length: 16
+  special: true
+`), &items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, items[1].Generate)
+	assert.Equal(t, "true", items[1].Special)
+
+	vault := &memoryVault{data: map[string]string{"ACME_EMAIL": "ops@example.com"}}
+	log := &bytes.Buffer{}
+	resolver := &secrets.Resolver{
+		Open: func(name string) (vaults.SecretVault, error) { return vault, nil },
+		Log:  log,
+	}
+
+	result, err := resolver.Resolve(items)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	generated := vault.data["pg-password"]
+	assert.Equal(t, 16, len(generated))
+	assert.Equal(t, generated, result.Values["PG_PASSWORD"])
+	assert.Equal(t, "ops@example.com", result.Values["ACME_EMAIL"])
+	assert.Equal(t, []string{"PG_PASSWORD"}, result.Generated)
+	assert.Contains(t, log.String(), "PG_PASSWORD")
+	assert.NotContains(t, log.String(), generated)
+
+	// the stored value is reused on the next run.
+	result, err = resolver.Resolve(items)
+	assert.Nil(t, err)
+	assert.Equal(t, generated, result.Values["PG_PASSWORD"])
+	assert.Empty(t, result.Generated)
+
+	delete(vault.data, "ACME_EMAIL")
+	_, err = resolver.Resolve(items)
+	assert.Contains(t, err.Error(), "ACME_EMAIL is missing")
+}
+
+func TestRotate(t *testing.T) {
+	vault := &memoryVault{data: map[string]string{"pg-password": "old"}}
+	item := configs.SecretItem{Name: "PG_PASSWORD", Key: "pg-password", Length: 12, Rotate: []string{"restart"}}
+	var applied string
+	rotator := &secrets.Rotator{
+		Open: func(name string) (vaults.SecretVault, error) { return vault, nil },
+		Hook: func(item configs.SecretItem, value string) error {
+			applied = value
+			return nil
+		},
+	}
+
+	value, err := rotator.Rotate(item)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, len(value))
+	assert.Equal(t, value, applied)
+	assert.Equal(t, value, vault.data["pg-password"])
+
+	// a failed hook restores the previous value.
+	rotator.Hook = func(item configs.SecretItem, value string) error {
+		return fmt.Errorf("restart failed")
+	}
+
+	_, err = rotator.Rotate(item)
+	assert.ErrorContains(t, err, "previous value was restored: restart failed")
+	assert.Equal(t, value, vault.data["pg-password"])
+
+	// a secret that did not exist is deleted again.
+	_, err = rotator.Rotate(configs.SecretItem{Name: "NEW"})
+	assert.NotNil(t, err)
+	_, ok := vault.data["NEW"]
+	assert.False(t, ok)
+}
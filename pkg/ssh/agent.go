@@ -0,0 +1,222 @@
+package ssh
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	terminal "golang.org/x/term"
+)
+
+// agents holds the agents of DialAgent by socket, the connection to
+// an agent stays open as signers use it.
+var (
+	agents    = map[string]agent.ExtendedAgent{}
+	agentsMux sync.Mutex
+)
+
+// DialAgent returns the ssh-agent listening on socket, SSH_AUTH_SOCK
+// when socket is empty. Agents are dialed once and then shared.
+func DialAgent(socket string) (agent.ExtendedAgent, error) {
+	if socket == "" {
+		socket = os.Getenv("SSH_AUTH_SOCK")
+	}
+
+	if socket == "" {
+		return nil, errors.New("no ssh-agent, SSH_AUTH_SOCK is not set")
+	}
+
+	agentsMux.Lock()
+	defer agentsMux.Unlock()
+	if a, ok := agents[socket]; ok {
+		return a, nil
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent %s: %w", socket, err)
+	}
+
+	a := agent.NewClient(conn)
+	agents[socket] = a
+	return a, nil
+}
+
+// NewPassphrasePrompt returns a PassphraseCallback that asks for the
+// passphrase of a key on the terminal. Passphrases are asked once per
+// key, hosts that connect in parallel wait for the prompt.
+func NewPassphrasePrompt() func(key string) ([]byte, error) {
+	var mux sync.Mutex
+	cache := map[string][]byte{}
+	return func(key string) ([]byte, error) {
+		mux.Lock()
+		defer mux.Unlock()
+		if p, ok := cache[key]; ok {
+			return p, nil
+		}
+
+		fd := int(os.Stdin.Fd())
+		if !terminal.IsTerminal(fd) {
+			return nil, fmt.Errorf("key %s needs a passphrase and stdin is not a terminal", key)
+		}
+
+		fmt.Fprintf(os.Stderr, "Enter passphrase for key %s: ", key)
+		p, err := terminal.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return nil, err
+		}
+
+		cache[key] = p
+		return p, nil
+	}
+}
+
+// signers returns the signers of the keys, raw keys and key pairs of
+// auth. Encrypted keys are decrypted with the PassphraseCallback, they
+// are skipped without one when an agent may hold them.
+func (auth *Auth) signers() ([]ssh.Signer, error) {
+	signers := []ssh.Signer{}
+	add := func(name string, key []byte) error {
+		signer, err := ssh.ParsePrivateKey(key)
+		var missing *ssh.PassphraseMissingError
+		if errors.As(err, &missing) {
+			if auth.PassphraseCallback == nil {
+				if auth.Agent != nil {
+					return nil
+				}
+
+				return fmt.Errorf("key %s is encrypted and there is no passphrase callback", name)
+			}
+
+			passphrase, perr := auth.PassphraseCallback(name)
+			if perr != nil {
+				return perr
+			}
+
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(key, passphrase)
+		}
+
+		if err != nil {
+			return fmt.Errorf("key %s: %w", name, err)
+		}
+
+		signers = append(signers, signer)
+		return nil
+	}
+
+	for _, k := range auth.Keys {
+		key, err := os.ReadFile(k)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := add(k, key); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, key := range auth.RawKeys {
+		if err := add(fmt.Sprintf("raw key %d", i+1), key); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, keypair := range auth.KeyPairs {
+		signer, err := keypair.getSigner()
+		if err != nil {
+			return nil, err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	return signers, nil
+}
+
+// identities returns the public keys of the key files of auth, read
+// from the `.pub` file next to an encrypted key.
+func (auth *Auth) identities(signers []ssh.Signer) []ssh.PublicKey {
+	keys := []ssh.PublicKey{}
+	for _, s := range signers {
+		keys = append(keys, s.PublicKey())
+	}
+
+	for _, k := range auth.Keys {
+		data, err := os.ReadFile(k + ".pub")
+		if err != nil {
+			continue
+		}
+
+		if key, _, _, _, err := ssh.ParseAuthorizedKey(data); err == nil {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// publicKeys returns the auth method that offers the keys of the agent
+// and of auth. The go client only tries one method of each type, so
+// all keys are offered by a single method.
+func (auth *Auth) publicKeys() (ssh.AuthMethod, error) {
+	signers, err := auth.signers()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(signers) == 0 && auth.Agent == nil && auth.KeyPairsCallback == nil {
+		return nil, nil
+	}
+
+	return ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
+		all := []ssh.Signer{}
+		if auth.Agent != nil {
+			agentSigners, err := auth.Agent.Signers()
+			if err != nil {
+				return nil, fmt.Errorf("ssh-agent: %w", err)
+			}
+
+			identities := auth.identities(signers)
+			for _, s := range agentSigners {
+				if !auth.IdentitiesOnly || containsKey(identities, s.PublicKey()) {
+					all = append(all, s)
+				}
+			}
+		}
+
+		all = append(all, signers...)
+		if auth.KeyPairsCallback != nil {
+			keypairs, err := auth.KeyPairsCallback()
+			if err != nil {
+				return nil, err
+			}
+
+			for _, keypair := range keypairs {
+				signer, err := keypair.getSigner()
+				if err != nil {
+					return nil, err
+				}
+
+				all = append(all, signer)
+			}
+		}
+
+		return all, nil
+	}), nil
+}
+
+func containsKey(keys []ssh.PublicKey, key ssh.PublicKey) bool {
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+
+	return false
+}
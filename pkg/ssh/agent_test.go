@@ -0,0 +1,130 @@
+package ssh_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func newPrivateKey(t *testing.T) (ed25519.PrivateKey, gossh.PublicKey) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return priv, signer.PublicKey()
+}
+
+func testConfig(server *testServer, auth *ssh.Auth) *ssh.Config {
+	return &ssh.Config{
+		User:       "deploy",
+		Host:       server.Host,
+		Port:       server.Port,
+		Auth:       auth,
+		KnownHosts: &ssh.KnownHosts{Mode: ssh.HostKeyOff},
+	}
+}
+
+func TestAgentAuth(t *testing.T) {
+	priv, pub := newPrivateKey(t)
+	other, _ := newPrivateKey(t)
+	keyring := agent.NewKeyring()
+	assert.Nil(t, keyring.Add(agent.AddedKey{PrivateKey: other, Comment: "other"}))
+	assert.Nil(t, keyring.Add(agent.AddedKey{PrivateKey: priv, Comment: "deploy"}))
+
+	server := newTestServer(t, pub)
+	client, err := ssh.NewClient(testConfig(server, &ssh.Auth{Agent: keyring}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := client.Output("ssh-add -L")
+	assert.Nil(t, err)
+	assert.Equal(t, "no agent", out)
+
+	// IdentitiesOnly only offers the agent key of the public key file.
+	dir := t.TempDir()
+	file := filepath.Join(dir, "deploy")
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(priv, "deploy", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, os.WriteFile(file, pem.EncodeToMemory(block), 0600))
+	assert.Nil(t, os.WriteFile(file+".pub", gossh.MarshalAuthorizedKey(pub), 0600))
+	server = newTestServer(t, pub)
+	client, err = ssh.NewClient(testConfig(server, &ssh.Auth{Agent: keyring, Keys: []string{file}, IdentitiesOnly: true, ForwardAgent: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = client.Output("ssh-add -L")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(strings.Split(out, "\n")))
+	assert.Contains(t, out, strings.TrimSpace(string(gossh.MarshalAuthorizedKey(pub))))
+
+	otherSigner, _ := gossh.NewSignerFromKey(other)
+	server = newTestServer(t, otherSigner.PublicKey())
+	client, err = ssh.NewClient(testConfig(server, &ssh.Auth{Agent: keyring, Keys: []string{file}, IdentitiesOnly: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Output("uptime")
+	assert.ErrorContains(t, err, "unable to authenticate")
+}
+
+func TestPassphraseCallback(t *testing.T) {
+	priv, pub := newPrivateKey(t)
+	file := filepath.Join(t.TempDir(), "deploy")
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(priv, "deploy", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, os.WriteFile(file, pem.EncodeToMemory(block), 0600))
+	server := newTestServer(t, pub)
+
+	_, err = ssh.NewClient(testConfig(server, &ssh.Auth{Keys: []string{file}}))
+	assert.ErrorContains(t, err, "encrypted")
+
+	asked := []string{}
+	client, err := ssh.NewClient(testConfig(server, &ssh.Auth{
+		Keys: []string{file},
+		PassphraseCallback: func(key string) ([]byte, error) {
+			asked = append(asked, key)
+			return []byte("secret"), nil
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := client.Output("uptime")
+	assert.Nil(t, err)
+	assert.Equal(t, "uptime", out)
+	assert.Equal(t, []string{file}, asked)
+
+	_, err = ssh.NewClient(testConfig(server, &ssh.Auth{
+		Keys: []string{file},
+		PassphraseCallback: func(string) ([]byte, error) {
+			return nil, errors.New("cancelled")
+		},
+	}))
+	assert.ErrorContains(t, err, "cancelled")
+}
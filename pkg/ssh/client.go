@@ -20,6 +20,7 @@ package ssh
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -31,12 +32,17 @@ import (
 
 	"github.com/moby/term"
 	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
 	terminal "golang.org/x/term"
 )
 
 const SSHKeepAliveTimeout = 30 * time.Minute
 const SSHKeepAliveInterval = 1 * time.Minute
 
+// ErrNoSession is returned by Wait when no command was started or the
+// session was already closed.
+var ErrNoSession = errors.New("no ssh session")
+
 // ExitError is a conveniance wrapper for (crypto/ssh).ExitError type.
 type ExitError struct {
 	Err      error
@@ -104,6 +110,19 @@ type Client interface {
 
 	// Stops cached sessions and close the connection
 	StopPersistentConn()
+
+	// Upload copies a local file to the host over sftp.
+	Upload(local, remote string, opts *TransferOptions) error
+
+	// Download copies a file of the host to a local file over sftp.
+	Download(remote, local string, opts *TransferOptions) error
+
+	// UploadDir copies a local directory to the host over sftp.
+	UploadDir(local, remote string, opts *TransferOptions) error
+
+	// WriteFile writes data to a file of the host with the mode and
+	// owner, paths owned by root are written with sudo.
+	WriteFile(path string, data []byte, mode os.FileMode, owner string) error
 }
 
 type HostDetail struct {
@@ -133,6 +152,7 @@ type NativeClient struct {
 	connectedClientMux  sync.Mutex
 	SessionInfo         *SessionInfo
 	DefaultClientConfig *ssh.ClientConfig
+	ForwardAgent        agent.Agent // agent forwarded to the sessions of the last host
 }
 
 // Auth contains auth info
@@ -142,6 +162,11 @@ type Auth struct {
 	RawKeys          [][]byte                  // RawKeys is a slice of private keys to try
 	KeyPairs         []KeyPair                 // KeyPairs is a slice of signed public keys & private keys to try
 	KeyPairsCallback func() ([]KeyPair, error) // Callback to get KeyPairs
+
+	Agent              agent.Agent                      // Agent is an ssh-agent whose keys are tried first, see DialAgent
+	ForwardAgent       bool                             // ForwardAgent forwards Agent to the sessions on the host
+	IdentitiesOnly     bool                             // IdentitiesOnly only tries the keys of Agent that match Keys
+	PassphraseCallback func(key string) ([]byte, error) // PassphraseCallback returns the passphrase of an encrypted key
 }
 
 // Config is used to create new client.
@@ -152,7 +177,14 @@ type Config struct {
 	Port    int                 // port to connect to, 22 by default
 	Auth    *Auth               // authentication methods to use
 	Timeout time.Duration       // connect timeout, 30s by default
-	HostKey ssh.HostKeyCallback // callback for verifying server keys, ssh.InsecureIgnoreHostKey by default
+	HostKey ssh.HostKeyCallback // callback for verifying server keys, KnownHosts by default
+
+	Alias                 string      // ssh_config host alias that the config was resolved for
+	IdentityFiles         []string    // IdentityFile of ssh_config, the ones that exist are in Auth.Keys
+	IdentitiesOnly        bool        // IdentitiesOnly of ssh_config, only the identity files are offered
+	StrictHostKeyChecking string      // StrictHostKeyChecking of ssh_config, the mode of KnownHosts when it has none
+	KnownHosts            *KnownHosts // known_hosts that verify server keys when HostKey is nil, NewKnownHosts by default
+	ProxyJump             []*Config   // jump hosts that are connected through in order before Host
 }
 
 func (cfg *Config) version() string {
@@ -180,7 +212,35 @@ func (cfg *Config) hostKey() ssh.HostKeyCallback {
 	if cfg.HostKey != nil {
 		return cfg.HostKey
 	}
-	return ssh.InsecureIgnoreHostKey()
+
+	kh, err := cfg.knownHosts()
+	if err != nil {
+		return func(string, net.Addr, ssh.PublicKey) error { return err }
+	}
+
+	return kh.HostKeyCallback()
+}
+
+// knownHosts returns the known hosts that verify the server key when
+// HostKey is nil, with the mode of StrictHostKeyChecking when
+// KnownHosts has none.
+func (cfg *Config) knownHosts() (*KnownHosts, error) {
+	if cfg.KnownHosts != nil && cfg.KnownHosts.Mode != "" {
+		return cfg.KnownHosts, nil
+	}
+
+	mode, err := ParseHostKeyMode(cfg.StrictHostKeyChecking)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.KnownHosts == nil {
+		return NewKnownHosts(mode), nil
+	}
+
+	kh := *cfg.KnownHosts
+	kh.Mode = mode
+	return &kh, nil
 }
 
 // saves SSH client so it can be closed later
@@ -243,6 +303,7 @@ func (c *NativeClient) Copy() *NativeClient {
 		ClientVersion:       c.ClientVersion,
 		DefaultClientConfig: c.DefaultClientConfig,
 		SessionInfo:         &sessionInfo,
+		ForwardAgent:        c.ForwardAgent,
 	}
 	return &copyClient
 }
@@ -272,12 +333,76 @@ func (c *NativeClient) RemoveLastHop() (interface{}, error) {
 	return newClient, nil
 }
 
+// NewClient creates a client for config. The jump hosts of ProxyJump
+// become the first hops of the client, they use the version, timeout,
+// host key callback and auth of config unless they set their own.
 func NewClient(config *Config) (Client, error) {
-	defaultConfig, err := NewNativeConfig(config.User, config.version(), config.Auth, config.timeout(), config.hostKey())
-	if err != nil {
-		return nil, fmt.Errorf("Error getting host config for native Go SSH: %s", err)
+	hops := append(append([]*Config{}, config.ProxyJump...), config)
+	var client Client
+	for _, hop := range hops {
+		hop = hop.inherit(config)
+		hopConfig, err := NewNativeConfig(hop.User, hop.version(), hop.Auth, hop.timeout(), hop.hostKey())
+		if err != nil {
+			return nil, fmt.Errorf("Error getting host config for native Go SSH: %s", err)
+		}
+
+		if hop.HostKey == nil {
+			if kh, err := hop.knownHosts(); err == nil {
+				hopConfig.HostKeyAlgorithms = kh.HostKeyAlgorithms(net.JoinHostPort(hop.Host, strconv.Itoa(hop.port())))
+			}
+		}
+
+		if client == nil {
+			client, err = NewClientWithConfig(hop.Host, hop.port(), hopConfig)
+		} else {
+			client, err = client.(*NativeClient).AddHopWithConfig(hop.Host, hop.port(), &hopConfig)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Auth != nil && config.Auth.ForwardAgent {
+		if config.Auth.Agent == nil {
+			return nil, errors.New("agent forwarding needs an agent")
+		}
+
+		client.(*NativeClient).ForwardAgent = config.Auth.Agent
+	}
+
+	return client, nil
+}
+
+// inherit returns a copy of the jump host cfg with the unset settings
+// of target.
+func (cfg *Config) inherit(target *Config) *Config {
+	if cfg == target {
+		return cfg
+	}
+
+	hop := *cfg
+	if hop.Version == "" {
+		hop.Version = target.Version
+	}
+
+	if hop.Timeout == 0 {
+		hop.Timeout = target.Timeout
+	}
+
+	if hop.HostKey == nil {
+		hop.HostKey = target.HostKey
+	}
+
+	if hop.KnownHosts == nil {
+		hop.KnownHosts = target.KnownHosts
 	}
-	return NewClientWithConfig(config.Host, config.port(), defaultConfig)
+
+	if hop.Auth == nil {
+		hop.Auth = target.Auth
+	}
+
+	return &hop
 }
 
 // NewNativeClient creates a new Client using the golang ssh library
@@ -312,54 +437,18 @@ func NewNativeConfig(user, clientVersion string, auth *Auth, timeout time.Durati
 		clientVersion = "SSH-2.0-Go"
 	}
 	if auth != nil {
-		rawKeys := auth.RawKeys
-		for _, k := range auth.Keys {
-			key, err := os.ReadFile(k)
-			if err != nil {
-				return ssh.ClientConfig{}, err
-			}
-
-			rawKeys = append(rawKeys, key)
+		publicKeys, err := auth.publicKeys()
+		if err != nil {
+			return ssh.ClientConfig{}, err
 		}
 
-		for _, key := range rawKeys {
-			privateKey, err := ssh.ParsePrivateKey(key)
-			if err != nil {
-				return ssh.ClientConfig{}, err
-			}
-
-			authMethods = append(authMethods, ssh.PublicKeys(privateKey))
+		if publicKeys != nil {
+			authMethods = append(authMethods, publicKeys)
 		}
 
 		for _, p := range auth.Passwords {
 			authMethods = append(authMethods, ssh.Password(p))
 		}
-
-		for _, keypair := range auth.KeyPairs {
-			signer, err := keypair.getSigner()
-			if err != nil {
-				return ssh.ClientConfig{}, err
-			}
-			authMethods = append(authMethods, ssh.PublicKeys(signer))
-		}
-
-		if auth.KeyPairsCallback != nil {
-			authMethods = append(authMethods, ssh.PublicKeysCallback(func() ([]ssh.Signer, error) {
-				keypairs, err := auth.KeyPairsCallback()
-				if err != nil {
-					return nil, err
-				}
-				signers := []ssh.Signer{}
-				for _, keypair := range keypairs {
-					signer, err := keypair.getSigner()
-					if err != nil {
-						return nil, err
-					}
-					signers = append(signers, signer)
-				}
-				return signers, nil
-			}))
-		}
 	}
 
 	if hostKeyCallback == nil {
@@ -434,7 +523,8 @@ func (nclient *NativeClient) Connect(timeout time.Duration) (*ssh.Client, *Sessi
 				sessionInfo.CloseAll()
 				return nil, nil, fmt.Errorf("ssh client timeout to %s", destAddr)
 			}
-			sshconn, chans, reqs, err := ssh.NewClientConn(conn, h.HostName, h.ClientConfig)
+			// the address with the port is what known_hosts verifies.
+			sshconn, chans, reqs, err := ssh.NewClientConn(conn, destAddr, h.ClientConfig)
 			if err != nil {
 				return nil, &sessionInfo, fmt.Errorf("NewClientConn fail  to %s - %v", destAddr, err)
 			}
@@ -444,25 +534,38 @@ func (nclient *NativeClient) Connect(timeout time.Duration) (*ssh.Client, *Sessi
 		}
 	} //for
 
+	if nclient.ForwardAgent != nil {
+		if err := agent.ForwardToAgent(sshClient, nclient.ForwardAgent); err != nil {
+			sshClient.Close()
+			sessionInfo.CloseAll()
+			return nil, nil, fmt.Errorf("agent forwarding failed - %v", err)
+		}
+	}
+
 	return sshClient, &sessionInfo, nil
 }
 
 func (nc *NativeClient) Session(timeout time.Duration) (*ssh.Session, *SessionInfo, error) {
-	if nc.connectedClient != nil {
-		session, err := nc.connectedClient.NewSession()
+	if conn := nc.persistentClient(); conn != nil {
+		session, err := conn.NewSession()
 		if err != nil {
 			// handle persistent connection loss by trying to reconnect
-			err = nc.restartPersistentConnection(timeout)
+			conn, err = nc.restartPersistentConnection(timeout, conn)
 			if err != nil {
 				return nil, nil, err
 			}
-			session, err = nc.connectedClient.NewSession()
+			session, err = conn.NewSession()
 			if err != nil {
 				// cleanup
 				nc.StopPersistentConn()
 				return nil, nil, err
 			}
 		}
+		if err := nc.requestAgentForwarding(session); err != nil {
+			session.Close()
+			return nil, nil, err
+		}
+
 		// for the cached connection we don't want to close the session, so return a dummy one
 		return session, &SessionInfo{}, nil
 	}
@@ -471,6 +574,9 @@ func (nc *NativeClient) Session(timeout time.Duration) (*ssh.Session, *SessionIn
 		return nil, nil, err
 	}
 	session, err := client.NewSession()
+	if err == nil {
+		err = nc.requestAgentForwarding(session)
+	}
 	if err != nil {
 		client.Close()
 		sessionInfo.CloseAll()
@@ -479,12 +585,46 @@ func (nc *NativeClient) Session(timeout time.Duration) (*ssh.Session, *SessionIn
 	return session, sessionInfo, nil
 }
 
-func (nc *NativeClient) restartPersistentConnection(timeout time.Duration) error {
+// requestAgentForwarding asks the host to forward its agent socket of
+// session to ForwardAgent, so that commands such as `git pull` use it.
+func (nc *NativeClient) requestAgentForwarding(session *ssh.Session) error {
+	if nc.ForwardAgent == nil {
+		return nil
+	}
+
+	return agent.RequestAgentForwarding(session)
+}
+
+// persistentClient returns the client of the persistent connection,
+// nil when there is none.
+func (nc *NativeClient) persistentClient() *ssh.Client {
+	nc.connectedClientMux.Lock()
+	defer nc.connectedClientMux.Unlock()
+	return nc.connectedClient
+}
+
+// restartPersistentConnection replaces the lost client of the
+// persistent connection. A connection that was stopped in the
+// meantime is not restarted, StopPersistentConn is used to end
+// commands that are still running.
+func (nc *NativeClient) restartPersistentConnection(timeout time.Duration, lost *ssh.Client) (*ssh.Client, error) {
 	// Need to hold the lock while trying to reconnect
 	nc.connectedClientMux.Lock()
 	defer nc.connectedClientMux.Unlock()
+	if nc.connectedClient == nil {
+		return nil, ErrNoSession
+	}
+
+	if nc.connectedClient != lost {
+		return nc.connectedClient, nil
+	}
+
 	nc.stopPersistentConn()
-	return nc.startPersistentConn(timeout)
+	if err := nc.startPersistentConn(timeout); err != nil {
+		return nil, err
+	}
+
+	return nc.connectedClient, nil
 }
 
 func (nc *NativeClient) saveConnection(client *ssh.Client, sessionInfo *SessionInfo) {
@@ -609,18 +749,39 @@ func (client *NativeClient) Start(command string) (sout io.ReadCloser, serr io.R
 	if err := session.Start(command); err != nil {
 		return nil, nil, nil, err
 	}
+	sessionInfo.mux.Lock()
 	sessionInfo.openSession = session
+	sessionInfo.mux.Unlock()
+	client.connectedClientMux.Lock()
 	client.saveConnection(nil, sessionInfo)
+	client.connectedClientMux.Unlock()
 
 	return io.NopCloser(stdout), io.NopCloser(stderr), stdin, nil
 }
 
 // Wait waits for the command started by the Start function to exit. The
 // returned error follows the same logic as in the exec.Cmd.Wait function.
+//
+// The session may be closed by StopPersistentConn while Wait blocks,
+// e.g. when a remote task is cancelled, Wait then returns an error.
 func (client *NativeClient) Wait() error {
-	err := client.SessionInfo.openSession.Wait()
-	_ = client.SessionInfo.openSession.Close()
-	client.SessionInfo.CloseAll()
+	client.connectedClientMux.Lock()
+	sessionInfo := client.SessionInfo
+	client.connectedClientMux.Unlock()
+	if sessionInfo == nil {
+		return ErrNoSession
+	}
+
+	sessionInfo.mux.Lock()
+	session := sessionInfo.openSession
+	sessionInfo.mux.Unlock()
+	if session == nil {
+		return ErrNoSession
+	}
+
+	err := session.Wait()
+	_ = session.Close()
+	sessionInfo.CloseAll()
 
 	return err
 }
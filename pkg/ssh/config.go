@@ -1,84 +1,615 @@
 package ssh
 
 import (
+	"bufio"
 	"errors"
+	"fmt"
 	"os"
+	"os/user"
 	"path/filepath"
 	"strconv"
-
-	"github.com/kevinburke/ssh_config"
+	"strings"
+	"time"
 )
 
-func FindConfig(alias string) error {
-	home, err := os.UserHomeDir()
+// MaxIncludeDepth is the number of nested `Include` directives that
+// are followed, it matches OpenSSH.
+const MaxIncludeDepth = 16
+
+// MaxJumpDepth is the number of nested `ProxyJump` hosts that are
+// resolved, it stops jump hosts that jump through each other.
+const MaxJumpDepth = 8
+
+// DefaultIdentityFiles are the private keys in ~/.ssh that are tried
+// when ssh_config does not set an `IdentityFile`.
+var DefaultIdentityFiles = []string{"id_ed25519", "id_ecdsa", "id_rsa"}
+
+// DefaultConfigFiles returns the ssh_config of the user and the one of
+// the system, in the order that ssh reads them.
+func DefaultConfigFiles() []string {
+	files := []string{}
+	if home, err := os.UserHomeDir(); err == nil {
+		files = append(files, filepath.Join(home, ".ssh", "config"))
+	}
+
+	return append(files, "/etc/ssh/ssh_config")
+}
+
+// ConfigResolver resolves host aliases with ssh_config files. Like
+// ssh, the first value of an option wins, `Host` and `Match` blocks
+// apply when they match the alias and `Include` reads more files.
+// `Match exec` and the criteria that need a connection never match.
+type ConfigResolver struct {
+	Files []string // files read in order, missing default files are skipped
+	User  string   // local user for %u and `Match localuser`, $USER by default
+	Home  string   // home directory for ~ and %d, os.UserHomeDir by default
+
+	defaults bool
+}
+
+// NewConfigResolver creates a resolver for files. Empty names are
+// ignored and without files the default files are read.
+func NewConfigResolver(files ...string) *ConfigResolver {
+	r := &ConfigResolver{}
+	for _, f := range files {
+		if f != "" {
+			r.Files = append(r.Files, f)
+		}
+	}
+
+	if len(r.Files) == 0 {
+		r.Files = DefaultConfigFiles()
+		r.defaults = true
+	}
+
+	return r
+}
+
+// FindConfig resolves alias with files, or with ~/.ssh/config and
+// /etc/ssh/ssh_config when no files are given.
+func FindConfig(alias string, files ...string) (*Config, error) {
+	return NewConfigResolver(files...).Resolve(alias)
+}
+
+// Resolve returns the config for alias. An alias that no block
+// matches connects to the alias itself, as ssh does. The hosts of
+// `ProxyJump` become the ProxyJump configs of the result.
+func (r *ConfigResolver) Resolve(alias string) (*Config, error) {
+	return r.resolve(alias, 0)
+}
+
+func (r *ConfigResolver) resolve(alias string, depth int) (*Config, error) {
+	if alias == "" {
+		return nil, errors.New("ssh config: empty host alias")
+	}
+
+	s := &configState{r: r, alias: alias, options: map[string]string{}}
+	for _, file := range r.Files {
+		if err := s.read(file, filepath.Dir(file), !r.defaults, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := s.config()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	sshConfigFile := filepath.Join(home, ".ssh", "ssh_config")
+	jump := s.options["proxyjump"]
+	if jump == "" || strings.EqualFold(jump, "none") {
+		return cfg, nil
+	}
+
+	if depth >= MaxJumpDepth {
+		return nil, fmt.Errorf("ssh config %s: more than %d nested jump hosts, the jump hosts may form a cycle", alias, MaxJumpDepth)
+	}
+
+	for i, spec := range strings.Split(jump, ",") {
+		hop, err := r.jump(strings.TrimSpace(spec), depth)
+		if err != nil {
+			return nil, fmt.Errorf("ssh config %s: %w", alias, err)
+		}
+
+		// like `ssh -J a,b`, only the first jump host uses its own
+		// jump hosts, the next ones are reached through the previous.
+		if i == 0 {
+			cfg.ProxyJump = append(cfg.ProxyJump, hop.ProxyJump...)
+		}
+
+		hop.ProxyJump = nil
+		cfg.ProxyJump = append(cfg.ProxyJump, hop)
+	}
+
+	return cfg, nil
+}
+
+// jump resolves a jump host such as `[user@]host[:port]`.
+func (r *ConfigResolver) jump(spec string, depth int) (*Config, error) {
+	spec = strings.TrimPrefix(spec, "ssh://")
+	userName := ""
+	if i := strings.LastIndex(spec, "@"); i >= 0 {
+		userName, spec = spec[:i], spec[i+1:]
+	}
 
-	if _, err := os.Stat(sshConfigFile); os.IsNotExist(err) {
-		return errors.New("ssh config file does not exist")
+	host, port := spec, 0
+	if h, p, ok := strings.Cut(spec, ":"); ok && !strings.Contains(p, ":") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port in jump host %q", spec)
+		}
+
+		host, port = h, n
+	}
+
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if host == "" {
+		return nil, fmt.Errorf("invalid jump host %q", spec)
 	}
 
-	sshConfigData, err := os.ReadFile(sshConfigFile)
+	cfg, err := r.resolve(host, depth+1)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	sshConfig, err := ssh_config.DecodeBytes(sshConfigData)
+	if userName != "" {
+		cfg.User = userName
+	}
+
+	if port != 0 {
+		cfg.Port = port
+	}
+
+	return cfg, nil
+}
+
+func (r *ConfigResolver) localUser() string {
+	if r.User != "" {
+		return r.User
+	}
+
+	for _, name := range []string{"USER", "USERNAME"} {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+
+	return ""
+}
+
+func (r *ConfigResolver) home() string {
+	if r.Home != "" {
+		return r.Home
+	}
+
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// configState collects the options of one alias while the files are
+// read. Options are stored by their lower case keyword.
+type configState struct {
+	r          *ConfigResolver
+	alias      string
+	options    map[string]string
+	identities []string
+}
+
+// read applies file to the state. Relative includes are resolved
+// against dir, the directory of the file that the resolver read.
+func (s *configState) read(file, dir string, required bool, depth int) error {
+	f, err := os.Open(file)
 	if err != nil {
-		return err
-	}
-
-	if sshConfig.Hosts == nil {
-		return errors.New("ssh config file does not contain any hosts")
-	}
-
-	hostFound := false
-
-	port := 22
-	hostname := alias
-	username := ""
-	identityFiles := []string{}
-	passwordAuth := false
-
-	for _, host := range sshConfig.Hosts {
-		if host.Matches(alias) {
-			hostFound = true
-			for _, n := range host.Nodes {
-				if kv, ok := n.(*ssh_config.KV); ok {
-					switch kv.Key {
-					case "HostName":
-						hostname = kv.Value
-					case "User":
-						username = kv.Value
-					case "Port":
-						portValue := kv.Value
-						port, err = strconv.Atoi(portValue)
-						if err != nil {
-							return err
-						}
-					case "IdentityFile":
-						identityFiles = append(identityFiles, kv.Value)
-					case "PasswordAuthentication":
-						passwordAuth = kv.Value == "yes"
-					}
-				}
-				break
+		if !required && errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("ssh config: %w", err)
+	}
+	defer f.Close()
+
+	active := true
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		keyword, args, err := parseConfigLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("ssh config %s:%d: %w", file, n, err)
+		}
+
+		if keyword == "" {
+			continue
+		}
+
+		switch keyword {
+		case "host":
+			active = matchHost(args, s.alias)
+		case "match":
+			active, err = s.match(args)
+		case "include":
+			if !active {
+				continue
+			}
+
+			if depth >= MaxIncludeDepth {
+				return fmt.Errorf("ssh config %s:%d: more than %d nested includes", file, n, MaxIncludeDepth)
+			}
+
+			// errors of included files name the included file.
+			if err := s.include(args, dir, depth); err != nil {
+				return err
+			}
+		default:
+			if active {
+				err = s.set(keyword, args)
 			}
 		}
+
+		if err != nil {
+			return fmt.Errorf("ssh config %s:%d: %w", file, n, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (s *configState) include(args []string, dir string, depth int) error {
+	for _, pattern := range args {
+		pattern = s.r.expandHome(pattern)
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+
+		// like ssh, patterns without matches are not an error.
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("ssh config: invalid include %q: %w", pattern, err)
+		}
+
+		for _, file := range files {
+			if err := s.read(file, dir, true, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *configState) set(keyword string, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s needs a value", keyword)
 	}
 
-	println(port)
-	println(hostname)
-	println(username)
-	println(identityFiles)
-	println(passwordAuth)
+	if keyword == "identityfile" {
+		s.identities = append(s.identities, args[0])
+		return nil
+	}
 
-	if !hostFound {
-		return errors.New("host not found in ssh config file")
+	if _, ok := s.options[keyword]; !ok {
+		s.options[keyword] = strings.Join(args, " ")
 	}
 
 	return nil
 }
+
+func (s *configState) hostName() string {
+	if v, ok := s.options["hostname"]; ok {
+		return s.expand(v, s.alias, "")
+	}
+
+	return s.alias
+}
+
+func (s *configState) user() string {
+	if v, ok := s.options["user"]; ok {
+		return v
+	}
+
+	return s.r.localUser()
+}
+
+// match evaluates the criteria of a `Match` line.
+func (s *configState) match(args []string) (bool, error) {
+	if len(args) == 0 {
+		return false, errors.New("Match needs criteria")
+	}
+
+	result := true
+	for i := 0; i < len(args); i++ {
+		criterion := strings.ToLower(args[i])
+		negate := strings.HasPrefix(criterion, "!")
+		criterion = strings.TrimPrefix(criterion, "!")
+
+		var ok bool
+		switch criterion {
+		case "all", "canonical", "final":
+			ok = true
+		case "host", "originalhost", "user", "localuser", "exec", "localnetwork",
+			"address", "localaddress", "localport", "rdomain", "tagged", "version",
+			"sessiontype", "command":
+			if i+1 >= len(args) {
+				return false, fmt.Errorf("Match %s needs an argument", criterion)
+			}
+
+			i++
+			patterns := strings.Split(args[i], ",")
+			switch criterion {
+			case "host":
+				ok = matchHost(patterns, s.hostName())
+			case "originalhost":
+				ok = matchHost(patterns, s.alias)
+			case "user":
+				ok = matchPatterns(patterns, s.user())
+			case "localuser":
+				ok = matchPatterns(patterns, s.r.localUser())
+			}
+		default:
+			return false, fmt.Errorf("unsupported Match criterion %q", args[i])
+		}
+
+		if ok == negate {
+			result = false
+		}
+	}
+
+	return result, nil
+}
+
+// config builds the config from the collected options.
+func (s *configState) config() (*Config, error) {
+	cfg := &Config{
+		Alias: s.alias,
+		Host:  s.hostName(),
+		User:  s.user(),
+	}
+
+	if v, ok := s.options["port"]; ok {
+		port, err := strconv.Atoi(v)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("ssh config %s: invalid port %q", s.alias, v)
+		}
+
+		cfg.Port = port
+	}
+
+	if v, ok := s.options["connecttimeout"]; ok {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("ssh config %s: invalid ConnectTimeout %q", s.alias, v)
+		}
+
+		cfg.Timeout = time.Duration(seconds) * time.Second
+	}
+
+	cfg.IdentitiesOnly = strings.EqualFold(s.options["identitiesonly"], "yes")
+	cfg.StrictHostKeyChecking = strings.ToLower(s.options["stricthostkeychecking"])
+	if _, err := ParseHostKeyMode(cfg.StrictHostKeyChecking); err != nil {
+		return nil, fmt.Errorf("ssh config %s: %w", s.alias, err)
+	}
+
+	files, custom := s.options["userknownhostsfile"]
+	hash := strings.EqualFold(s.options["hashknownhosts"], "yes")
+	if custom || hash {
+		cfg.KnownHosts = NewKnownHosts("")
+		cfg.KnownHosts.Hash = hash
+		if custom {
+			cfg.KnownHosts.Files = nil
+			for _, f := range strings.Fields(files) {
+				if !strings.EqualFold(f, "none") {
+					cfg.KnownHosts.Files = append(cfg.KnownHosts.Files, s.expand(f, cfg.Host, cfg.User))
+				}
+			}
+		}
+	}
+
+	for _, id := range s.identities {
+		if strings.EqualFold(id, "none") {
+			continue
+		}
+
+		cfg.IdentityFiles = append(cfg.IdentityFiles, s.expand(id, cfg.Host, cfg.User))
+	}
+
+	if len(s.identities) == 0 {
+		for _, name := range DefaultIdentityFiles {
+			cfg.IdentityFiles = append(cfg.IdentityFiles, filepath.Join(s.r.home(), ".ssh", name))
+		}
+	}
+
+	// like ssh, identity files that do not exist are skipped.
+	keys := []string{}
+	for _, file := range cfg.IdentityFiles {
+		if _, err := os.Stat(file); err == nil {
+			keys = append(keys, file)
+		}
+	}
+
+	cfg.Auth = &Auth{Keys: keys, IdentitiesOnly: cfg.IdentitiesOnly}
+
+	// like ssh, the agent is used when it runs and a missing agent is
+	// not an error.
+	socket := s.options["identityagent"]
+	if !strings.EqualFold(socket, "none") {
+		switch {
+		case socket == "SSH_AUTH_SOCK":
+			socket = ""
+		case strings.HasPrefix(socket, "$"):
+			socket = os.Getenv(strings.Trim(socket[1:], "{}"))
+		case socket != "":
+			socket = s.expand(socket, cfg.Host, cfg.User)
+		}
+
+		if a, err := DialAgent(socket); err == nil {
+			cfg.Auth.Agent = a
+			cfg.Auth.ForwardAgent = strings.EqualFold(s.options["forwardagent"], "yes")
+		}
+	}
+
+	return cfg, nil
+}
+
+// expand replaces ~ and the tokens %%, %d, %h, %n, %p, %r and %u.
+func (s *configState) expand(value, host, remoteUser string) string {
+	value = s.r.expandHome(value)
+	if !strings.Contains(value, "%") {
+		return value
+	}
+
+	port := s.options["port"]
+	if port == "" {
+		port = "22"
+	}
+
+	sb := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		if value[i] != '%' || i+1 == len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		i++
+		switch value[i] {
+		case '%':
+			sb.WriteByte('%')
+		case 'd':
+			sb.WriteString(s.r.home())
+		case 'h':
+			sb.WriteString(host)
+		case 'n':
+			sb.WriteString(s.alias)
+		case 'p':
+			sb.WriteString(port)
+		case 'r':
+			sb.WriteString(remoteUser)
+		case 'u':
+			sb.WriteString(s.r.localUser())
+		default:
+			sb.WriteByte('%')
+			sb.WriteByte(value[i])
+		}
+	}
+
+	return sb.String()
+}
+
+func (r *ConfigResolver) expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	return filepath.Join(r.home(), strings.TrimPrefix(path, "~"))
+}
+
+// parseConfigLine returns the lower case keyword and the arguments of
+// a line such as `Keyword value`, `Keyword=value` or
+// `Keyword "quoted value"`. Comments and empty lines have no keyword.
+func parseConfigLine(line string) (string, []string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil, nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil, nil
+	}
+
+	keyword := strings.ToLower(line[:end])
+	rest := strings.TrimLeft(line[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, "="), " \t")
+
+	args := []string{}
+	for rest != "" {
+		if rest[0] == '#' {
+			break
+		}
+
+		if rest[0] == '"' {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return "", nil, errors.New("unterminated quote")
+			}
+
+			args = append(args, rest[1:end+1])
+			rest = strings.TrimLeft(rest[end+2:], " \t")
+			continue
+		}
+
+		end := strings.IndexAny(rest, " \t")
+		if end < 0 {
+			end = len(rest)
+		}
+
+		args = append(args, rest[:end])
+		rest = strings.TrimLeft(rest[end:], " \t")
+	}
+
+	return keyword, args, nil
+}
+
+// matchHost reports whether host matches the patterns of a `Host`
+// line or a `Match host` criterion. Host names are not case
+// sensitive. A negated pattern such as `!bastion` that matches wins.
+func matchHost(patterns []string, host string) bool {
+	lower := make([]string, len(patterns))
+	for i, p := range patterns {
+		lower[i] = strings.ToLower(p)
+	}
+
+	return matchPatterns(lower, strings.ToLower(host))
+}
+
+func matchPatterns(patterns []string, value string) bool {
+	matched := false
+	for _, p := range patterns {
+		negate := strings.HasPrefix(p, "!")
+		if !matchWildcard(strings.TrimPrefix(p, "!"), value) {
+			continue
+		}
+
+		if negate {
+			return false
+		}
+
+		matched = true
+	}
+
+	return matched
+}
+
+// matchWildcard matches value against a pattern with the wildcards
+// `*` and `?`.
+func matchWildcard(pattern, value string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			pattern = pattern[1:]
+			if pattern == "" {
+				return true
+			}
+
+			for i := 0; i <= len(value); i++ {
+				if matchWildcard(pattern, value[i:]) {
+					return true
+				}
+			}
+
+			return false
+		case '?':
+			if value == "" {
+				return false
+			}
+		default:
+			if value == "" || pattern[0] != value[0] {
+				return false
+			}
+		}
+
+		pattern, value = pattern[1:], value[1:]
+	}
+
+	return value == ""
+}
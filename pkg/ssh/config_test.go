@@ -0,0 +1,165 @@
+package ssh_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, dir, name, content string) string {
+	file := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func TestConfigResolver(t *testing.T) {
+	dir := t.TempDir()
+	key := writeConfig(t, dir, "keys/web", "key")
+	writeConfig(t, dir, "conf.d/web.conf", `
+Host web
+    User www
+    Port 2200
+`)
+	file := writeConfig(t, dir, "config", `
+# comment
+Include conf.d/*.conf
+
+Host web !db
+    HostName=web.example.com
+    User ignored
+    IdentityFile "`+filepath.Join(dir, "keys", "%n")+`"
+    IdentitiesOnly yes
+    ConnectTimeout 5
+
+Match host *.example.com user www
+    StrictHostKeyChecking accept-new
+
+Match exec "true"
+    Port 1
+
+Host *
+    User fallback
+    IdentityFile ~/.ssh/missing
+`)
+
+	r := ssh.NewConfigResolver(file)
+	r.User = "me"
+	r.Home = dir
+
+	cfg, err := r.Resolve("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "web", cfg.Alias)
+	assert.Equal(t, "web.example.com", cfg.Host)
+	assert.Equal(t, "www", cfg.User)
+	assert.Equal(t, 2200, cfg.Port)
+	assert.Equal(t, 5*time.Second, cfg.Timeout)
+	assert.True(t, cfg.IdentitiesOnly)
+	assert.Equal(t, "accept-new", cfg.StrictHostKeyChecking)
+	assert.Equal(t, []string{key, filepath.Join(dir, ".ssh", "missing")}, cfg.IdentityFiles)
+	assert.Equal(t, []string{key}, cfg.Auth.Keys)
+	assert.Empty(t, cfg.ProxyJump)
+
+	cfg, err = r.Resolve("db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "db", cfg.Host)
+	assert.Equal(t, "fallback", cfg.User)
+	assert.Equal(t, 0, cfg.Port)
+	assert.False(t, cfg.IdentitiesOnly)
+	assert.Equal(t, "", cfg.StrictHostKeyChecking)
+
+	_, err = ssh.NewConfigResolver(filepath.Join(dir, "none")).Resolve("web")
+	assert.NotNil(t, err)
+
+	bad := writeConfig(t, dir, "bad", "Match nothing\n")
+	_, err = ssh.NewConfigResolver(bad).Resolve("web")
+	assert.ErrorContains(t, err, "bad:1")
+}
+
+func TestConfigResolverProxyJump(t *testing.T) {
+	dir := t.TempDir()
+	file := writeConfig(t, dir, "config", `
+Host target
+    ProxyJump admin@hop2:2022,hop3
+
+Host hop2
+    HostName hop2.example.com
+    ProxyJump hop1
+
+Host hop3
+    ProxyJump hop1
+
+Host loop
+    ProxyJump loop
+
+Host *
+    User me
+`)
+
+	r := ssh.NewConfigResolver(file)
+	r.Home = dir
+
+	cfg, err := r.Resolve("target")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hosts := []string{}
+	for _, hop := range cfg.ProxyJump {
+		hosts = append(hosts, hop.Host)
+		assert.Empty(t, hop.ProxyJump)
+	}
+
+	assert.Equal(t, []string{"hop1", "hop2.example.com", "hop3"}, hosts)
+	assert.Equal(t, "admin", cfg.ProxyJump[1].User)
+	assert.Equal(t, 2022, cfg.ProxyJump[1].Port)
+	assert.Equal(t, "me", cfg.ProxyJump[2].User)
+
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details := client.(*ssh.NativeClient).HostDetails
+	assert.Equal(t, 4, len(details))
+	assert.Equal(t, "hop1", details[0].HostName)
+	assert.Equal(t, 2022, details[1].Port)
+	assert.Equal(t, "target", details[3].HostName)
+	assert.Equal(t, "admin", details[1].ClientConfig.User)
+
+	_, err = r.Resolve("loop")
+	assert.ErrorContains(t, err, "cycle")
+}
+
+func TestConfigResolverSample(t *testing.T) {
+	r := ssh.NewConfigResolver(filepath.Join("..", "..", "samples", "etc", "jolt9", "ssh_config"))
+	r.Home = t.TempDir()
+
+	cfg, err := r.Resolve("node1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "node1.example.internal", cfg.Host)
+	assert.Equal(t, "deploy", cfg.User)
+	assert.Equal(t, "accept-new", cfg.StrictHostKeyChecking)
+	assert.Equal(t, 1, len(cfg.ProxyJump))
+	assert.Equal(t, "bastion.example.com", cfg.ProxyJump[0].Host)
+	assert.Equal(t, 2222, cfg.ProxyJump[0].Port)
+}
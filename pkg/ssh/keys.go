@@ -1,17 +1,18 @@
 package ssh
 
 import (
-	"crypto/md5"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"runtime"
+	"strings"
 
 	gossh "golang.org/x/crypto/ssh"
 )
@@ -23,83 +24,194 @@ var (
 	ErrUnableToWriteFile = errors.New("Unable to write file")
 )
 
+// KeyType is the algorithm of a generated key.
+type KeyType string
+
+const (
+	KeyEd25519 KeyType = "ed25519"
+	KeyECDSA   KeyType = "ecdsa"
+	KeyRSA     KeyType = "rsa"
+)
+
+// DefaultRSABits and DefaultECDSABits are the sizes of keys when
+// KeyOptions has no bits, ed25519 keys always have 256 bits.
+const (
+	DefaultRSABits   = 4096
+	DefaultECDSABits = 256
+)
+
+// KeyOptions selects the key that NewKeyPair generates.
+type KeyOptions struct {
+	Type       KeyType // ed25519 by default
+	Bits       int     // 2048 to 8192 for rsa, 256, 384 or 521 for ecdsa
+	Comment    string  // comment of the key, e.g. deploy@node1
+	Passphrase []byte  // encrypts the private key when set
+}
+
 type KeyPair struct {
 	PrivateKey []byte
 	PublicKey  []byte
 }
 
-// NewKeyPair generates a new SSH keypair
-// This will return a private & public key encoded as DER.
-func NewKeyPair() (keyPair *KeyPair, err error) {
-	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+// NewKeyPair generates a new SSH keypair. The private key is PEM in
+// the OpenSSH format, encrypted with the passphrase of opts when set,
+// and the public key is in the authorized_keys format. A nil opts
+// generates an ed25519 key.
+func NewKeyPair(opts *KeyOptions) (keyPair *KeyPair, err error) {
+	if opts == nil {
+		opts = &KeyOptions{}
+	}
+
+	priv, err := generateKey(opts.Type, opts.Bits)
 	if err != nil {
-		return nil, ErrKeyGeneration
+		return nil, err
 	}
 
-	if err := priv.Validate(); err != nil {
-		return nil, ErrValidation
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, ErrPublicKey
 	}
 
-	privDer := x509.MarshalPKCS1PrivateKey(priv)
+	var block *pem.Block
+	if len(opts.Passphrase) > 0 {
+		block, err = gossh.MarshalPrivateKeyWithPassphrase(priv, opts.Comment, opts.Passphrase)
+	} else {
+		block, err = gossh.MarshalPrivateKey(priv, opts.Comment)
+	}
 
-	pubSSH, err := gossh.NewPublicKey(&priv.PublicKey)
 	if err != nil {
-		return nil, ErrPublicKey
+		return nil, fmt.Errorf("%w: %s", ErrKeyGeneration, err)
+	}
+
+	pub := strings.TrimSpace(string(gossh.MarshalAuthorizedKey(signer.PublicKey())))
+	if opts.Comment != "" {
+		pub += " " + opts.Comment
 	}
 
 	return &KeyPair{
-		PrivateKey: privDer,
-		PublicKey:  gossh.MarshalAuthorizedKey(pubSSH),
+		PrivateKey: pem.EncodeToMemory(block),
+		PublicKey:  []byte(pub + "\n"),
 	}, nil
 }
 
-// WriteToFile writes keypair to files
-func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) error {
-	files := []struct {
-		File  string
-		Type  string
-		Value []byte
-	}{
-		{
-			File:  privateKeyPath,
-			Value: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Headers: nil, Bytes: kp.PrivateKey}),
-		},
-		{
-			File:  publicKeyPath,
-			Value: kp.PublicKey,
-		},
-	}
+func generateKey(typ KeyType, bits int) (crypto.Signer, error) {
+	switch typ {
+	case "", KeyEd25519:
+		if bits != 0 && bits != 256 {
+			return nil, fmt.Errorf("%w: ed25519 keys have 256 bits", ErrValidation)
+		}
+
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, ErrKeyGeneration
+		}
+
+		return priv, nil
+	case KeyECDSA:
+		if bits == 0 {
+			bits = DefaultECDSABits
+		}
+
+		var curve elliptic.Curve
+		switch bits {
+		case 256:
+			curve = elliptic.P256()
+		case 384:
+			curve = elliptic.P384()
+		case 521:
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("%w: ecdsa keys have 256, 384 or 521 bits", ErrValidation)
+		}
+
+		priv, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			return nil, ErrKeyGeneration
+		}
+
+		return priv, nil
+	case KeyRSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
 
-	for _, v := range files {
-		f, err := os.Create(v.File)
+		if bits < 2048 || bits > 8192 {
+			return nil, fmt.Errorf("%w: rsa keys have 2048 to 8192 bits", ErrValidation)
+		}
+
+		priv, err := rsa.GenerateKey(rand.Reader, bits)
 		if err != nil {
-			return ErrUnableToWriteFile
+			return nil, ErrKeyGeneration
 		}
 
-		if _, err := f.Write(v.Value); err != nil {
-			return ErrUnableToWriteFile
+		if err := priv.Validate(); err != nil {
+			return nil, ErrValidation
 		}
 
-		// windows does not support chmod
-		switch runtime.GOOS {
-		case "darwin", "freebsd", "linux", "openbsd":
-			if err := f.Chmod(0600); err != nil {
-				return err
-			}
+		return priv, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown key type %q, expected ed25519, ecdsa or rsa", ErrValidation, typ)
+}
+
+// WriteToFile writes keypair to files, the private key is only
+// readable by the user.
+func (kp *KeyPair) WriteToFile(privateKeyPath string, publicKeyPath string) error {
+	if err := os.WriteFile(privateKeyPath, kp.PrivateKey, 0600); err != nil {
+		return fmt.Errorf("%w: %s", ErrUnableToWriteFile, err)
+	}
+
+	// WriteFile keeps the mode of an existing file, windows does not
+	// support chmod.
+	if runtime.GOOS != "windows" {
+		if err := os.Chmod(privateKeyPath, 0600); err != nil {
+			return err
 		}
 	}
 
+	if err := os.WriteFile(publicKeyPath, kp.PublicKey, 0644); err != nil {
+		return fmt.Errorf("%w: %s", ErrUnableToWriteFile, err)
+	}
+
 	return nil
 }
 
-// Fingerprint calculates the fingerprint of the public key
+// Fingerprint returns the SHA256 fingerprint of the public key as
+// printed by `ssh-keygen -l`, e.g. `SHA256:uNiVztksCsDhcc0u9e8B...`.
 func (kp *KeyPair) Fingerprint() string {
-	b, _ := base64.StdEncoding.DecodeString(string(kp.PublicKey))
-	h := md5.New()
+	pub, _, _, _, err := gossh.ParseAuthorizedKey(kp.PublicKey)
+	if err != nil {
+		return ""
+	}
 
-	io.WriteString(h, string(b))
+	return gossh.FingerprintSHA256(pub)
+}
+
+// String describes the key like `ssh-keygen -l`, with the bits,
+// fingerprint, comment and type of the key.
+func (kp *KeyPair) String() string {
+	pub, comment, _, _, err := gossh.ParseAuthorizedKey(kp.PublicKey)
+	if err != nil {
+		return ""
+	}
+
+	if comment == "" {
+		comment = "no comment"
+	}
+
+	bits, typ := 0, ""
+	if ck, ok := pub.(gossh.CryptoPublicKey); ok {
+		switch k := ck.CryptoPublicKey().(type) {
+		case ed25519.PublicKey:
+			bits, typ = 256, "ED25519"
+		case *ecdsa.PublicKey:
+			bits, typ = k.Curve.Params().BitSize, "ECDSA"
+		case *rsa.PublicKey:
+			bits, typ = k.N.BitLen(), "RSA"
+		}
+	}
 
-	return fmt.Sprintf("%x", h.Sum(nil))
+	return fmt.Sprintf("%d %s %s (%s)", bits, gossh.FingerprintSHA256(pub), comment, typ)
 }
 
 // GenerateSSHKey generates SSH keypair based on path of the private key
@@ -110,7 +222,7 @@ func GenerateSSHKey(path string) error {
 			return fmt.Errorf("Desired directory for SSH keys does not exist: %s", err)
 		}
 
-		kp, err := NewKeyPair()
+		kp, err := NewKeyPair(nil)
 		if err != nil {
 			return fmt.Errorf("Error generating key pair: %s", err)
 		}
@@ -0,0 +1,65 @@
+package ssh_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+func TestNewKeyPair(t *testing.T) {
+	tests := []struct {
+		opts *ssh.KeyOptions
+		algo string
+		desc string
+	}{
+		{nil, gossh.KeyAlgoED25519, "256 SHA256:"},
+		{&ssh.KeyOptions{Type: ssh.KeyECDSA, Bits: 384}, gossh.KeyAlgoECDSA384, "384 SHA256:"},
+		{&ssh.KeyOptions{Type: ssh.KeyRSA, Bits: 2048, Comment: "deploy@node1"}, gossh.KeyAlgoRSA, "2048 SHA256:"},
+	}
+
+	for _, tt := range tests {
+		kp, err := ssh.NewKeyPair(tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Contains(t, string(kp.PrivateKey), "OPENSSH PRIVATE KEY")
+		signer, err := gossh.ParsePrivateKey(kp.PrivateKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, tt.algo, signer.PublicKey().Type())
+		assert.Equal(t, gossh.FingerprintSHA256(signer.PublicKey()), kp.Fingerprint())
+		assert.True(t, strings.HasPrefix(kp.String(), tt.desc))
+	}
+
+	kp, err := ssh.NewKeyPair(&ssh.KeyOptions{Comment: "deploy@node1", Passphrase: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.True(t, strings.HasSuffix(kp.String(), "deploy@node1 (ED25519)"))
+	_, err = gossh.ParsePrivateKey(kp.PrivateKey)
+	assert.NotNil(t, err)
+	_, err = gossh.ParsePrivateKeyWithPassphrase(kp.PrivateKey, []byte("secret"))
+	assert.Nil(t, err)
+
+	_, err = ssh.NewKeyPair(&ssh.KeyOptions{Type: ssh.KeyECDSA, Bits: 1024})
+	assert.ErrorIs(t, err, ssh.ErrValidation)
+	_, err = ssh.NewKeyPair(&ssh.KeyOptions{Type: "dsa"})
+	assert.ErrorIs(t, err, ssh.ErrValidation)
+
+	file := filepath.Join(t.TempDir(), "id_ed25519")
+	assert.Nil(t, ssh.GenerateSSHKey(file))
+	info, err := os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	_, err = os.Stat(file + ".pub")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,260 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/os/paths"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyMode selects how unknown host keys are handled.
+type HostKeyMode string
+
+const (
+	// HostKeyStrict only accepts the keys of known_hosts.
+	HostKeyStrict HostKeyMode = "strict"
+
+	// HostKeyAcceptNew records the key of an unknown host on the first
+	// connection and then verifies it, keys that changed are refused.
+	HostKeyAcceptNew HostKeyMode = "accept-new"
+
+	// HostKeyOff accepts every host key.
+	HostKeyOff HostKeyMode = "off"
+)
+
+// DefaultHostKeyMode is used when neither the config nor ssh_config
+// sets a mode.
+const DefaultHostKeyMode = HostKeyAcceptNew
+
+// ErrHostKeyUnknown is returned in strict mode for a host that has no
+// key in the known_hosts files.
+var ErrHostKeyUnknown = errors.New("host key is not known")
+
+// knownHostsMux serializes the reads and writes of known_hosts files,
+// hosts of a job connect in parallel.
+var knownHostsMux sync.Mutex
+
+// ParseHostKeyMode returns the mode for a value of StrictHostKeyChecking.
+// Like ssh, `yes` and `ask` are strict, as jolt9 does not prompt, and
+// `no` is off. An empty value is the default mode.
+func ParseHostKeyMode(value string) (HostKeyMode, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return DefaultHostKeyMode, nil
+	case "strict", "yes", "ask", "true":
+		return HostKeyStrict, nil
+	case "accept-new":
+		return HostKeyAcceptNew, nil
+	case "off", "no", "false":
+		return HostKeyOff, nil
+	}
+
+	return "", fmt.Errorf("invalid host key mode %q, expected strict, accept-new or off", value)
+}
+
+// HostKeyMismatchError is returned when a host presents a key that
+// differs from the key of known_hosts, which may be an attack.
+type HostKeyMismatchError struct {
+	Host  string   // host as written to known_hosts, e.g. `[node1]:2222`
+	Known []string // SHA256 fingerprints of the known keys with their file and line
+	Got   string   // SHA256 fingerprint of the presented key
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key of %s changed: known %s, got %s. "+
+		"If the host was reinstalled, remove its old key from the known_hosts file",
+		e.Host, strings.Join(e.Known, ", "), e.Got)
+}
+
+// KnownHosts verifies host keys with known_hosts files. Files are
+// only read, keys accepted with HostKeyAcceptNew are added to File,
+// which is also read. Hashed host names are supported.
+type KnownHosts struct {
+	Files []string    // known_hosts files that are read, e.g. ~/.ssh/known_hosts
+	File  string      // jolt9 managed known_hosts that new keys are written to
+	Mode  HostKeyMode // mode for unknown hosts, DefaultHostKeyMode when empty
+	Hash  bool        // write hashed host names such as HashKnownHosts does
+}
+
+// ManagedKnownHostsFile returns the known_hosts file that jolt9
+// writes to, under paths.AppDataDir.
+func ManagedKnownHostsFile() (string, error) {
+	dir, err := paths.AppDataDir(configs.AppName)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "known_hosts"), nil
+}
+
+// NewKnownHosts returns known hosts that read ~/.ssh/known_hosts and
+// the managed file, which new keys are written to.
+func NewKnownHosts(mode HostKeyMode) *KnownHosts {
+	k := &KnownHosts{Mode: mode}
+	if home, err := os.UserHomeDir(); err == nil {
+		k.Files = append(k.Files, filepath.Join(home, ".ssh", "known_hosts"))
+	}
+
+	if file, err := ManagedKnownHostsFile(); err == nil {
+		k.File = file
+	}
+
+	return k
+}
+
+func (k *KnownHosts) mode() HostKeyMode {
+	if k.Mode == "" {
+		return DefaultHostKeyMode
+	}
+
+	return k.Mode
+}
+
+// files returns the known_hosts files that exist.
+func (k *KnownHosts) files() []string {
+	files := []string{}
+	for _, f := range append(append([]string{}, k.Files...), k.File) {
+		if f == "" {
+			continue
+		}
+
+		if _, err := os.Stat(f); err == nil {
+			files = append(files, f)
+		}
+	}
+
+	return files
+}
+
+func (k *KnownHosts) callback() (ssh.HostKeyCallback, error) {
+	files := k.files()
+	if len(files) == 0 {
+		return func(string, net.Addr, ssh.PublicKey) error { return &knownhosts.KeyError{} }, nil
+	}
+
+	return knownhosts.New(files...)
+}
+
+// HostKeyCallback returns the callback that verifies host keys. The
+// files are read for every connection, so keys that are recorded for
+// a jump host are known to the next connection.
+func (k *KnownHosts) HostKeyCallback() ssh.HostKeyCallback {
+	if k.mode() == HostKeyOff {
+		return ssh.InsecureIgnoreHostKey()
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		knownHostsMux.Lock()
+		defer knownHostsMux.Unlock()
+
+		check, err := k.callback()
+		if err != nil {
+			return err
+		}
+
+		err = check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		host := knownhosts.Normalize(hostname)
+		if len(keyErr.Want) > 0 {
+			known := make([]string, 0, len(keyErr.Want))
+			for _, w := range keyErr.Want {
+				known = append(known, fmt.Sprintf("%s (%s:%d)", ssh.FingerprintSHA256(w.Key), w.Filename, w.Line))
+			}
+
+			return &HostKeyMismatchError{Host: host, Known: known, Got: ssh.FingerprintSHA256(key)}
+		}
+
+		if k.mode() == HostKeyStrict {
+			return fmt.Errorf("%w: %s presented %s %s", ErrHostKeyUnknown, host, key.Type(), ssh.FingerprintSHA256(key))
+		}
+
+		return k.add(hostname, key)
+	}
+}
+
+// add writes key for hostname to the managed file.
+func (k *KnownHosts) add(hostname string, key ssh.PublicKey) error {
+	if k.File == "" {
+		return errors.New("no known_hosts file to record new host keys to")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(k.File), 0700); err != nil {
+		return err
+	}
+
+	host := knownhosts.Normalize(hostname)
+	if k.Hash {
+		host = knownhosts.HashHostname(host)
+	}
+
+	f, err := os.OpenFile(k.File, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintln(f, knownhosts.Line([]string{host}, key))
+	return err
+}
+
+// HostKeyAlgorithms returns the algorithms of the known keys of the
+// host at address, so that the host presents a key that is known
+// instead of a newer type. Unknown hosts return nil.
+func (k *KnownHosts) HostKeyAlgorithms(address string) []string {
+	if k.mode() == HostKeyOff {
+		return nil
+	}
+
+	knownHostsMux.Lock()
+	defer knownHostsMux.Unlock()
+
+	check, err := k.callback()
+	if err != nil {
+		return nil
+	}
+
+	// a throwaway key never matches, the error lists the known keys.
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil
+	}
+
+	probe, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if !errors.As(check(address, &net.TCPAddr{IP: net.IPv4zero}, probe), &keyErr) {
+		return nil
+	}
+
+	algorithms := []string{}
+	for _, w := range keyErr.Want {
+		if w.Key.Type() == ssh.KeyAlgoRSA {
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+
+		algorithms = append(algorithms, w.Key.Type())
+	}
+
+	if len(algorithms) == 0 {
+		return nil
+	}
+
+	return algorithms
+}
@@ -0,0 +1,127 @@
+package ssh_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func newPublicKey(t *testing.T) gossh.PublicKey {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := gossh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestKnownHosts(t *testing.T) {
+	dir := t.TempDir()
+	remote := &net.TCPAddr{IP: net.ParseIP("10.0.0.10"), Port: 22}
+	key := newPublicKey(t)
+	other := newPublicKey(t)
+
+	strict := &ssh.KnownHosts{File: filepath.Join(dir, "known_hosts"), Mode: ssh.HostKeyStrict}
+	err := strict.HostKeyCallback()("node1:22", remote, key)
+	assert.ErrorIs(t, err, ssh.ErrHostKeyUnknown)
+
+	kh := &ssh.KnownHosts{File: filepath.Join(dir, "known_hosts"), Mode: ssh.HostKeyAcceptNew, Hash: true}
+	check := kh.HostKeyCallback()
+	assert.Nil(t, check("node1:22", remote, key))
+	assert.Nil(t, check("node1:22", remote, key))
+	assert.Nil(t, strict.HostKeyCallback()("node1:22", remote, key))
+
+	data, _ := os.ReadFile(kh.File)
+	assert.True(t, strings.HasPrefix(string(data), "|1|"))
+	assert.Equal(t, 1, strings.Count(string(data), "\n"))
+
+	err = check("node1:22", remote, other)
+	var mismatch *ssh.HostKeyMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, "node1", mismatch.Host)
+	assert.Equal(t, gossh.FingerprintSHA256(other), mismatch.Got)
+	assert.Contains(t, err.Error(), gossh.FingerprintSHA256(key))
+
+	user := filepath.Join(dir, "user_known_hosts")
+	line := knownhosts.Line([]string{knownhosts.HashHostname("[node2]:2222")}, other) + "\n"
+	if err := os.WriteFile(user, []byte(line), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	strict.Files = []string{user}
+	assert.Nil(t, strict.HostKeyCallback()("node2:2222", remote, other))
+	assert.Equal(t, []string{gossh.KeyAlgoED25519}, strict.HostKeyAlgorithms("node2:2222"))
+	assert.Nil(t, strict.HostKeyAlgorithms("node3:22"))
+
+	off := &ssh.KnownHosts{Mode: ssh.HostKeyOff}
+	assert.Nil(t, off.HostKeyCallback()("node1:22", remote, other))
+
+	mode, err := ssh.ParseHostKeyMode("yes")
+	assert.Nil(t, err)
+	assert.Equal(t, ssh.HostKeyStrict, mode)
+	_, err = ssh.ParseHostKeyMode("maybe")
+	assert.NotNil(t, err)
+}
+
+func TestKnownHostsJumpHost(t *testing.T) {
+	jump := newTestServer(t)
+	target := newTestServer(t)
+	file := filepath.Join(t.TempDir(), "known_hosts")
+	auth := &ssh.Auth{Passwords: []string{"secret"}}
+
+	cfg := &ssh.Config{
+		User:       "deploy",
+		Host:       target.Host,
+		Port:       target.Port,
+		Auth:       auth,
+		KnownHosts: &ssh.KnownHosts{File: file, Mode: ssh.HostKeyAcceptNew},
+		ProxyJump:  []*ssh.Config{{User: "jump", Host: jump.Host, Port: jump.Port}},
+	}
+
+	client, err := ssh.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := client.Output("hostname")
+	assert.Nil(t, err)
+	assert.Equal(t, "hostname", out)
+
+	data, _ := os.ReadFile(file)
+	assert.Equal(t, 2, strings.Count(string(data), "\n"))
+
+	// the host behind the jump host is verified too, a host that
+	// presents another key is refused.
+	other := newTestServer(t)
+	cfg.Host = "localhost"
+	cfg.Port = other.Port
+	address := knownhosts.Normalize(net.JoinHostPort("localhost", strconv.Itoa(other.Port)))
+	line := knownhosts.Line([]string{address}, target.Key.PublicKey()) + "\n"
+	if err := os.WriteFile(file, append(data, line...), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err = ssh.NewClient(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Output("hostname")
+	assert.ErrorContains(t, err, "host key of [localhost]")
+}
@@ -0,0 +1,108 @@
+package ssh_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/actions"
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/jobs"
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	"github.com/stretchr/testify/assert"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSshRemoteCancel(t *testing.T) {
+	server := newTestServer(t)
+	sshConfig := filepath.Join(t.TempDir(), "ssh_config")
+	assert.Nil(t, os.WriteFile(sshConfig, nil, 0600))
+
+	remote := &jobs.SshRemote{
+		Auth:    &ssh.Auth{Passwords: []string{"secret"}},
+		HostKey: gossh.InsecureIgnoreHostKey(),
+	}
+
+	params := jobs.RemoteParams{
+		Host:      configs.InventoryItem{Name: "node1", Host: server.Host, Port: server.Port, User: "deploy"},
+		Script:    "echo $GREETING",
+		Env:       map[string]string{"GREETING": "hello"},
+		SshConfig: sshConfig,
+	}
+
+	out, err := remote.Run(context.Background(), params)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", string(out.Stdout))
+
+	params.Script = "exit 3"
+	out, err = remote.Run(context.Background(), params)
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, out.Code)
+
+	params.Script = "sleep 10"
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	out, err = remote.Run(ctx, params)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 1, out.Code)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestSshRunCancel(t *testing.T) {
+	server := newTestServer(t)
+
+	// jolt9/ssh-run verifies the server with ~/.ssh/known_hosts.
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	addr := net.JoinHostPort(server.Host, strconv.Itoa(server.Port))
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, server.Key.PublicKey())
+	assert.Nil(t, os.MkdirAll(filepath.Join(home, ".ssh"), 0700))
+	assert.Nil(t, os.WriteFile(filepath.Join(home, ".ssh", "known_hosts"), []byte(line+"\n"), 0600))
+
+	action, ok := actions.Registry.Get("jolt9/ssh-run")
+	assert.True(t, ok)
+
+	run := func(ctx context.Context, command string) (string, error) {
+		stdout := &bytes.Buffer{}
+		_, err := action.Run(&actions.Context{
+			Context: ctx,
+			Stdout:  stdout,
+			With: map[string]string{
+				"host":     server.Host,
+				"port":     fmt.Sprint(server.Port),
+				"user":     "deploy",
+				"password": "secret",
+				"command":  command,
+			},
+		})
+
+		return stdout.String(), err
+	}
+
+	text, err := run(context.Background(), "sh -c 'echo hello'")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello\n", text)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = run(ctx, "sh -c 'sleep 10'")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// cancels while the connection and session are set up.
+	for i := 0; i < 20; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(i)*time.Millisecond)
+		_, err = run(ctx, "sh -c 'sleep 10'")
+		cancel()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,249 @@
+package ssh_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// testServer is an in-process ssh server that runs no commands, exec
+// requests echo the command. Commands that start with `sh` or `sudo -n`
+// run in a local shell without sudo until the client disconnects and
+// the sftp subsystem serves the local files. It forwards direct-tcpip channels, so it can be a jump host.
+// With agent forwarding, `ssh-add -L` lists the keys of the forwarded
+// agent.
+type testServer struct {
+	Host string
+	Port int
+	Key  gossh.Signer
+}
+
+// newTestServer starts a server that accepts any password and key, or
+// only the authorized keys when some are given.
+func newTestServer(t *testing.T, authorized ...gossh.PublicKey) *testServer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := gossh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &gossh.ServerConfig{
+		PasswordCallback: func(gossh.ConnMetadata, []byte) (*gossh.Permissions, error) {
+			if len(authorized) > 0 {
+				return nil, errors.New("password denied")
+			}
+
+			return nil, nil
+		},
+		PublicKeyCallback: func(_ gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			for _, k := range authorized {
+				if bytes.Equal(k.Marshal(), key.Marshal()) {
+					return nil, nil
+				}
+			}
+
+			if len(authorized) > 0 {
+				return nil, errors.New("key denied")
+			}
+
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveConn(conn, config)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return &testServer{Host: host, Port: n, Key: signer}
+}
+
+func serveConn(conn net.Conn, config *gossh.ServerConfig) {
+	defer conn.Close()
+	sconn, chans, reqs, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+
+	go gossh.DiscardRequests(reqs)
+	for nc := range chans {
+		switch nc.ChannelType() {
+		case "session":
+			ch, reqs, err := nc.Accept()
+			if err != nil {
+				continue
+			}
+
+			go serveSession(sconn, ch, reqs)
+		case "direct-tcpip":
+			var target struct {
+				Host     string
+				Port     uint32
+				OrigHost string
+				OrigPort uint32
+			}
+
+			if err := gossh.Unmarshal(nc.ExtraData(), &target); err != nil {
+				nc.Reject(gossh.ConnectionFailed, err.Error())
+				continue
+			}
+
+			dest, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.Itoa(int(target.Port))))
+			if err != nil {
+				nc.Reject(gossh.ConnectionFailed, err.Error())
+				continue
+			}
+
+			ch, reqs, err := nc.Accept()
+			if err != nil {
+				dest.Close()
+				continue
+			}
+
+			go gossh.DiscardRequests(reqs)
+			go func() {
+				io.Copy(ch, dest)
+				ch.Close()
+			}()
+			go func() {
+				io.Copy(dest, ch)
+				dest.Close()
+			}()
+		default:
+			nc.Reject(gossh.UnknownChannelType, nc.ChannelType())
+		}
+	}
+}
+
+func serveSession(sconn *gossh.ServerConn, ch gossh.Channel, reqs <-chan *gossh.Request) {
+	defer ch.Close()
+	forward := false
+	for req := range reqs {
+		switch req.Type {
+		case "auth-agent-req@openssh.com":
+			forward = true
+			req.Reply(true, nil)
+			continue
+		case "subsystem":
+			var subsystem struct{ Name string }
+			gossh.Unmarshal(req.Payload, &subsystem)
+			req.Reply(subsystem.Name == "sftp", nil)
+			if subsystem.Name != "sftp" {
+				continue
+			}
+
+			server, err := sftp.NewServer(ch)
+			if err != nil {
+				return
+			}
+
+			server.Serve()
+			return
+		case "exec":
+		default:
+			req.Reply(req.Type == "env", nil)
+			continue
+		}
+
+		var exec struct{ Command string }
+		gossh.Unmarshal(req.Payload, &exec)
+		req.Reply(true, nil)
+		if strings.HasPrefix(exec.Command, "sudo -n ") || strings.HasPrefix(exec.Command, "sh ") {
+			status := runLocal(sconn, ch, exec.Command)
+			ch.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{status}))
+			return
+		}
+
+		out := exec.Command
+		if exec.Command == "ssh-add -L" {
+			out = listForwardedKeys(sconn, forward)
+		}
+
+		ch.Write([]byte(out))
+		ch.SendRequest("exit-status", false, gossh.Marshal(struct{ Status uint32 }{0}))
+		return
+	}
+}
+
+// runLocal runs the command in a local shell without sudo, the shell is
+// killed when the client disconnects.
+func runLocal(sconn *gossh.ServerConn, ch gossh.Channel, command string) uint32 {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		sconn.Wait()
+		cancel()
+	}()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", strings.ReplaceAll(command, "sudo -n ", ""))
+	cmd.WaitDelay = 100 * time.Millisecond
+	cmd.Stdout = ch
+	cmd.Stderr = ch.Stderr()
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return uint32(exitErr.ExitCode())
+		}
+
+		return 127
+	}
+
+	return 0
+}
+
+func listForwardedKeys(sconn *gossh.ServerConn, forward bool) string {
+	if !forward {
+		return "no agent"
+	}
+
+	ch, reqs, err := sconn.OpenChannel("auth-agent@openssh.com", nil)
+	if err != nil {
+		return err.Error()
+	}
+
+	defer ch.Close()
+	go gossh.DiscardRequests(reqs)
+	keys, err := agent.NewClient(ch).List()
+	if err != nil {
+		return err.Error()
+	}
+
+	lines := []string{}
+	for _, k := range keys {
+		lines = append(lines, k.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,517 @@
+package ssh
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// SudoCommand prefixes the commands that write root owned paths. It
+// does not prompt, the user needs a NOPASSWD rule such as the one of
+// setup.AddSudoerAsUser.
+const SudoCommand = "sudo -n"
+
+// ProgressFunc is called while a file is copied with the bytes that
+// are copied so far and the size of the file. Files that are skipped
+// as unchanged report their size once.
+type ProgressFunc func(file string, written, total int64)
+
+// TransferOptions control the copies of Upload, Download and
+// UploadDir. Writes use sudo when Sudo or Owner is set or when a
+// direct write is denied, so that paths owned by root such as
+// /opt/jolt9/compose can be written. Files whose sha256 checksum
+// matches are not copied again unless Force is set.
+type TransferOptions struct {
+	Mode     os.FileMode  // mode of written files, the mode of the source by default
+	Owner    string       // owner of written files such as `deploy` or `deploy:docker`
+	Sudo     bool         // always write with sudo
+	Force    bool         // copy files even when their checksums match
+	Progress ProgressFunc // reports the progress of each file
+}
+
+// transfer is an sftp session on a connection of the client.
+type transfer struct {
+	conn  *ssh.Client
+	sftp  *sftp.Client
+	close func()
+}
+
+// transfer opens an sftp session on the persistent connection or on a
+// new connection that is closed with the session.
+func (nc *NativeClient) transfer() (*transfer, error) {
+	if conn := nc.persistentClient(); conn != nil {
+		sc, err := sftp.NewClient(conn)
+		if err != nil {
+			return nil, fmt.Errorf("sftp: %w", err)
+		}
+
+		return &transfer{conn: conn, sftp: sc, close: func() { sc.Close() }}, nil
+	}
+
+	conn, sessionInfo, err := nc.Connect(nc.DefaultClientConfig.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	sc, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		sessionInfo.CloseAll()
+		return nil, fmt.Errorf("sftp: %w", err)
+	}
+
+	return &transfer{conn: conn, sftp: sc, close: func() {
+		sc.Close()
+		conn.Close()
+		sessionInfo.CloseAll()
+	}}, nil
+}
+
+// Upload copies the local file to the remote path.
+func (nc *NativeClient) Upload(local, remote string, opts *TransferOptions) error {
+	t, err := nc.transfer()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	return t.upload(local, remote, opts)
+}
+
+// UploadDir copies the files of the local directory to the remote
+// directory, the directories are created as needed. Files that are
+// not regular, such as symlinks, are skipped.
+func (nc *NativeClient) UploadDir(local, remote string, opts *TransferOptions) error {
+	t, err := nc.transfer()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	return filepath.WalkDir(local, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(local, p)
+		if err != nil {
+			return err
+		}
+
+		target := path.Join(remote, filepath.ToSlash(rel))
+		if d.IsDir() {
+			return t.mkdirAll(target, opts)
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		return t.upload(p, target, opts)
+	})
+}
+
+// WriteFile writes data to the remote path with mode. An owner such as
+// `root` or `deploy:docker` is set with sudo.
+func (nc *NativeClient) WriteFile(path string, data []byte, mode os.FileMode, owner string) error {
+	t, err := nc.transfer()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	return t.put(bytes.NewReader(data), int64(len(data)), path, &TransferOptions{Mode: mode, Owner: owner})
+}
+
+// Download copies the remote file to the local path.
+func (nc *NativeClient) Download(remote, local string, opts *TransferOptions) error {
+	t, err := nc.transfer()
+	if err != nil {
+		return err
+	}
+	defer t.close()
+
+	if opts == nil {
+		opts = &TransferOptions{}
+	}
+
+	info, err := t.sftp.Stat(remote)
+	if err != nil {
+		return fmt.Errorf("sftp: %s: %w", remote, err)
+	}
+
+	if !opts.Force {
+		if sum, err := localChecksum(local); err == nil {
+			if remoteSum, err := t.checksum(remote); err == nil && sum == remoteSum {
+				opts.progress(remote, info.Size(), info.Size())
+				return nil
+			}
+		}
+	}
+
+	var src io.Reader
+	f, err := t.sftp.Open(remote)
+	if errors.Is(err, os.ErrPermission) {
+		data, serr := t.sudoOutput(fmt.Sprintf("%s cat -- %s", SudoCommand, quote(remote)))
+		if serr != nil {
+			return fmt.Errorf("sftp: %s: %w", remote, serr)
+		}
+
+		src = bytes.NewReader(data)
+	} else if err != nil {
+		return fmt.Errorf("sftp: %s: %w", remote, err)
+	} else {
+		defer f.Close()
+		src = f
+	}
+
+	mode := opts.Mode
+	if mode == 0 {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(local), "."+filepath.Base(local)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, opts.reader(src, remote, info.Size()))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return fmt.Errorf("sftp: %s: %w", remote, err)
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), local)
+}
+
+func (t *transfer) upload(local, remote string, opts *TransferOptions) error {
+	f, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	o := TransferOptions{}
+	if opts != nil {
+		o = *opts
+	}
+
+	if o.Mode == 0 {
+		o.Mode = info.Mode().Perm()
+	}
+
+	return t.put(f, info.Size(), remote, &o)
+}
+
+// put writes src to remote unless the remote file has the same
+// checksum, then only the mode and owner are updated.
+func (t *transfer) put(src io.ReadSeeker, size int64, remote string, opts *TransferOptions) error {
+	if opts.Mode == 0 {
+		opts.Mode = 0644
+	}
+
+	if !opts.Force {
+		changed, err := t.changed(src, size, remote)
+		if err != nil {
+			return err
+		}
+
+		if !changed {
+			opts.progress(remote, size, size)
+			return t.setAttributes(remote, opts)
+		}
+	}
+
+	if !opts.Sudo && opts.Owner == "" {
+		err := t.write(src, size, remote, opts)
+		if !errors.Is(err, os.ErrPermission) {
+			return err
+		}
+
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	return t.sudoWrite(src, size, remote, opts)
+}
+
+// changed compares the sha256 checksum of src with the remote file.
+func (t *transfer) changed(src io.ReadSeeker, size int64, remote string) (bool, error) {
+	defer src.Seek(0, io.SeekStart)
+	info, err := t.sftp.Stat(remote)
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, os.ErrPermission) {
+		return true, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("sftp: %s: %w", remote, err)
+	}
+
+	if info.Size() != size {
+		return true, nil
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return false, err
+	}
+
+	remoteSum, err := t.checksum(remote)
+	if err != nil {
+		return true, nil
+	}
+
+	return hex.EncodeToString(h.Sum(nil)) != remoteSum, nil
+}
+
+// checksum returns the sha256 checksum of the remote file, files that
+// the user cannot read are hashed with sudo.
+func (t *transfer) checksum(remote string) (string, error) {
+	f, err := t.sftp.Open(remote)
+	if errors.Is(err, os.ErrPermission) {
+		out, err := t.sudoOutput(fmt.Sprintf("%s sha256sum -- %s", SudoCommand, quote(remote)))
+		if err != nil {
+			return "", err
+		}
+
+		sum, _, _ := strings.Cut(string(out), " ")
+		return sum, nil
+	}
+
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// write writes src next to remote and renames it, so that readers
+// never see a partial file.
+func (t *transfer) write(src io.Reader, size int64, remote string, opts *TransferOptions) error {
+	if err := t.sftp.MkdirAll(path.Dir(remote)); err != nil {
+		return err
+	}
+
+	tmp := path.Join(path.Dir(remote), "."+path.Base(remote)+".jolt9-"+randomSuffix())
+	if err := t.copy(src, size, tmp, remote, opts); err != nil {
+		t.sftp.Remove(tmp)
+		return err
+	}
+
+	if err := t.sftp.Chmod(tmp, opts.Mode); err != nil {
+		t.sftp.Remove(tmp)
+		return err
+	}
+
+	if err := t.sftp.PosixRename(tmp, remote); err != nil {
+		t.sftp.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// sudoWrite uploads src to a temp file and installs it at remote with
+// sudo, with the mode and owner of opts.
+func (t *transfer) sudoWrite(src io.Reader, size int64, remote string, opts *TransferOptions) error {
+	tmp := "/tmp/.jolt9-" + randomSuffix()
+	defer t.sftp.Remove(tmp)
+	if err := t.copy(src, size, tmp, remote, opts); err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf("%s mkdir -p -- %s && %s install -m %04o%s -- %s %s",
+		SudoCommand, quote(path.Dir(remote)), SudoCommand, opts.Mode.Perm(), ownerFlags(opts.Owner), quote(tmp), quote(remote))
+	_, err := t.sudoOutput(command)
+	return err
+}
+
+// copy writes src to the new file tmp. The server creates files with
+// its default mode, usually 0644, so tmp is only made readable by the
+// user before any data is written, secrets such as env files and keys
+// are never readable by others while they are staged.
+func (t *transfer) copy(src io.Reader, size int64, tmp, name string, opts *TransferOptions) error {
+	f, err := t.sftp.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		return err
+	}
+
+	if err := f.Chmod(0600); err != nil {
+		f.Close()
+		return err
+	}
+
+	_, err = io.Copy(f, opts.reader(src, name, size))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// setAttributes applies the mode and owner of opts to an unchanged
+// remote file.
+func (t *transfer) setAttributes(remote string, opts *TransferOptions) error {
+	info, err := t.sftp.Stat(remote)
+	if err != nil {
+		return err
+	}
+
+	if info.Mode().Perm() != opts.Mode.Perm() && !opts.Sudo && opts.Owner == "" {
+		err = t.sftp.Chmod(remote, opts.Mode)
+		if !errors.Is(err, os.ErrPermission) {
+			return err
+		}
+	}
+
+	commands := []string{}
+	if info.Mode().Perm() != opts.Mode.Perm() {
+		commands = append(commands, fmt.Sprintf("%s chmod %04o -- %s", SudoCommand, opts.Mode.Perm(), quote(remote)))
+	}
+
+	if opts.Owner != "" {
+		commands = append(commands, fmt.Sprintf("%s chown %s -- %s", SudoCommand, quote(opts.Owner), quote(remote)))
+	}
+
+	if len(commands) == 0 {
+		return nil
+	}
+
+	_, err = t.sudoOutput(strings.Join(commands, " && "))
+	return err
+}
+
+func (t *transfer) mkdirAll(dir string, opts *TransferOptions) error {
+	if opts == nil || (!opts.Sudo && opts.Owner == "") {
+		err := t.sftp.MkdirAll(dir)
+		if !errors.Is(err, os.ErrPermission) {
+			return err
+		}
+	}
+
+	_, err := t.sudoOutput(fmt.Sprintf("%s mkdir -p -- %s", SudoCommand, quote(dir)))
+	return err
+}
+
+// sudoOutput runs a command on the connection and returns its stdout.
+func (t *transfer) sudoOutput(command string) ([]byte, error) {
+	session, err := t.conn.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+	if err := session.Run(command); err != nil {
+		return nil, fmt.Errorf("%s: %w: %s", command, wrapError(err), strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func (o *TransferOptions) progress(file string, written, total int64) {
+	if o != nil && o.Progress != nil {
+		o.Progress(file, written, total)
+	}
+}
+
+// reader reports the progress of the bytes that are read from r.
+func (o *TransferOptions) reader(r io.Reader, file string, total int64) io.Reader {
+	if o == nil || o.Progress == nil {
+		return r
+	}
+
+	return &progressReader{r: r, file: file, total: total, fn: o.Progress}
+}
+
+type progressReader struct {
+	r       io.Reader
+	file    string
+	written int64
+	total   int64
+	fn      ProgressFunc
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.fn(p.file, p.written, p.total)
+	}
+
+	return n, err
+}
+
+func localChecksum(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func ownerFlags(owner string) string {
+	if owner == "" {
+		return ""
+	}
+
+	user, group, ok := strings.Cut(owner, ":")
+	flags := " -o " + quote(user)
+	if ok && group != "" {
+		flags += " -g " + quote(group)
+	}
+
+	return flags
+}
+
+// quote quotes s for a posix shell.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func randomSuffix() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
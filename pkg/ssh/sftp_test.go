@@ -0,0 +1,183 @@
+package ssh_test
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/ssh"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUploadDownload(t *testing.T) {
+	server := newTestServer(t)
+	client, err := ssh.NewClient(testConfig(server, &ssh.Auth{Passwords: []string{"secret"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	local := filepath.Join(dir, "compose.yaml")
+	assert.Nil(t, os.WriteFile(local, []byte("services: {}\n"), 0640))
+
+	written := int64(0)
+	opts := &ssh.TransferOptions{Progress: func(_ string, n, total int64) {
+		written = n
+		assert.Equal(t, int64(13), total)
+	}}
+
+	remote := filepath.Join(dir, "opt", "compose", "compose.yaml")
+	assert.Nil(t, client.Upload(local, remote, opts))
+	assert.Equal(t, int64(13), written)
+	data, err := os.ReadFile(remote)
+	assert.Nil(t, err)
+	assert.Equal(t, "services: {}\n", string(data))
+	before, err := os.Stat(remote)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), before.Mode().Perm())
+
+	// an unchanged file is not written again.
+	written = 0
+	assert.Nil(t, client.Upload(local, remote, opts))
+	assert.Equal(t, int64(13), written)
+	after, err := os.Stat(remote)
+	assert.Nil(t, err)
+	assert.True(t, os.SameFile(before, after))
+
+	opts.Force = true
+	assert.Nil(t, client.Upload(local, remote, opts))
+	after, err = os.Stat(remote)
+	assert.Nil(t, err)
+	assert.False(t, os.SameFile(before, after))
+
+	assert.Nil(t, os.WriteFile(remote, []byte("services: {web: {}}\n"), 0600))
+	assert.Nil(t, os.Chmod(remote, 0600))
+	copied := filepath.Join(dir, "copied.yaml")
+	assert.Nil(t, client.Download(remote, copied, nil))
+	data, err = os.ReadFile(copied)
+	assert.Nil(t, err)
+	assert.Equal(t, "services: {web: {}}\n", string(data))
+	info, err := os.Stat(copied)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.ErrorIs(t, client.Download(filepath.Join(dir, "missing"), copied, nil), os.ErrNotExist)
+}
+
+func TestUploadDir(t *testing.T) {
+	server := newTestServer(t)
+	client, err := ssh.NewClient(testConfig(server, &ssh.Auth{Passwords: []string{"secret"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := t.TempDir()
+	assert.Nil(t, os.MkdirAll(filepath.Join(local, "certs"), 0755))
+	assert.Nil(t, os.WriteFile(filepath.Join(local, "compose.yaml"), []byte("services: {}\n"), 0644))
+	assert.Nil(t, os.WriteFile(filepath.Join(local, ".env"), []byte("TAG=1\n"), 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(local, "certs", "tls.crt"), []byte("cert"), 0644))
+	assert.Nil(t, os.Symlink("compose.yaml", filepath.Join(local, "link.yaml")))
+
+	files := []string{}
+	remote := filepath.Join(t.TempDir(), "compose", "web")
+	assert.Nil(t, client.UploadDir(local, remote, &ssh.TransferOptions{Sudo: true, Progress: func(file string, n, total int64) {
+		if n == total {
+			files = append(files, file)
+		}
+	}}))
+
+	assert.ElementsMatch(t, []string{
+		filepath.Join(remote, ".env"),
+		filepath.Join(remote, "certs", "tls.crt"),
+		filepath.Join(remote, "compose.yaml"),
+	}, files)
+
+	data, err := os.ReadFile(filepath.Join(remote, "certs", "tls.crt"))
+	assert.Nil(t, err)
+	assert.Equal(t, "cert", string(data))
+	info, err := os.Stat(filepath.Join(remote, ".env"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	_, err = os.Lstat(filepath.Join(remote, "link.yaml"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWriteFile(t *testing.T) {
+	server := newTestServer(t)
+	client, err := ssh.NewClient(testConfig(server, &ssh.Auth{Passwords: []string{"secret"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := user.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "compose", ".env")
+	assert.Nil(t, client.WriteFile(file, []byte("TAG=1\n"), 0600, ""))
+	data, err := os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "TAG=1\n", string(data))
+
+	// an owner is set with sudo install.
+	assert.Nil(t, client.WriteFile(file, []byte("TAG=2\n"), 0640, current.Username))
+	data, err = os.ReadFile(file)
+	assert.Nil(t, err)
+	assert.Equal(t, "TAG=2\n", string(data))
+	info, err := os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// the mode of an unchanged file is updated.
+	assert.Nil(t, client.WriteFile(file, []byte("TAG=2\n"), 0600, ""))
+	info, err = os.Stat(file)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	assert.ErrorContains(t, client.WriteFile(file, []byte("TAG=3\n"), 0600, "no-such-user-j9"), "install")
+}
+
+func TestStagedFileMode(t *testing.T) {
+	server := newTestServer(t)
+	client, err := ssh.NewClient(testConfig(server, &ssh.Auth{Passwords: []string{"secret"}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// files are staged next to the target or in /tmp for sudo, the
+	// progress of the first chunk is reported before it is written.
+	dir := t.TempDir()
+	staged := map[string]os.FileMode{}
+	progress := func(pattern string) ssh.ProgressFunc {
+		return func(string, int64, int64) {
+			matches, _ := filepath.Glob(pattern)
+			for _, m := range matches {
+				if info, err := os.Stat(m); err == nil {
+					staged[m] = info.Mode().Perm()
+				}
+			}
+		}
+	}
+
+	local := filepath.Join(dir, "tls.key")
+	assert.Nil(t, os.WriteFile(local, []byte("private key"), 0644))
+	assert.Nil(t, client.Upload(local, filepath.Join(dir, "certs", "tls.key"), &ssh.TransferOptions{
+		Progress: progress(filepath.Join(dir, "certs", ".tls.key.jolt9-*")),
+	}))
+	assert.Nil(t, client.Upload(local, filepath.Join(dir, "sudo", "tls.key"), &ssh.TransferOptions{
+		Sudo:     true,
+		Progress: progress("/tmp/.jolt9-*"),
+	}))
+
+	assert.GreaterOrEqual(t, len(staged), 2)
+	for file, mode := range staged {
+		assert.Equal(t, os.FileMode(0600), mode, file)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "sudo", "tls.key"))
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
@@ -0,0 +1,121 @@
+package age
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+func (v *AgeSecretVault) limit() int {
+	if v.params.History == 0 {
+		return vaults.DefaultHistoryLimit
+	}
+
+	return v.params.History
+}
+
+// record adds the ciphertext data as the next version of key to
+// history, a nil data records that key is deleted. The ciphertext of
+// a secret written before the vault kept history becomes version 1.
+func (v *AgeSecretVault) record(history vaults.History, key string, data []byte) error {
+	if v.params.History < 0 {
+		return nil
+	}
+
+	entries, err := v.store.history(key)
+	if err != nil {
+		return err
+	}
+
+	history[key] = entries
+	old, ok, err := v.store.get(key)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		history.Seed(key, string(old))
+	}
+
+	now := time.Now().UTC()
+	if data == nil {
+		history.Delete(key, now, v.limit())
+		return nil
+	}
+
+	history.Record(key, string(data), now, v.limit())
+	return nil
+}
+
+func (v *AgeSecretVault) getVersion(key, version string) (string, error) {
+	if v.params.History < 0 {
+		return "", fmt.Errorf("%w: the history of the age vault is disabled", vaults.ErrUnsupported)
+	}
+
+	n, err := vaults.ParseVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	entries, err := v.store.history(key)
+	if err != nil {
+		return "", err
+	}
+
+	history := vaults.History{key: entries}
+	entry, ok := history.Get(key, n)
+
+	// secrets written before the vault kept history only have the
+	// current value as version 1.
+	if len(entries) == 0 && n == 1 {
+		return v.GetSecretValue(key, nil)
+	}
+
+	if !ok || entry.Deleted {
+		return "", fmt.Errorf("%w: %s version %d", vaults.ErrSecretNotFound, key, n)
+	}
+
+	ids, err := v.identities()
+	if err != nil {
+		return "", err
+	}
+
+	value, err := decrypt([]byte(entry.Value), ids)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt version %d of secret %s: %w", n, key, err)
+	}
+
+	return value, nil
+}
+
+// ListSecretVersions returns the kept versions of key, oldest first.
+func (v *AgeSecretVault) ListSecretVersions(key string, params *vaults.ListSecretVersionsParams) ([]vaults.SecretVersion, error) {
+	if err := vaults.Err(params); err != nil {
+		return nil, err
+	}
+
+	if v.params.History < 0 {
+		return nil, fmt.Errorf("%w: the history of the age vault is disabled", vaults.ErrUnsupported)
+	}
+
+	entries, err := v.store.history(key)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := vaults.History{key: entries}.Versions(key)
+	if len(versions) == 0 {
+		if _, ok, err := v.store.get(key); err != nil || !ok {
+			if err == nil {
+				err = fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+			}
+
+			return nil, err
+		}
+
+		versions = append(versions, vaults.SecretVersion{Version: 1})
+	}
+
+	return versions, nil
+}
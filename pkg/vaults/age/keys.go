@@ -0,0 +1,176 @@
+package age
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"filippo.io/age/armor"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/jolt9dev/jolt9/pkg/vaults/sops"
+)
+
+// ParseRecipient parses an age X25519 recipient or an ssh public key.
+func ParseRecipient(s string) (age.Recipient, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "age1") {
+		return age.ParseX25519Recipient(s)
+	}
+
+	if strings.HasPrefix(s, "ssh-") {
+		return agessh.ParseRecipient(s)
+	}
+
+	return nil, fmt.Errorf("unknown recipient %q, expected an age1 or ssh public key", s)
+}
+
+func parseRecipients(values []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(values))
+	for _, v := range values {
+		r, err := ParseRecipient(v)
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, r)
+	}
+
+	return recipients, nil
+}
+
+// parseIdentities parses age identities or a pem encoded ssh private
+// key.
+func parseIdentities(data []byte) ([]age.Identity, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN")) {
+		id, err := agessh.ParseIdentity(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return []age.Identity{id}, nil
+	}
+
+	return age.ParseIdentities(bytes.NewReader(data))
+}
+
+// identities reads the identities of the vault. When none is
+// configured the sops key file is used if it exists, which is where
+// `jolt9 vault init` adds keys.
+func (p *AgeSecretVaultParams) identities() ([]age.Identity, error) {
+	ids := []age.Identity{}
+	add := func(source string, data []byte) error {
+		parsed, err := parseIdentities(data)
+		if err != nil {
+			return fmt.Errorf("invalid age identity in %s: %w", source, err)
+		}
+
+		ids = append(ids, parsed...)
+		return nil
+	}
+
+	if p.Key != "" {
+		if err := add("key", []byte(p.Key)); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, file := range p.KeyFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read age key file: %w", err)
+		}
+
+		if err := add(file, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.KeyEnv != "" {
+		key := os.Getenv(p.KeyEnv)
+		if key == "" {
+			return nil, fmt.Errorf("age key env %s is not set", p.KeyEnv)
+		}
+
+		if err := add(p.KeyEnv, []byte(key)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(ids) == 0 {
+		file, err := sops.DefaultAgeKeyFile()
+		if err == nil {
+			if data, err := os.ReadFile(file); err == nil {
+				if err := add(file, data); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil, errors.New("no age identity configured, set key_file or key_env or run `jolt9 vault init`")
+	}
+
+	return ids, nil
+}
+
+// identityRecipients returns the recipients of the X25519 identities.
+func identityRecipients(ids []age.Identity) []string {
+	recipients := []string{}
+	for _, id := range ids {
+		if x, ok := id.(*age.X25519Identity); ok {
+			recipients = append(recipients, x.Recipient().String())
+		}
+	}
+
+	return recipients
+}
+
+func encrypt(value string, recipients []age.Recipient) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	aw := armor.NewWriter(buf)
+	w, err := age.Encrypt(aw, recipients...)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.WriteString(w, value); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decrypt returns an error that wraps vaults.ErrVaultLocked when none
+// of the identities is a recipient of data.
+func decrypt(data []byte, ids []age.Identity) (string, error) {
+	r, err := age.Decrypt(armor.NewReader(bytes.NewReader(data)), ids...)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return "", fmt.Errorf("%w: %w", vaults.ErrVaultLocked, err)
+		}
+
+		return "", err
+	}
+
+	value, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
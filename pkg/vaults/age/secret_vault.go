@@ -0,0 +1,467 @@
+// Package age is a vault driver that encrypts each secret on its own
+// with age. It needs no sops config or key service and works offline
+// with local identity files.
+package age
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+type AgeSecretVault struct {
+	params AgeSecretVaultParams
+	store  store
+	ids    []age.Identity
+}
+
+type AgeSecretVaultParams struct {
+	// Path is the yaml file of the vault or, when Dir is set, the
+	// directory with a `<name>.age` file per secret.
+	Path string
+	Dir  bool
+
+	// Recipients encrypt new vaults. Once the vault is written its
+	// recipients are stored with it and managed with AddRecipients
+	// and RemoveRecipients. When empty the recipients of the
+	// identities are used.
+	Recipients []string
+
+	// Key, KeyFiles and KeyEnv hold the identities that decrypt the
+	// secrets.
+	Key      string
+	KeyFiles []string
+	KeyEnv   string
+
+	// History is the number of versions kept of each secret. Zero
+	// uses vaults.DefaultHistoryLimit, a negative value keeps no
+	// history.
+	History int
+}
+
+var configKeys = []string{"file", "dir", "recipients", "key", "key_file", "key_env", "history"}
+
+func init() {
+	vaults.RegisterDriver("age", OpenVault)
+}
+
+func New(params AgeSecretVaultParams) *AgeSecretVault {
+	var s store = &fileStore{file: params.Path}
+	if params.Dir {
+		s = &dirStore{dir: params.Path}
+	}
+
+	return &AgeSecretVault{params: params, store: s}
+}
+
+// OpenVault is the vault driver for the `age` scheme. The path is a
+// yaml file or a directory when it ends with a `/`, already is a
+// directory or `dir` is set.
+//
+//	vaults:
+//	  default: age:./secrets.age.yaml
+//	  prod:
+//	    uri: age:./secrets/prod/
+//	    with:
+//	      key_file: ~/.config/jolt9/prod.txt
+//
+// Prior values of secrets are kept encrypted in the vault, `history`
+// sets the number of versions per secret and `history: false`
+// disables it.
+func OpenVault(cfg *vaults.VaultConfig) (vaults.SecretVault, error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	path := cfg.Path
+	if cfg.Has("file") {
+		path = cfg.String("file")
+	}
+
+	if path == "" {
+		return nil, fmt.Errorf("age vault requires a path, e.g. age:./secrets.age.yaml")
+	}
+
+	dir := cfg.Bool("dir") || strings.HasSuffix(path, "/")
+	resolved := cfg.ResolvePath(path)
+	if fi, err := os.Stat(resolved); err == nil && fi.IsDir() {
+		dir = true
+	}
+
+	keyFiles := []string{}
+	for _, file := range cfg.Strings("key_file") {
+		keyFiles = append(keyFiles, cfg.ResolvePath(file))
+	}
+
+	return New(AgeSecretVaultParams{
+		Path:       filepath.Clean(resolved),
+		Dir:        dir,
+		Recipients: cfg.Strings("recipients"),
+		Key:        cfg.String("key"),
+		KeyFiles:   keyFiles,
+		KeyEnv:     cfg.String("key_env"),
+		History:    vaults.HistoryLimit(cfg),
+	}), nil
+}
+
+func validateConfig(cfg *vaults.VaultConfig) error {
+	errs := []string{}
+	for _, key := range cfg.Unknown(configKeys...) {
+		errs = append(errs, fmt.Sprintf("unknown option %s", key))
+	}
+
+	for _, key := range []string{"file", "dir", "key", "key_env"} {
+		if !cfg.IsString(key) {
+			errs = append(errs, fmt.Sprintf("%s must be a string", key))
+		}
+	}
+
+	for _, key := range []string{"recipients", "key_file"} {
+		if !cfg.IsStrings(key) {
+			errs = append(errs, fmt.Sprintf("%s must be a string or a list of strings", key))
+		}
+	}
+
+	if err := vaults.ValidateHistory(cfg); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	for _, r := range cfg.Strings("recipients") {
+		if _, err := ParseRecipient(r); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid age options: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (v *AgeSecretVault) identities() ([]age.Identity, error) {
+	if v.ids != nil {
+		return v.ids, nil
+	}
+
+	ids, err := v.params.identities()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", vaults.ErrVaultLocked, err)
+	}
+
+	v.ids = ids
+	return ids, nil
+}
+
+// Recipients returns the recipients that secrets are encrypted to.
+func (v *AgeSecretVault) Recipients() ([]string, error) {
+	recipients, err := v.store.recipients()
+	if err != nil || len(recipients) > 0 {
+		return recipients, err
+	}
+
+	if len(v.params.Recipients) > 0 {
+		return v.params.Recipients, nil
+	}
+
+	ids, err := v.identities()
+	if err != nil {
+		return nil, err
+	}
+
+	recipients = identityRecipients(ids)
+	if len(recipients) == 0 {
+		return nil, errors.New("age vault has no recipients")
+	}
+
+	return recipients, nil
+}
+
+func (v *AgeSecretVault) GetSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
+	if err := vaults.Err(params); err != nil {
+		return "", err
+	}
+
+	if params != nil && params.Version != "" {
+		return v.getVersion(key, params.Version)
+	}
+
+	data, ok, err := v.store.get(key)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+	}
+
+	ids, err := v.identities()
+	if err != nil {
+		return "", err
+	}
+
+	value, err := decrypt(data, ids)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+func (v *AgeSecretVault) BatchGetSecretValues(keys []string, params *vaults.GetSecretValueParams) (map[string]string, error) {
+	values := map[string]string{}
+	for _, key := range keys {
+		value, err := v.GetSecretValue(key, params)
+		if err != nil {
+			return nil, err
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+func (v *AgeSecretVault) MapSecretValues(query map[string]string, params *vaults.GetSecretValueParams) (map[string]string, error) {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	res, err := v.BatchGetSecretValues(keys, params)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for k, name := range query {
+		if val, ok := res[k]; ok {
+			values[name] = val
+		}
+	}
+
+	return values, nil
+}
+
+func (v *AgeSecretVault) ListSecretNames(params *vaults.ListSecretNamesParams) ([]string, error) {
+	if err := vaults.Err(params); err != nil {
+		return nil, err
+	}
+
+	return v.store.list()
+}
+
+func (v *AgeSecretVault) SetSecretValue(key, value string, params *vaults.SetSecretValueParams) error {
+	return v.BatchSetSecretValues(map[string]string{key: value}, params)
+}
+
+// BatchSetSecretValues encrypts the values to the recipients of the
+// vault. Writing secrets does not need an identity.
+func (v *AgeSecretVault) BatchSetSecretValues(values map[string]string, params *vaults.SetSecretValueParams) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	stored, err := v.store.recipients()
+	if err != nil {
+		return err
+	}
+
+	names, err := v.Recipients()
+	if err != nil {
+		return err
+	}
+
+	recipients, err := parseRecipients(names)
+	if err != nil {
+		return err
+	}
+
+	secrets := map[string][]byte{}
+	history := vaults.History{}
+	for key, value := range values {
+		if err := vaults.Err(params); err != nil {
+			return err
+		}
+
+		if err := validName(key); err != nil {
+			return err
+		}
+
+		data, err := encrypt(value, recipients)
+		if err != nil {
+			return err
+		}
+
+		secrets[key] = data
+		if err := v.record(history, key, data); err != nil {
+			return err
+		}
+	}
+
+	// record the recipients with the first secrets of a vault.
+	var save []string
+	if len(stored) == 0 {
+		save = names
+	}
+
+	return v.store.save(save, secrets, history)
+}
+
+func (v *AgeSecretVault) DeleteSecret(key string, params *vaults.DeleteSecretParams) error {
+	if err := vaults.Err(params); err != nil {
+		return err
+	}
+
+	_, ok, err := v.store.get(key)
+	if err != nil || !ok {
+		return err
+	}
+
+	history := vaults.History{}
+	if err := v.record(history, key, nil); err != nil {
+		return err
+	}
+
+	return v.store.save(nil, map[string][]byte{key: nil}, history)
+}
+
+// AddRecipients adds public keys to the vault and re-encrypts every
+// secret so that the new recipients can decrypt them.
+func (v *AgeSecretVault) AddRecipients(recipients ...string) error {
+	current, err := v.Recipients()
+	if err != nil {
+		return err
+	}
+
+	next := append([]string{}, current...)
+	for _, r := range recipients {
+		r = strings.TrimSpace(r)
+		if _, err := ParseRecipient(r); err != nil {
+			return err
+		}
+
+		if !contains(next, r) {
+			next = append(next, r)
+		}
+	}
+
+	return v.rekey(next)
+}
+
+// RemoveRecipients removes public keys from the vault and re-encrypts
+// every secret without them. The last recipient cannot be removed.
+func (v *AgeSecretVault) RemoveRecipients(recipients ...string) error {
+	current, err := v.Recipients()
+	if err != nil {
+		return err
+	}
+
+	next := []string{}
+	for _, r := range current {
+		if !contains(recipients, r) {
+			next = append(next, r)
+		}
+	}
+
+	for _, r := range recipients {
+		if !contains(current, r) {
+			return fmt.Errorf("%s is not a recipient of the vault", r)
+		}
+	}
+
+	if len(next) == 0 {
+		return errors.New("cannot remove the last recipient of the vault")
+	}
+
+	return v.rekey(next)
+}
+
+// rekey decrypts every secret and encrypts it to recipients.
+func (v *AgeSecretVault) rekey(names []string) error {
+	recipients, err := parseRecipients(names)
+	if err != nil {
+		return err
+	}
+
+	keys, err := v.store.list()
+	if err != nil {
+		return err
+	}
+
+	versioned, err := v.store.versioned()
+	if err != nil {
+		return err
+	}
+
+	secrets := map[string][]byte{}
+	history := vaults.History{}
+	if len(keys) > 0 || len(versioned) > 0 {
+		ids, err := v.identities()
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			data, _, err := v.store.get(key)
+			if err != nil {
+				return err
+			}
+
+			secrets[key], err = reencrypt(key, data, ids, recipients)
+			if err != nil {
+				return err
+			}
+		}
+
+		// prior versions are re-encrypted as well, removed recipients
+		// must not be able to read them.
+		for _, key := range versioned {
+			entries, err := v.store.history(key)
+			if err != nil {
+				return err
+			}
+
+			for i, e := range entries {
+				if e.Deleted {
+					continue
+				}
+
+				data, err := reencrypt(key, []byte(e.Value), ids, recipients)
+				if err != nil {
+					return err
+				}
+
+				entries[i].Value = string(data)
+			}
+
+			history[key] = entries
+		}
+	}
+
+	return v.store.save(names, secrets, history)
+}
+
+func reencrypt(key string, data []byte, ids []age.Identity, recipients []age.Recipient) ([]byte, error) {
+	value, err := decrypt(data, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret %s: %w", key, err)
+	}
+
+	return encrypt(value, recipients)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,220 @@
+package age_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	agevault "github.com/jolt9dev/jolt9/pkg/vaults/age"
+	"github.com/stretchr/testify/assert"
+)
+
+func newKey(t *testing.T, dir, name string) (*age.X25519Identity, string) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(id.String()+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return id, file
+}
+
+func TestAgeSecretVault(t *testing.T) {
+	for _, uri := range []string{"age:./secrets.age.yaml", "age:./secrets/"} {
+		t.Run(uri, func(t *testing.T) {
+			dir := t.TempDir()
+			t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join(dir, "missing.txt"))
+			alice, aliceFile := newKey(t, dir, "alice.txt")
+			bob, bobFile := newKey(t, dir, "bob.txt")
+
+			open := func(keyFile string) vaults.SecretVault {
+				vault, err := vaults.OpenIn(configs.VaultItem{
+					Name: "default",
+					Uri:  uri,
+					With: map[string]interface{}{"key_file": keyFile},
+				}, dir)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return vault
+			}
+
+			vault := open(aliceFile)
+			assert.Nil(t, vault.BatchSetSecretValues(map[string]string{
+				"DB_PASSWORD": "s3cret",
+				"api/token":   "t0ken",
+			}, nil))
+
+			v, err := vault.GetSecretValue("api/token", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "t0ken", v)
+
+			_, err = vault.GetSecretValue("MISSING", nil)
+			assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+
+			_, err = vault.GetSecretValue("../alice.txt", nil)
+			assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+			assert.NotNil(t, vault.SetSecretValue("../escape", "x", nil))
+
+			names, err := vault.ListSecretNames(nil)
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"DB_PASSWORD", "api/token"}, names)
+
+			// bob can not decrypt until added as a recipient.
+			_, err = open(bobFile).GetSecretValue("DB_PASSWORD", nil)
+			assert.ErrorIs(t, err, vaults.ErrVaultLocked)
+
+			_, err = vault.GetSecretValue("DB_PASSWORD", &vaults.GetSecretValueParams{Version: "2"})
+			assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err = vault.SetSecretValue("DB_PASSWORD", "changed", &vaults.SetSecretValueParams{
+				OperationParams: vaults.OperationParams{Context: ctx},
+			})
+			assert.ErrorIs(t, err, context.Canceled)
+
+			rv := vault.(vaults.RecipientVault)
+			recipients, err := rv.Recipients()
+			assert.Nil(t, err)
+			assert.Equal(t, []string{alice.Recipient().String()}, recipients)
+
+			assert.Nil(t, rv.AddRecipients(bob.Recipient().String()))
+			v, err = open(bobFile).GetSecretValue("DB_PASSWORD", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "s3cret", v)
+
+			// prior versions are re-encrypted for bob as well.
+			v, err = open(bobFile).GetSecretValue("DB_PASSWORD", &vaults.GetSecretValueParams{Version: "1"})
+			assert.Nil(t, err)
+			assert.Equal(t, "s3cret", v)
+
+			// bob removes alice, whose key no longer decrypts.
+			bobVault := open(bobFile).(vaults.RecipientVault)
+			assert.Nil(t, bobVault.RemoveRecipients(alice.Recipient().String()))
+			_, err = open(aliceFile).GetSecretValue("DB_PASSWORD", nil)
+			assert.NotNil(t, err)
+
+			assert.NotNil(t, bobVault.RemoveRecipients(bob.Recipient().String()))
+			assert.NotNil(t, bobVault.AddRecipients("not-a-key"))
+
+			vault = open(bobFile)
+			assert.Nil(t, vault.DeleteSecret("api/token", nil))
+			assert.Nil(t, vault.DeleteSecret("api/token", nil))
+			names, _ = vault.ListSecretNames(nil)
+			assert.Equal(t, []string{"DB_PASSWORD"}, names)
+		})
+	}
+}
+
+func TestAgeSecretVaultKeyEnv(t *testing.T) {
+	dir := t.TempDir()
+	id, _ := newKey(t, dir, "key.txt")
+	t.Setenv("TEST_AGE_VAULT_KEY", id.String())
+
+	item := configs.VaultItem{Name: "default", Uri: "age:./secrets.yaml?key_env=TEST_AGE_VAULT_KEY"}
+	vault, err := vaults.OpenIn(item, dir)
+	assert.Nil(t, err)
+	assert.Nil(t, vault.SetSecretValue("TOKEN", "plain-token", nil))
+
+	raw, err := os.ReadFile(filepath.Join(dir, "secrets.yaml"))
+	assert.Nil(t, err)
+	assert.Contains(t, string(raw), id.Recipient().String())
+	assert.Contains(t, string(raw), "BEGIN AGE ENCRYPTED FILE")
+	assert.NotContains(t, string(raw), "plain-token")
+
+	vault, _ = vaults.OpenIn(item, dir)
+	v, err := vault.GetSecretValue("TOKEN", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "plain-token", v)
+}
+
+func TestAgeSecretVaultRecipientsOnly(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("SOPS_AGE_KEY_FILE", filepath.Join(dir, "missing.txt"))
+	id, _ := newKey(t, dir, "key.txt")
+
+	// writing only needs the public key.
+	vault, err := vaults.OpenIn(configs.VaultItem{
+		Name: "ci",
+		Uri:  "age:./ci.yaml",
+		With: map[string]interface{}{"recipients": []interface{}{id.Recipient().String()}},
+	}, dir)
+	assert.Nil(t, err)
+	assert.Nil(t, vault.SetSecretValue("TOKEN", "value", nil))
+
+	_, err = vault.GetSecretValue("TOKEN", nil)
+	assert.ErrorContains(t, err, "no age identity")
+	assert.ErrorIs(t, err, vaults.ErrVaultLocked)
+}
+
+func TestAgeSecretVaultHistory(t *testing.T) {
+	for _, uri := range []string{"age:./secrets.age.yaml", "age:./secrets/"} {
+		t.Run(uri, func(t *testing.T) {
+			dir := t.TempDir()
+			_, keyFile := newKey(t, dir, "key.txt")
+			vault, err := vaults.OpenIn(configs.VaultItem{
+				Name: "default",
+				Uri:  uri,
+				With: map[string]interface{}{"key_file": keyFile, "history": 2},
+			}, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, v := range []string{"one", "two", "three"} {
+				assert.Nil(t, vault.SetSecretValue("TOKEN", v, nil))
+			}
+
+			versions, err := vault.(vaults.VersionedVault).ListSecretVersions("TOKEN", nil)
+			assert.Nil(t, err)
+			assert.Len(t, versions, 2)
+			assert.Equal(t, 2, versions[0].Version)
+			assert.False(t, versions[1].Created.IsZero())
+
+			_, err = vault.GetSecretValue("TOKEN", &vaults.GetSecretValueParams{Version: "1"})
+			assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+			v, err := vault.GetSecretValue("TOKEN", &vaults.GetSecretValueParams{Version: "2"})
+			assert.Nil(t, err)
+			assert.Equal(t, "two", v)
+
+			assert.Nil(t, vault.DeleteSecret("TOKEN", nil))
+			assert.Nil(t, vaults.Rollback(vault, "TOKEN", "3", nil))
+			v, err = vault.GetSecretValue("TOKEN", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "three", v)
+
+			names, _ := vault.ListSecretNames(nil)
+			assert.Equal(t, []string{"TOKEN"}, names)
+		})
+	}
+}
+
+func TestAgeInvalidOptions(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"unknown option keyfile":         {"keyfile": "key.txt"},
+		"unknown recipient":              {"recipients": "bob"},
+		"key_file must be a string":      {"key_file": map[string]interface{}{"a": "b"}},
+		"recipients must be a string or": {"recipients": []interface{}{1}},
+	}
+
+	for msg, with := range cases {
+		_, err := vaults.Open(configs.VaultItem{Name: "default", Uri: "age:./secrets.yaml", With: with})
+		assert.ErrorContains(t, err, msg)
+	}
+
+	_, err := vaults.Open(configs.VaultItem{Name: "default", Uri: "age:"})
+	assert.ErrorContains(t, err, "requires a path")
+
+	_, err = agevault.ParseRecipient("age1invalid")
+	assert.NotNil(t, err)
+}
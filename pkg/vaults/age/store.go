@@ -0,0 +1,428 @@
+package age
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"gopkg.in/yaml.v3"
+)
+
+// RecipientsFile holds the recipients of a directory vault, one per
+// line in the format of `age -R`.
+const RecipientsFile = ".recipients"
+
+// HistoryDir is the directory of a directory vault that holds a
+// `<name>.yaml` file with the versions of each secret.
+const HistoryDir = ".history"
+
+var secretName = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-/]*$`)
+
+// store persists the armored ciphertext of each secret, the versions
+// of the secrets and the recipients that the secrets are encrypted to.
+// The values of the versions are armored ciphertexts as well.
+type store interface {
+	recipients() ([]string, error)
+	list() ([]string, error)
+	get(name string) ([]byte, bool, error)
+
+	// history returns the versions of a secret, oldest first.
+	history(name string) ([]vaults.HistoryEntry, error)
+
+	// versioned returns the names of the secrets with versions,
+	// including deleted secrets.
+	versioned() ([]string, error)
+
+	// save writes the recipients, secrets and versions. Secrets with
+	// a nil value are deleted. Recipients are only written when not
+	// nil, the versions of the secrets in history replace the stored
+	// versions.
+	save(recipients []string, secrets map[string][]byte, history vaults.History) error
+}
+
+// validName rejects names that would escape a directory vault.
+func validName(name string) error {
+	if !secretName.MatchString(name) {
+		return fmt.Errorf("invalid secret name %q, names may contain letters, digits, _, -, . and /", name)
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("invalid secret name %q", name)
+		}
+	}
+
+	return nil
+}
+
+// fileDocument is the yaml document of a file vault.
+type fileDocument struct {
+	Recipients []string          `yaml:"recipients"`
+	Secrets    map[string]string `yaml:"secrets"`
+	History    vaults.History    `yaml:"history,omitempty"`
+}
+
+// fileStore keeps every secret in a single yaml file.
+type fileStore struct {
+	file   string
+	doc    *fileDocument
+	loaded bool
+}
+
+func (s *fileStore) load() (*fileDocument, error) {
+	if s.loaded {
+		return s.doc, nil
+	}
+
+	doc := &fileDocument{Secrets: map[string]string{}}
+	data, err := os.ReadFile(s.file)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err == nil {
+		if err := yaml.Unmarshal(data, doc); err != nil {
+			return nil, fmt.Errorf("invalid age vault %s: %w", s.file, err)
+		}
+
+		if doc.Secrets == nil {
+			doc.Secrets = map[string]string{}
+		}
+	}
+
+	s.doc = doc
+	s.loaded = true
+	return doc, nil
+}
+
+func (s *fileStore) recipients() ([]string, error) {
+	doc, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.Recipients, nil
+}
+
+func (s *fileStore) list() ([]string, error) {
+	doc, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(doc.Secrets))
+	for name := range doc.Secrets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *fileStore) get(name string) ([]byte, bool, error) {
+	doc, err := s.load()
+	if err != nil {
+		return nil, false, err
+	}
+
+	v, ok := doc.Secrets[name]
+	return []byte(v), ok, nil
+}
+
+func (s *fileStore) history(name string) ([]vaults.HistoryEntry, error) {
+	doc, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return doc.History[name], nil
+}
+
+func (s *fileStore) versioned() ([]string, error) {
+	doc, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(doc.History))
+	for name := range doc.History {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *fileStore) save(recipients []string, secrets map[string][]byte, history vaults.History) error {
+	doc, err := s.load()
+	if err != nil {
+		return err
+	}
+
+	next := &fileDocument{Recipients: doc.Recipients, Secrets: map[string]string{}, History: vaults.History{}}
+	if recipients != nil {
+		next.Recipients = recipients
+	}
+
+	for k, v := range doc.Secrets {
+		next.Secrets[k] = v
+	}
+
+	for k, v := range doc.History {
+		next.History[k] = v
+	}
+
+	for k, v := range history {
+		next.History[k] = v
+	}
+
+	for k, v := range secrets {
+		if v == nil {
+			delete(next.Secrets, k)
+			continue
+		}
+
+		next.Secrets[k] = string(v)
+	}
+
+	data, err := yaml.Marshal(next)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(s.file, data); err != nil {
+		return err
+	}
+
+	s.doc = next
+	return nil
+}
+
+// dirStore keeps each secret in its own `<name>.age` file. Names with
+// a `/` are stored in sub directories.
+type dirStore struct {
+	dir string
+}
+
+func (s *dirStore) path(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name)+".age")
+}
+
+func (s *dirStore) historyPath(name string) string {
+	return filepath.Join(s.dir, HistoryDir, filepath.FromSlash(name)+".yaml")
+}
+
+func (s *dirStore) recipients() ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, RecipientsFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	recipients := []string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		recipients = append(recipients, line)
+	}
+
+	return recipients, nil
+}
+
+func (s *dirStore) list() ([]string, error) {
+	names := []string{}
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == s.dir {
+				return filepath.SkipDir
+			}
+
+			return err
+		}
+
+		if d.IsDir() && path == filepath.Join(s.dir, HistoryDir) {
+			return filepath.SkipDir
+		}
+
+		if d.IsDir() || !strings.HasSuffix(path, ".age") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, strings.TrimSuffix(filepath.ToSlash(rel), ".age"))
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *dirStore) get(name string) ([]byte, bool, error) {
+	if validName(name) != nil {
+		return nil, false, nil
+	}
+
+	data, err := os.ReadFile(s.path(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, false, nil
+		}
+
+		return nil, false, err
+	}
+
+	return data, true, nil
+}
+
+func (s *dirStore) history(name string) ([]vaults.HistoryEntry, error) {
+	if validName(name) != nil {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(s.historyPath(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	entries := []vaults.HistoryEntry{}
+	if err := yaml.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("invalid history of secret %s: %w", name, err)
+	}
+
+	return entries, nil
+}
+
+func (s *dirStore) versioned() ([]string, error) {
+	root := filepath.Join(s.dir, HistoryDir)
+	names := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && path == root {
+				return filepath.SkipDir
+			}
+
+			return err
+		}
+
+		if d.IsDir() || !strings.HasSuffix(path, ".yaml") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		names = append(names, strings.TrimSuffix(filepath.ToSlash(rel), ".yaml"))
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (s *dirStore) save(recipients []string, secrets map[string][]byte, history vaults.History) error {
+	names := make([]string, 0, len(secrets))
+	for name := range secrets {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		v := secrets[name]
+		if v == nil {
+			if err := os.Remove(s.path(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+
+			continue
+		}
+
+		if err := writeFile(s.path(name), v); err != nil {
+			return err
+		}
+	}
+
+	names = names[:0]
+	for name := range history {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		data, err := yaml.Marshal(history[name])
+		if err != nil {
+			return err
+		}
+
+		if err := writeFile(s.historyPath(name), data); err != nil {
+			return err
+		}
+	}
+
+	// the recipients are written last so that they still match the
+	// secrets when writing a secret fails.
+	if recipients != nil {
+		data := strings.Join(recipients, "\n") + "\n"
+		return writeFile(filepath.Join(s.dir, RecipientsFile), []byte(data))
+	}
+
+	return nil
+}
+
+// writeFile replaces file through a temp file so that a failed write
+// does not leave a truncated vault behind.
+func writeFile(file string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
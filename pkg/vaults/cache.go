@@ -0,0 +1,323 @@
+package vaults
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultCacheTTL is the TTL of cached secrets when CacheOptions.TTL
+// is zero.
+const DefaultCacheTTL = 5 * time.Minute
+
+// CacheOptions configures a CachedVault.
+type CacheOptions struct {
+	// TTL is how long a secret is cached. Zero uses DefaultCacheTTL,
+	// a negative TTL caches secrets until they are invalidated.
+	TTL time.Duration
+
+	// Prefetch are keys that are read with a single
+	// BatchGetSecretValues on the first read of the vault.
+	Prefetch []string
+
+	// Now returns the current time, it defaults to time.Now.
+	Now func() time.Time
+}
+
+// CacheStats counts the reads served from the cache and the reads
+// of the wrapped vault.
+type CacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+}
+
+type cacheEntry struct {
+	value   string
+	expires time.Time
+}
+
+// CachedVault caches the reads of a vault and is safe for concurrent
+// use. Calls to the wrapped vault are serialized, so vaults that are
+// not safe for concurrent use, such as the sops vault, may be shared
+// between goroutines through it. Reads of a version bypass the cache.
+type CachedVault struct {
+	vault SecretVault
+	opts  CacheOptions
+
+	mux        sync.RWMutex
+	entries    map[string]cacheEntry
+	names      []string
+	namesUntil time.Time
+	prefetched bool
+
+	// call serializes the calls to vault.
+	call sync.Mutex
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+// Cached wraps vault with a read cache. Errors are not cached, so a
+// cancelled read or a missing secret is read again on the next call.
+func Cached(vault SecretVault, opts CacheOptions) *CachedVault {
+	if opts.TTL == 0 {
+		opts.TTL = DefaultCacheTTL
+	}
+
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+
+	return &CachedVault{vault: vault, opts: opts, entries: map[string]cacheEntry{}}
+}
+
+// Unwrap returns the wrapped vault.
+func (c *CachedVault) Unwrap() SecretVault {
+	return c.vault
+}
+
+// Unwrap returns the innermost vault of decorators such as
+// CachedVault, e.g. to check for RecipientVault.
+func Unwrap(vault SecretVault) SecretVault {
+	for {
+		u, ok := vault.(interface{ Unwrap() SecretVault })
+		if !ok {
+			return vault
+		}
+
+		vault = u.Unwrap()
+	}
+}
+
+func (c *CachedVault) Stats() CacheStats {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	return CacheStats{Hits: c.hits.Load(), Misses: c.misses.Load(), Entries: len(c.entries)}
+}
+
+// Invalidate removes keys from the cache, without keys the whole
+// cache is cleared.
+func (c *CachedVault) Invalidate(keys ...string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.names = nil
+	if len(keys) == 0 {
+		c.entries = map[string]cacheEntry{}
+		return
+	}
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+func (c *CachedVault) expires() time.Time {
+	if c.opts.TTL < 0 {
+		return time.Time{}
+	}
+
+	return c.opts.Now().Add(c.opts.TTL)
+}
+
+func (c *CachedVault) lookup(key string) (string, bool) {
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+	e, ok := c.entries[key]
+	if !ok || (!e.expires.IsZero() && !c.opts.Now().Before(e.expires)) {
+		return "", false
+	}
+
+	return e.value, true
+}
+
+func (c *CachedVault) store(values map[string]string) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	expires := c.expires()
+	for k, v := range values {
+		c.entries[k] = cacheEntry{value: v, expires: expires}
+	}
+}
+
+// prefetch reads CacheOptions.Prefetch once. Errors are ignored, the
+// keys are then read one by one.
+func (c *CachedVault) prefetch(params *GetSecretValueParams) {
+	c.mux.Lock()
+	if c.prefetched || len(c.opts.Prefetch) == 0 {
+		c.mux.Unlock()
+		return
+	}
+
+	c.prefetched = true
+	c.mux.Unlock()
+	_ = c.Prefetch(c.opts.Prefetch, params)
+}
+
+// Prefetch reads the keys that are not cached with a single
+// BatchGetSecretValues of the wrapped vault.
+func (c *CachedVault) Prefetch(keys []string, params *GetSecretValueParams) error {
+	_, err := c.BatchGetSecretValues(keys, params)
+	return err
+}
+
+func (c *CachedVault) GetSecretValue(key string, params *GetSecretValueParams) (string, error) {
+	if params != nil && params.Version != "" {
+		c.call.Lock()
+		defer c.call.Unlock()
+		return c.vault.GetSecretValue(key, params)
+	}
+
+	c.prefetch(params)
+	if v, ok := c.lookup(key); ok {
+		c.hits.Add(1)
+		return v, nil
+	}
+
+	c.call.Lock()
+	defer c.call.Unlock()
+
+	// another goroutine may have read the key while waiting.
+	if v, ok := c.lookup(key); ok {
+		c.hits.Add(1)
+		return v, nil
+	}
+
+	c.misses.Add(1)
+	v, err := c.vault.GetSecretValue(key, params)
+	if err != nil {
+		return "", err
+	}
+
+	c.store(map[string]string{key: v})
+	return v, nil
+}
+
+// BatchGetSecretValues returns the cached keys and reads the others
+// with one call to the wrapped vault.
+func (c *CachedVault) BatchGetSecretValues(keys []string, params *GetSecretValueParams) (map[string]string, error) {
+	if params != nil && params.Version != "" {
+		c.call.Lock()
+		defer c.call.Unlock()
+		return c.vault.BatchGetSecretValues(keys, params)
+	}
+
+	values := map[string]string{}
+	missing := func() []string {
+		keys2 := []string{}
+		for _, key := range keys {
+			if _, ok := values[key]; ok {
+				continue
+			}
+
+			if v, ok := c.lookup(key); ok {
+				values[key] = v
+				c.hits.Add(1)
+				continue
+			}
+
+			keys2 = append(keys2, key)
+		}
+
+		return keys2
+	}
+
+	if len(missing()) == 0 {
+		return values, nil
+	}
+
+	c.call.Lock()
+	defer c.call.Unlock()
+	rest := missing()
+	if len(rest) == 0 {
+		return values, nil
+	}
+
+	c.misses.Add(int64(len(rest)))
+	res, err := c.vault.BatchGetSecretValues(rest, params)
+	if err != nil {
+		return nil, err
+	}
+
+	c.store(res)
+	for k, v := range res {
+		values[k] = v
+	}
+
+	return values, nil
+}
+
+func (c *CachedVault) MapSecretValues(query map[string]string, params *GetSecretValueParams) (map[string]string, error) {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	res, err := c.BatchGetSecretValues(keys, params)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for k, name := range query {
+		if v, ok := res[k]; ok {
+			values[name] = v
+		}
+	}
+
+	return values, nil
+}
+
+// ListSecretNames caches the names with the TTL of the secrets.
+func (c *CachedVault) ListSecretNames(params *ListSecretNamesParams) ([]string, error) {
+	c.mux.RLock()
+	names, until := c.names, c.namesUntil
+	c.mux.RUnlock()
+	if names != nil && (until.IsZero() || c.opts.Now().Before(until)) {
+		c.hits.Add(1)
+		return append([]string{}, names...), nil
+	}
+
+	c.call.Lock()
+	defer c.call.Unlock()
+	c.misses.Add(1)
+	names, err := c.vault.ListSecretNames(params)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mux.Lock()
+	c.names = append([]string{}, names...)
+	c.namesUntil = c.expires()
+	c.mux.Unlock()
+	return names, nil
+}
+
+func (c *CachedVault) SetSecretValue(key, value string, params *SetSecretValueParams) error {
+	c.call.Lock()
+	defer c.call.Unlock()
+	defer c.Invalidate(key)
+	return c.vault.SetSecretValue(key, value, params)
+}
+
+func (c *CachedVault) BatchSetSecretValues(values map[string]string, params *SetSecretValueParams) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	c.call.Lock()
+	defer c.call.Unlock()
+	if len(keys) > 0 {
+		defer c.Invalidate(keys...)
+	}
+
+	return c.vault.BatchSetSecretValues(values, params)
+}
+
+func (c *CachedVault) DeleteSecret(key string, params *DeleteSecretParams) error {
+	c.call.Lock()
+	defer c.call.Unlock()
+	defer c.Invalidate(key)
+	return c.vault.DeleteSecret(key, params)
+}
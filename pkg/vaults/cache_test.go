@@ -0,0 +1,106 @@
+package vaults_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingVault is a memory vault that counts the reads.
+type countingVault struct {
+	vaults.SecretVault
+	data    map[string]string
+	gets    int
+	batches int
+}
+
+func (v *countingVault) GetSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
+	v.gets++
+	if s, ok := v.data[key]; ok {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+}
+
+func (v *countingVault) BatchGetSecretValues(keys []string, params *vaults.GetSecretValueParams) (map[string]string, error) {
+	v.batches++
+	values := map[string]string{}
+	for _, key := range keys {
+		if s, ok := v.data[key]; ok {
+			values[key] = s
+		}
+	}
+
+	return values, nil
+}
+
+func (v *countingVault) SetSecretValue(key, value string, params *vaults.SetSecretValueParams) error {
+	v.data[key] = value
+	return nil
+}
+
+func (v *countingVault) DeleteSecret(key string, params *vaults.DeleteSecretParams) error {
+	delete(v.data, key)
+	return nil
+}
+
+func TestCachedVault(t *testing.T) {
+	now := time.Now()
+	inner := &countingVault{data: map[string]string{"a": "1", "b": "2"}}
+	cache := vaults.Cached(inner, vaults.CacheOptions{
+		TTL:      time.Minute,
+		Prefetch: []string{"a", "b"},
+		Now:      func() time.Time { return now },
+	})
+
+	v, err := cache.GetSecretValue("a", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "1", v)
+	v, err = cache.GetSecretValue("b", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "2", v)
+	assert.Equal(t, 1, inner.batches)
+	assert.Equal(t, 0, inner.gets)
+	assert.Equal(t, vaults.CacheStats{Hits: 2, Misses: 2, Entries: 2}, cache.Stats())
+
+	assert.Nil(t, cache.SetSecretValue("a", "3", nil))
+	v, _ = cache.GetSecretValue("a", nil)
+	assert.Equal(t, "3", v)
+	assert.Equal(t, 1, inner.gets)
+
+	now = now.Add(2 * time.Minute)
+	v, _ = cache.GetSecretValue("b", nil)
+	assert.Equal(t, "2", v)
+	assert.Equal(t, 2, inner.gets)
+
+	assert.Nil(t, cache.DeleteSecret("b", nil))
+	_, err = cache.GetSecretValue("b", nil)
+	assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+
+	assert.Same(t, inner, vaults.Unwrap(cache))
+}
+
+func TestCachedVaultConcurrent(t *testing.T) {
+	inner := &countingVault{data: map[string]string{"a": "1"}}
+	cache := vaults.Cached(inner, vaults.CacheOptions{})
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := cache.GetSecretValue("a", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "1", v)
+		}()
+	}
+
+	wg.Wait()
+	assert.Equal(t, 1, inner.gets)
+	assert.Equal(t, int64(19), cache.Stats().Hits)
+}
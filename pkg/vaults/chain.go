@@ -0,0 +1,330 @@
+package vaults
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReferencePrefix starts a secret value that refers to a secret of
+// another vault of the chain, e.g. `vault://prod-sops/PG_PASSWORD`.
+const ReferencePrefix = "vault://"
+
+// MaxReferenceDepth is the number of references that a chain follows
+// for one read, it stops reference cycles.
+const MaxReferenceDepth = 8
+
+// ChainLink is a named vault of a chain.
+type ChainLink struct {
+	Name  string
+	Vault SecretVault
+}
+
+// SecretSource is a secret name with the vaults of a chain that hold
+// it. The first vault resolves the secret, it shadows the others.
+type SecretSource struct {
+	Name   string
+	Vaults []string
+}
+
+// Chain reads secrets through an ordered list of vaults and writes
+// them to its primary vault. Keys may be qualified with the name of a
+// vault, `prod-sops:PG_PASSWORD` only reads and writes the vault
+// `prod-sops`. A value that is a reference such as
+// `vault://prod-sops/PG_PASSWORD` is replaced with the secret it
+// refers to.
+type Chain struct {
+	links   []ChainLink
+	primary string
+}
+
+// NewChain creates a chain of links. Writes go to the vault named
+// primary, an empty primary uses the first link.
+func NewChain(links []ChainLink, primary string) (*Chain, error) {
+	if len(links) == 0 {
+		return nil, errors.New("a vault chain needs at least one vault")
+	}
+
+	seen := map[string]bool{}
+	for _, l := range links {
+		if seen[l.Name] {
+			return nil, fmt.Errorf("vault %s is in the chain twice", l.Name)
+		}
+
+		seen[l.Name] = true
+	}
+
+	if primary == "" {
+		primary = links[0].Name
+	}
+
+	if !seen[primary] {
+		return nil, fmt.Errorf("primary vault %s is not in the chain", primary)
+	}
+
+	return &Chain{links: links, primary: primary}, nil
+}
+
+// Names returns the names of the vaults in the order they are read.
+func (c *Chain) Names() []string {
+	names := make([]string, 0, len(c.links))
+	for _, l := range c.links {
+		names = append(names, l.Name)
+	}
+
+	return names
+}
+
+// Primary returns the name of the vault that writes go to.
+func (c *Chain) Primary() string {
+	return c.primary
+}
+
+// Unwrap returns the primary vault, so that key management such as
+// RecipientVault applies to the vault that is written to.
+func (c *Chain) Unwrap() SecretVault {
+	v, _ := c.vault(c.primary)
+	return v
+}
+
+func (c *Chain) vault(name string) (SecretVault, bool) {
+	for _, l := range c.links {
+		if l.Name == name {
+			return l.Vault, true
+		}
+	}
+
+	return nil, false
+}
+
+// Split returns the vault name and key of a qualified key. Keys whose
+// prefix is not a vault of the chain are not qualified, so keys of
+// yaml vaults such as `db:password` keep working.
+func (c *Chain) Split(key string) (string, string) {
+	name, rest, ok := strings.Cut(key, ":")
+	if !ok {
+		return "", key
+	}
+
+	if _, found := c.vault(name); !found {
+		return "", key
+	}
+
+	return name, rest
+}
+
+// ParseReference returns the vault name and key of a value such as
+// `vault://prod-sops/PG_PASSWORD`.
+func ParseReference(value string) (string, string, bool) {
+	if !strings.HasPrefix(value, ReferencePrefix) {
+		return "", "", false
+	}
+
+	name, key, ok := strings.Cut(value[len(ReferencePrefix):], "/")
+	if !ok || name == "" || key == "" {
+		return "", "", false
+	}
+
+	return name, key, true
+}
+
+func (c *Chain) GetSecretValue(key string, params *GetSecretValueParams) (string, error) {
+	return c.get(key, params, 0)
+}
+
+func (c *Chain) get(key string, params *GetSecretValueParams, depth int) (string, error) {
+	name, key := c.Split(key)
+	var value string
+	if name != "" {
+		v, _ := c.vault(name)
+		s, err := v.GetSecretValue(key, params)
+		if err != nil {
+			return "", fmt.Errorf("vault %s: %w", name, err)
+		}
+
+		value = s
+	} else {
+		found := false
+		for _, l := range c.links {
+			s, err := l.Vault.GetSecretValue(key, params)
+			if errors.Is(err, ErrSecretNotFound) {
+				continue
+			}
+
+			if err != nil {
+				return "", fmt.Errorf("vault %s: %w", l.Name, err)
+			}
+
+			value, found = s, true
+			break
+		}
+
+		if !found {
+			return "", fmt.Errorf("%w: %s is in none of the vaults %s", ErrSecretNotFound, key, strings.Join(c.Names(), ", "))
+		}
+	}
+
+	refName, refKey, ok := ParseReference(value)
+	if !ok {
+		return value, nil
+	}
+
+	if depth >= MaxReferenceDepth {
+		return "", fmt.Errorf("secret %s: more than %d vault references, the references may form a cycle", key, MaxReferenceDepth)
+	}
+
+	if _, found := c.vault(refName); !found {
+		return "", fmt.Errorf("secret %s refers to vault %s, which is not in the chain", key, refName)
+	}
+
+	// versions only apply to the secret that was asked for.
+	var refParams *GetSecretValueParams
+	if params != nil {
+		refParams = &GetSecretValueParams{OperationParams: params.OperationParams}
+	}
+
+	return c.get(refName+":"+refKey, refParams, depth+1)
+}
+
+func (c *Chain) BatchGetSecretValues(keys []string, params *GetSecretValueParams) (map[string]string, error) {
+	values := map[string]string{}
+	for _, key := range keys {
+		v, err := c.GetSecretValue(key, params)
+		if err != nil {
+			return nil, err
+		}
+
+		values[key] = v
+	}
+
+	return values, nil
+}
+
+// MapSecretValues reads the keys of query, which may be qualified,
+// and returns the values by the names in query.
+func (c *Chain) MapSecretValues(query map[string]string, params *GetSecretValueParams) (map[string]string, error) {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	res, err := c.BatchGetSecretValues(keys, params)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for k, name := range query {
+		if v, ok := res[k]; ok {
+			values[name] = v
+		}
+	}
+
+	return values, nil
+}
+
+// ListSecretNames returns the sorted names of the secrets of every
+// vault of the chain.
+func (c *Chain) ListSecretNames(params *ListSecretNamesParams) ([]string, error) {
+	sources, err := c.ListSecretSources(params)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(sources))
+	for _, s := range sources {
+		names = append(names, s.Name)
+	}
+
+	return names, nil
+}
+
+// ListSecretSources returns the sorted names of the secrets of every
+// vault with the vaults that hold them, in the order of the chain.
+func (c *Chain) ListSecretSources(params *ListSecretNamesParams) ([]SecretSource, error) {
+	byName := map[string]*SecretSource{}
+	for _, l := range c.links {
+		names, err := l.Vault.ListSecretNames(params)
+		if err != nil {
+			return nil, fmt.Errorf("vault %s: %w", l.Name, err)
+		}
+
+		for _, name := range names {
+			s, ok := byName[name]
+			if !ok {
+				s = &SecretSource{Name: name}
+				byName[name] = s
+			}
+
+			s.Vaults = append(s.Vaults, l.Name)
+		}
+	}
+
+	sources := make([]SecretSource, 0, len(byName))
+	for _, s := range byName {
+		sources = append(sources, *s)
+	}
+
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+	return sources, nil
+}
+
+// target returns the vault that a write of key goes to.
+func (c *Chain) target(key string) (string, SecretVault, string) {
+	name, key := c.Split(key)
+	if name == "" {
+		name = c.primary
+	}
+
+	v, _ := c.vault(name)
+	return name, v, key
+}
+
+// SetSecretValue writes to the primary vault unless key is qualified.
+func (c *Chain) SetSecretValue(key, value string, params *SetSecretValueParams) error {
+	name, vault, key := c.target(key)
+	if err := vault.SetSecretValue(key, value, params); err != nil {
+		return fmt.Errorf("vault %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// BatchSetSecretValues writes the values with one batch per vault.
+func (c *Chain) BatchSetSecretValues(values map[string]string, params *SetSecretValueParams) error {
+	batches := map[string]map[string]string{}
+	for k, v := range values {
+		name, _, key := c.target(k)
+		if batches[name] == nil {
+			batches[name] = map[string]string{}
+		}
+
+		batches[name][key] = v
+	}
+
+	for _, l := range c.links {
+		batch, ok := batches[l.Name]
+		if !ok {
+			continue
+		}
+
+		if err := l.Vault.BatchSetSecretValues(batch, params); err != nil {
+			return fmt.Errorf("vault %s: %w", l.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// DeleteSecret deletes from the primary vault unless key is
+// qualified. Other vaults of the chain that hold the key still
+// resolve it.
+func (c *Chain) DeleteSecret(key string, params *DeleteSecretParams) error {
+	name, vault, key := c.target(key)
+	if err := vault.DeleteSecret(key, params); err != nil {
+		return fmt.Errorf("vault %s: %w", name, err)
+	}
+
+	return nil
+}
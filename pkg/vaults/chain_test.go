@@ -0,0 +1,99 @@
+package vaults_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/stretchr/testify/assert"
+)
+
+type mapVault struct {
+	vaults.SecretVault
+	data map[string]string
+}
+
+func (m *mapVault) GetSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
+	if v, ok := m.data[key]; ok {
+		return v, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+}
+
+func (m *mapVault) ListSecretNames(params *vaults.ListSecretNamesParams) ([]string, error) {
+	names := []string{}
+	for k := range m.data {
+		names = append(names, k)
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (m *mapVault) SetSecretValue(key, value string, params *vaults.SetSecretValueParams) error {
+	m.data[key] = value
+	return nil
+}
+
+func (m *mapVault) BatchSetSecretValues(values map[string]string, params *vaults.SetSecretValueParams) error {
+	for k, v := range values {
+		m.data[k] = v
+	}
+
+	return nil
+}
+
+func (m *mapVault) DeleteSecret(key string, params *vaults.DeleteSecretParams) error {
+	delete(m.data, key)
+	return nil
+}
+
+func TestChain(t *testing.T) {
+	local := &mapVault{data: map[string]string{"A": "local", "REF": "vault://prod/B"}}
+	prod := &mapVault{data: map[string]string{"A": "prod", "B": "b", "LOOP": "vault://prod/LOOP"}}
+	chain, err := vaults.NewChain([]vaults.ChainLink{{Name: "local", Vault: local}, {Name: "prod", Vault: prod}}, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := chain.GetSecretValue("A", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "local", v)
+
+	v, _ = chain.GetSecretValue("prod:A", nil)
+	assert.Equal(t, "prod", v)
+
+	v, _ = chain.GetSecretValue("REF", nil)
+	assert.Equal(t, "b", v)
+
+	_, err = chain.GetSecretValue("LOOP", nil)
+	assert.ErrorContains(t, err, "cycle")
+
+	_, err = chain.GetSecretValue("MISSING", nil)
+	assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+
+	values, err := chain.MapSecretValues(map[string]string{"prod:A": "PROD_A", "B": "B"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"PROD_A": "prod", "B": "b"}, values)
+
+	sources, err := chain.ListSecretSources(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, vaults.SecretSource{Name: "A", Vaults: []string{"local", "prod"}}, sources[0])
+
+	assert.Nil(t, chain.SetSecretValue("C", "c", nil))
+	assert.Equal(t, "c", prod.data["C"])
+	assert.Nil(t, chain.BatchSetSecretValues(map[string]string{"local:D": "d", "E": "e"}, nil))
+	assert.Equal(t, "d", local.data["D"])
+	assert.Equal(t, "e", prod.data["E"])
+
+	assert.Nil(t, chain.DeleteSecret("A", nil))
+	v, _ = chain.GetSecretValue("A", nil)
+	assert.Equal(t, "local", v)
+
+	assert.Same(t, prod, vaults.Unwrap(chain))
+
+	_, err = vaults.NewChain([]vaults.ChainLink{{Name: "local", Vault: local}}, "prod")
+	assert.NotNil(t, err)
+}
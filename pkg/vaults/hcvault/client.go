@@ -0,0 +1,277 @@
+package hcvault
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+const (
+	AuthToken   = "token"
+	AuthAppRole = "approle"
+	AuthEnv     = "env"
+
+	DefaultAppRolePath = "approle"
+)
+
+// Auth configures how the client gets its token. With AuthEnv the
+// token is read from VAULT_TOKEN or, when it is not set, an AppRole
+// login is done with VAULT_ROLE_ID and VAULT_SECRET_ID.
+type Auth struct {
+	Method string
+
+	Token string
+
+	RoleId      string
+	SecretId    string
+	AppRolePath string
+}
+
+// Client is a minimal client of the Vault HTTP API.
+type Client struct {
+	Address   string
+	Namespace string
+	Auth      Auth
+	Http      *http.Client
+
+	token string
+	mux   sync.Mutex
+}
+
+// apiError is a non 2xx response of the Vault API.
+type apiError struct {
+	Method string
+	Path   string
+	Status int
+	Errors []string
+}
+
+func (e *apiError) Error() string {
+	msg := http.StatusText(e.Status)
+	if len(e.Errors) > 0 {
+		msg = strings.Join(e.Errors, "; ")
+	}
+
+	return fmt.Sprintf("vault %s %s: %d %s", e.Method, e.Path, e.Status, msg)
+}
+
+// Unwrap maps denied requests and a sealed vault to
+// vaults.ErrVaultLocked.
+func (e *apiError) Unwrap() error {
+	switch e.Status {
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusServiceUnavailable:
+		return vaults.ErrVaultLocked
+	default:
+		return nil
+	}
+}
+
+func NewClient(address string, auth Auth, insecure bool) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true} //nolint:gosec
+	}
+
+	return &Client{
+		Address: strings.TrimSuffix(address, "/"),
+		Auth:    auth,
+		Http:    &http.Client{Transport: transport},
+	}
+}
+
+// Do sends a request to /v1/<path> and decodes the json response into
+// out when it is not nil. A 403 after an AppRole login logs in again
+// once, the token may have expired.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, body interface{}, out interface{}) error {
+	token, err := c.login(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	err = c.send(ctx, method, path, query, token, body, out)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusForbidden && c.canRelogin() {
+		if token, err = c.login(ctx, true); err != nil {
+			return err
+		}
+
+		return c.send(ctx, method, path, query, token, body, out)
+	}
+
+	return err
+}
+
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, token string, body interface{}, out interface{}) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	u := c.Address + "/v1/" + strings.TrimPrefix(path, "/")
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+
+	res, err := c.Http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		apiErr := &apiError{Method: method, Path: path, Status: res.StatusCode}
+		var payload struct {
+			Errors []string `json:"errors"`
+		}
+
+		if json.Unmarshal(data, &payload) == nil {
+			apiErr.Errors = payload.Errors
+		}
+
+		return apiErr
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+func (c *Client) canRelogin() bool {
+	method, _, _ := c.Auth.resolve()
+	return method == AuthAppRole
+}
+
+// resolve returns the effective auth method and the values from the
+// env when the method is AuthEnv.
+func (a Auth) resolve() (string, Auth, error) {
+	switch a.Method {
+	case AuthToken:
+		if a.Token == "" {
+			return "", a, errors.New("token auth requires a token")
+		}
+
+		return AuthToken, a, nil
+	case AuthAppRole:
+		if a.RoleId == "" || a.SecretId == "" {
+			return "", a, errors.New("approle auth requires a role_id and a secret_id")
+		}
+
+		return AuthAppRole, a, nil
+	case AuthEnv, "":
+		if a.Method == "" {
+			if a.Token != "" {
+				return AuthToken, a, nil
+			}
+
+			if a.RoleId != "" {
+				return Auth{Method: AuthAppRole, RoleId: a.RoleId, SecretId: a.SecretId, AppRolePath: a.AppRolePath}.resolve()
+			}
+		}
+
+		env := Auth{
+			Token:       os.Getenv("VAULT_TOKEN"),
+			RoleId:      os.Getenv("VAULT_ROLE_ID"),
+			SecretId:    os.Getenv("VAULT_SECRET_ID"),
+			AppRolePath: a.AppRolePath,
+		}
+
+		if env.Token != "" {
+			env.Method = AuthToken
+			return AuthToken, env, nil
+		}
+
+		if env.RoleId != "" {
+			env.Method = AuthAppRole
+			return env.resolve()
+		}
+
+		return "", a, errors.New("no vault credentials, set VAULT_TOKEN or VAULT_ROLE_ID and VAULT_SECRET_ID")
+	default:
+		return "", a, fmt.Errorf("unknown auth method %q, expected token, approle or env", a.Method)
+	}
+}
+
+func (c *Client) login(ctx context.Context, force bool) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.token != "" && !force {
+		return c.token, nil
+	}
+
+	method, auth, err := c.Auth.resolve()
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", vaults.ErrVaultLocked, err)
+	}
+
+	if method == AuthToken {
+		c.token = auth.Token
+		return c.token, nil
+	}
+
+	path := auth.AppRolePath
+	if path == "" {
+		path = DefaultAppRolePath
+	}
+
+	var res struct {
+		Auth struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+
+	body := map[string]string{"role_id": auth.RoleId, "secret_id": auth.SecretId}
+	if err := c.send(ctx, http.MethodPost, "auth/"+strings.Trim(path, "/")+"/login", nil, "", body, &res); err != nil {
+		return "", fmt.Errorf("%w: approle login failed: %w", vaults.ErrVaultLocked, err)
+	}
+
+	if res.Auth.ClientToken == "" {
+		return "", errors.New("approle login returned no token")
+	}
+
+	c.token = res.Auth.ClientToken
+	return c.token, nil
+}
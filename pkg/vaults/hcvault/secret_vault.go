@@ -0,0 +1,401 @@
+// Package hcvault is a vault driver for the HashiCorp Vault KV v2
+// secrets engine. Each secret is a KV path below the path of the
+// vault with its value in a single field.
+package hcvault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+const (
+	DefaultMount = "secret"
+	DefaultField = "value"
+
+	DefaultTimeout = 30 * time.Second
+)
+
+type HcVaultSecretVault struct {
+	client *Client
+	mount  string
+	prefix string
+	field  string
+}
+
+type HcVaultSecretVaultParams struct {
+	Address   string
+	Namespace string
+
+	// Mount is the path of the KV v2 engine and Path the prefix of
+	// the secrets within it.
+	Mount string
+	Path  string
+
+	// Field is the field of the KV secret that holds the value.
+	Field string
+
+	Auth     Auth
+	Insecure bool
+
+	// Timeout limits each request, DefaultTimeout when zero. The
+	// context of the params can set a shorter deadline.
+	Timeout time.Duration
+}
+
+var configKeys = []string{
+	"address", "namespace", "mount", "path", "field", "insecure", "timeout",
+	"auth", "token", "token_file", "role_id", "secret_id", "approle_path",
+}
+
+func init() {
+	vaults.RegisterDriver("hcvault", OpenVault)
+}
+
+func New(params HcVaultSecretVaultParams) *HcVaultSecretVault {
+	if params.Mount == "" {
+		params.Mount = DefaultMount
+	}
+
+	if params.Field == "" {
+		params.Field = DefaultField
+	}
+
+	if params.Timeout == 0 {
+		params.Timeout = DefaultTimeout
+	}
+
+	client := NewClient(params.Address, params.Auth, params.Insecure)
+	client.Namespace = params.Namespace
+	client.Http.Timeout = params.Timeout
+	return &HcVaultSecretVault{
+		client: client,
+		mount:  strings.Trim(params.Mount, "/"),
+		prefix: strings.Trim(params.Path, "/"),
+		field:  params.Field,
+	}
+}
+
+// OpenVault is the vault driver for the `hcvault` scheme. The address
+// is the host of the uri, the `address` option or $VAULT_ADDR. The
+// first segment of the path is the mount of the KV engine.
+//
+//	vaults:
+//	  default: hcvault://vault.example.com:8200/secret/myapp
+//	  prod:
+//	    uri: hcvault:kv/myapp/prod
+//	    with:
+//	      auth: approle
+//	      role_id: ${VAULT_ROLE_ID}
+//	      secret_id: ${VAULT_SECRET_ID}
+func OpenVault(cfg *vaults.VaultConfig) (vaults.SecretVault, error) {
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+
+	address := ""
+	path := cfg.Path
+	if strings.HasPrefix(path, "//") {
+		host, rest, _ := strings.Cut(path[2:], "/")
+		address = "https://" + host
+		path = rest
+	}
+
+	if cfg.Has("address") {
+		address = cfg.String("address")
+	}
+
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+
+	if address == "" {
+		return nil, errors.New("hcvault requires an address, e.g. hcvault://vault.example.com:8200/secret/app or $VAULT_ADDR")
+	}
+
+	if _, err := url.ParseRequestURI(address); err != nil {
+		return nil, fmt.Errorf("invalid vault address %q: %w", address, err)
+	}
+
+	if cfg.Has("path") {
+		path = cfg.String("path")
+	}
+
+	path = strings.Trim(path, "/")
+	mount := cfg.String("mount")
+	if mount == "" {
+		mount, path, _ = strings.Cut(path, "/")
+	}
+
+	token := cfg.String("token")
+	if file := cfg.String("token_file"); file != "" && token == "" {
+		data, err := os.ReadFile(cfg.ResolvePath(file))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read vault token file: %w", err)
+		}
+
+		token = strings.TrimSpace(string(data))
+	}
+
+	var timeout time.Duration
+	if t := cfg.String("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout %q: %w", t, err)
+		}
+
+		timeout = d
+	}
+
+	namespace := cfg.String("namespace")
+	if namespace == "" {
+		namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+
+	return New(HcVaultSecretVaultParams{
+		Address:   address,
+		Namespace: namespace,
+		Mount:     mount,
+		Path:      path,
+		Field:     cfg.String("field"),
+		Insecure:  cfg.Bool("insecure"),
+		Timeout:   timeout,
+		Auth: Auth{
+			Method:      cfg.String("auth"),
+			Token:       token,
+			RoleId:      cfg.String("role_id"),
+			SecretId:    cfg.String("secret_id"),
+			AppRolePath: cfg.String("approle_path"),
+		},
+	}), nil
+}
+
+func validateConfig(cfg *vaults.VaultConfig) error {
+	errs := []string{}
+	for _, key := range cfg.Unknown(configKeys...) {
+		errs = append(errs, fmt.Sprintf("unknown option %s", key))
+	}
+
+	for _, key := range configKeys {
+		if !cfg.IsString(key) {
+			errs = append(errs, fmt.Sprintf("%s must be a string", key))
+		}
+	}
+
+	switch auth := cfg.String("auth"); auth {
+	case "", AuthToken, AuthAppRole, AuthEnv:
+	default:
+		errs = append(errs, fmt.Sprintf("auth must be token, approle or env, got %q", auth))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid hcvault options: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
+
+func (v *HcVaultSecretVault) path(kind, key string) (string, error) {
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid secret name %q", key)
+		}
+	}
+
+	if v.prefix != "" {
+		key = v.prefix + "/" + key
+	}
+
+	return v.mount + "/" + kind + "/" + key, nil
+}
+
+func isNotFound(err error) bool {
+	var apiErr *apiError
+	return errors.As(err, &apiErr) && apiErr.Status == http.StatusNotFound
+}
+
+// GetSecretValue reads the latest version of the secret or the
+// version of params. Deleted versions are not found.
+func (v *HcVaultSecretVault) GetSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
+	query := url.Values{}
+	if params != nil && params.Version != "" {
+		query.Set("version", params.Version)
+	}
+
+	path, err := v.path("data", key)
+	if err != nil {
+		return "", err
+	}
+
+	var res struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+
+	if err := v.client.Do(vaults.Context(params), http.MethodGet, path, query, nil, &res); err != nil {
+		if isNotFound(err) {
+			return "", fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+		}
+
+		return "", err
+	}
+
+	if res.Data.Data == nil {
+		return "", fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+	}
+
+	value, ok := res.Data.Data[v.field]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %s", key, v.field)
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+
+	return fmt.Sprint(value), nil
+}
+
+func (v *HcVaultSecretVault) BatchGetSecretValues(keys []string, params *vaults.GetSecretValueParams) (map[string]string, error) {
+	values := map[string]string{}
+	for _, key := range keys {
+		value, err := v.GetSecretValue(key, params)
+		if err != nil {
+			return nil, err
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+func (v *HcVaultSecretVault) MapSecretValues(query map[string]string, params *vaults.GetSecretValueParams) (map[string]string, error) {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+
+	res, err := v.BatchGetSecretValues(keys, params)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]string{}
+	for k, name := range query {
+		if val, ok := res[k]; ok {
+			values[name] = val
+		}
+	}
+
+	return values, nil
+}
+
+// SetSecretValue writes a new version of the secret.
+func (v *HcVaultSecretVault) SetSecretValue(key, value string, params *vaults.SetSecretValueParams) error {
+	path, err := v.path("data", key)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]interface{}{"data": map[string]string{v.field: value}}
+	return v.client.Do(vaults.Context(params), http.MethodPost, path, nil, body, nil)
+}
+
+func (v *HcVaultSecretVault) BatchSetSecretValues(values map[string]string, params *vaults.SetSecretValueParams) error {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := vaults.Err(params); err != nil {
+			return err
+		}
+
+		if err := v.SetSecretValue(k, values[k], params); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteSecret soft deletes the latest version of the secret, it can
+// be restored with `vault kv undelete`.
+func (v *HcVaultSecretVault) DeleteSecret(key string, params *vaults.DeleteSecretParams) error {
+	path, err := v.path("data", key)
+	if err != nil {
+		return err
+	}
+
+	err = v.client.Do(vaults.Context(params), http.MethodDelete, path, nil, nil, nil)
+	if isNotFound(err) {
+		return nil
+	}
+
+	return err
+}
+
+// ListSecretNames lists the secrets below the path of the vault,
+// secrets in sub folders are returned as `folder/name`. Vault keeps
+// the metadata of soft deleted secrets, so they are still listed.
+func (v *HcVaultSecretVault) ListSecretNames(params *vaults.ListSecretNamesParams) ([]string, error) {
+	names := []string{}
+	if err := v.list(vaults.Context(params), "", &names); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	return names, nil
+}
+
+func (v *HcVaultSecretVault) list(ctx context.Context, folder string, names *[]string) error {
+	path := v.mount + "/metadata"
+	if v.prefix != "" {
+		path += "/" + v.prefix
+	}
+
+	if folder != "" {
+		path += "/" + strings.TrimSuffix(folder, "/")
+	}
+
+	var res struct {
+		Data struct {
+			Keys []string `json:"keys"`
+		} `json:"data"`
+	}
+
+	err := v.client.Do(ctx, "LIST", path, nil, nil, &res)
+	if isNotFound(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	for _, key := range res.Data.Keys {
+		if strings.HasSuffix(key, "/") {
+			if err := v.list(ctx, folder+key, names); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		*names = append(*names, folder+key)
+	}
+
+	return nil
+}
@@ -0,0 +1,340 @@
+package hcvault_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/stretchr/testify/assert"
+)
+
+type version struct {
+	data    map[string]interface{}
+	deleted bool
+}
+
+// fakeVault is an in-process KV v2 engine mounted at `secret` with an
+// AppRole login that issues `approle-token`.
+type fakeVault struct {
+	secrets map[string][]*version
+	tokens  map[string]bool
+	logins  int
+	mux     sync.Mutex
+}
+
+func newFakeVault(t *testing.T) (*fakeVault, *httptest.Server) {
+	f := &fakeVault{secrets: map[string][]*version{}, tokens: map[string]bool{"root": true}}
+	srv := httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(srv.Close)
+	return f, srv
+}
+
+func (f *fakeVault) serve(w http.ResponseWriter, r *http.Request) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	if path == "auth/approle/login" {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			reply(w, http.StatusBadRequest, map[string]interface{}{"errors": []string{"invalid role or secret id"}})
+			return
+		}
+
+		f.logins++
+		f.tokens["approle-token"] = true
+		reply(w, http.StatusOK, map[string]interface{}{"auth": map[string]string{"client_token": "approle-token"}})
+		return
+	}
+
+	if !f.tokens[r.Header.Get("X-Vault-Token")] {
+		reply(w, http.StatusForbidden, map[string]interface{}{"errors": []string{"permission denied"}})
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(path, "secret/data/"):
+		f.data(w, r, strings.TrimPrefix(path, "secret/data/"))
+	case strings.HasPrefix(path, "secret/metadata"):
+		f.list(w, r, strings.Trim(strings.TrimPrefix(path, "secret/metadata"), "/"))
+	default:
+		reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+	}
+}
+
+func (f *fakeVault) data(w http.ResponseWriter, r *http.Request, key string) {
+	versions := f.secrets[key]
+	switch r.Method {
+	case http.MethodGet:
+		n := len(versions)
+		if v := r.URL.Query().Get("version"); v != "" {
+			n, _ = strconv.Atoi(v)
+		}
+
+		if n < 1 || n > len(versions) {
+			reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+			return
+		}
+
+		v := versions[n-1]
+		if v.deleted {
+			reply(w, http.StatusNotFound, map[string]interface{}{"data": map[string]interface{}{"data": nil}})
+			return
+		}
+
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{
+			"data":     v.data,
+			"metadata": map[string]interface{}{"version": n},
+		}})
+	case http.MethodPost:
+		var body struct {
+			Data map[string]interface{} `json:"data"`
+		}
+
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		f.secrets[key] = append(versions, &version{data: body.Data})
+		reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"version": len(f.secrets[key])}})
+	case http.MethodDelete:
+		if len(versions) == 0 {
+			reply(w, http.StatusNotFound, nil)
+			return
+		}
+
+		versions[len(versions)-1].deleted = true
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (f *fakeVault) list(w http.ResponseWriter, r *http.Request, folder string) {
+	if r.Method != "LIST" {
+		reply(w, http.StatusMethodNotAllowed, nil)
+		return
+	}
+
+	prefix := folder + "/"
+	if folder == "" {
+		prefix = ""
+	}
+
+	seen := map[string]bool{}
+	for key := range f.secrets {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+
+		rest := strings.TrimPrefix(key, prefix)
+		if i := strings.Index(rest, "/"); i >= 0 {
+			rest = rest[:i+1]
+		}
+
+		seen[rest] = true
+	}
+
+	if len(seen) == 0 {
+		reply(w, http.StatusNotFound, map[string]interface{}{"errors": []string{}})
+		return
+	}
+
+	keys := []string{}
+	for k := range seen {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	reply(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"keys": keys}})
+}
+
+func reply(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if body != nil {
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+func TestHcVaultSecretVault(t *testing.T) {
+	fake, srv := newFakeVault(t)
+	vault, err := vaults.Open(configs.VaultItem{
+		Name: "default",
+		Uri:  "hcvault:secret/myapp",
+		With: map[string]interface{}{"address": srv.URL, "token": "root"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, vault.BatchSetSecretValues(map[string]string{
+		"DB_PASSWORD": "v1",
+		"api/token":   "t0ken",
+	}, nil))
+	assert.Nil(t, vault.SetSecretValue("DB_PASSWORD", "v2", nil))
+	assert.Len(t, fake.secrets["myapp/DB_PASSWORD"], 2)
+
+	v, err := vault.GetSecretValue("DB_PASSWORD", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "v2", v)
+
+	v, err = vault.GetSecretValue("DB_PASSWORD", &vaults.GetSecretValueParams{Version: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", v)
+
+	values, err := vault.MapSecretValues(map[string]string{"api/token": "TOKEN"}, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"TOKEN": "t0ken"}, values)
+
+	names, err := vault.ListSecretNames(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"DB_PASSWORD", "api/token"}, names)
+
+	_, err = vault.GetSecretValue("MISSING", nil)
+	assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+
+	_, err = vault.GetSecretValue("../other", nil)
+	assert.ErrorContains(t, err, "invalid secret name")
+
+	// soft delete keeps older versions.
+	assert.Nil(t, vault.DeleteSecret("DB_PASSWORD", nil))
+	_, err = vault.GetSecretValue("DB_PASSWORD", nil)
+	assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+	v, err = vault.GetSecretValue("DB_PASSWORD", &vaults.GetSecretValueParams{Version: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "v1", v)
+
+	assert.Nil(t, vault.DeleteSecret("MISSING", nil))
+}
+
+func TestHcVaultAuth(t *testing.T) {
+	fake, srv := newFakeVault(t)
+	fake.secrets["app/TOKEN"] = []*version{{data: map[string]interface{}{"value": "x"}}}
+
+	t.Run("approle", func(t *testing.T) {
+		vault, err := vaults.Open(configs.VaultItem{
+			Name: "default",
+			Uri:  "hcvault:secret/app",
+			With: map[string]interface{}{
+				"address":   srv.URL,
+				"auth":      "approle",
+				"role_id":   "role",
+				"secret_id": "secret",
+			},
+		})
+		assert.Nil(t, err)
+
+		v, err := vault.GetSecretValue("TOKEN", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", v)
+
+		// an expired token logs in again.
+		fake.mux.Lock()
+		delete(fake.tokens, "approle-token")
+		logins := fake.logins
+		fake.mux.Unlock()
+
+		v, err = vault.GetSecretValue("TOKEN", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", v)
+		assert.Equal(t, logins+1, fake.logins)
+	})
+
+	t.Run("env", func(t *testing.T) {
+		t.Setenv("VAULT_ADDR", srv.URL)
+		t.Setenv("VAULT_TOKEN", "")
+		t.Setenv("VAULT_ROLE_ID", "role")
+		t.Setenv("VAULT_SECRET_ID", "secret")
+
+		vault, err := vaults.Open(configs.VaultItem{Name: "default", Uri: "hcvault:secret/app"})
+		assert.Nil(t, err)
+		v, err := vault.GetSecretValue("TOKEN", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", v)
+
+		t.Setenv("VAULT_TOKEN", "root")
+		vault, _ = vaults.Open(configs.VaultItem{Name: "default", Uri: "hcvault:secret/app?auth=env"})
+		v, err = vault.GetSecretValue("TOKEN", nil)
+		assert.Nil(t, err)
+		assert.Equal(t, "x", v)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		vault, _ := vaults.Open(configs.VaultItem{
+			Name: "default",
+			Uri:  "hcvault:secret/app",
+			With: map[string]interface{}{"address": srv.URL, "token": "wrong"},
+		})
+
+		_, err := vault.GetSecretValue("TOKEN", nil)
+		assert.ErrorContains(t, err, "403 permission denied")
+		assert.ErrorIs(t, err, vaults.ErrVaultLocked)
+	})
+
+	t.Run("no credentials", func(t *testing.T) {
+		t.Setenv("VAULT_TOKEN", "")
+		t.Setenv("VAULT_ROLE_ID", "")
+		vault, _ := vaults.Open(configs.VaultItem{
+			Name: "default",
+			Uri:  "hcvault:secret/app",
+			With: map[string]interface{}{"address": srv.URL},
+		})
+
+		_, err := vault.GetSecretValue("TOKEN", nil)
+		assert.ErrorIs(t, err, vaults.ErrVaultLocked)
+	})
+}
+
+func TestHcVaultDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	vault, err := vaults.Open(configs.VaultItem{
+		Name: "default",
+		Uri:  "hcvault:secret/app",
+		With: map[string]interface{}{"address": srv.URL, "token": "root"},
+	})
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = vault.GetSecretValue("TOKEN", &vaults.GetSecretValueParams{
+		OperationParams: vaults.OperationParams{Context: ctx},
+	})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	vault, _ = vaults.Open(configs.VaultItem{
+		Name: "default",
+		Uri:  "hcvault:secret/app",
+		With: map[string]interface{}{"address": srv.URL, "token": "root", "timeout": "50ms"},
+	})
+
+	_, err = vault.ListSecretNames(nil)
+	assert.NotNil(t, err)
+}
+
+func TestHcVaultOptions(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	_, err := vaults.Open(configs.VaultItem{Name: "default", Uri: "hcvault:secret/app"})
+	assert.ErrorContains(t, err, "requires an address")
+
+	_, err = vaults.Open(configs.VaultItem{Name: "default", Uri: "hcvault://vault:8200/secret/app?auth=ldap"})
+	assert.ErrorContains(t, err, "auth must be token, approle or env")
+
+	_, err = vaults.Open(configs.VaultItem{Name: "default", Uri: "hcvault://vault:8200/secret/app?tokn=x"})
+	assert.ErrorContains(t, err, "unknown option tokn")
+
+	_, err = vaults.Open(configs.VaultItem{Name: "default", Uri: "hcvault://vault:8200/secret/app?timeout=soon"})
+	assert.ErrorContains(t, err, "invalid timeout")
+}
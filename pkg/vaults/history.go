@@ -0,0 +1,182 @@
+package vaults
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DefaultHistoryLimit is the number of versions that drivers keep of
+// each secret unless configured otherwise.
+const DefaultHistoryLimit = 10
+
+// HistoryLimit maps the `history` option of a vault to the history
+// limit of the driver params. `history: false` and `history: 0` keep
+// no history and return -1, `true` or no option return 0 for
+// DefaultHistoryLimit.
+func HistoryLimit(cfg *VaultConfig) int {
+	switch v := cfg.Options["history"].(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 0
+		}
+
+		return -1
+	}
+
+	if n := cfg.Int("history"); n > 0 {
+		return n
+	}
+
+	return -1
+}
+
+// ValidateHistory checks that the `history` option is a number or a
+// bool.
+func ValidateHistory(cfg *VaultConfig) error {
+	v, ok := cfg.Options["history"]
+	if !ok || v == nil {
+		return nil
+	}
+
+	if _, isBool := v.(bool); isBool {
+		return nil
+	}
+
+	if _, err := strconv.Atoi(cfg.String("history")); err != nil {
+		return fmt.Errorf("history must be a number or false, got %q", cfg.String("history"))
+	}
+
+	return nil
+}
+
+// SecretVersion describes a version of a secret. Versions count up
+// from 1, the last version is the current value unless it records
+// that the secret was deleted. Versions recorded before a vault kept
+// history have a zero Created time.
+type SecretVersion struct {
+	Version int       `yaml:"version" json:"version"`
+	Created time.Time `yaml:"created" json:"created"`
+	Deleted bool      `yaml:"deleted,omitempty" json:"deleted,omitempty"`
+}
+
+type ListSecretVersionsParams struct {
+	OperationParams
+}
+
+func (p *ListSecretVersionsParams) operation() *OperationParams {
+	if p == nil {
+		return nil
+	}
+
+	return &p.OperationParams
+}
+
+// VersionedVault is implemented by vaults that keep prior values of
+// their secrets. A version is read with GetSecretValueParams.Version.
+type VersionedVault interface {
+	ListSecretVersions(key string, params *ListSecretVersionsParams) ([]SecretVersion, error)
+}
+
+// HistoryEntry is a version with its stored value. Drivers decide
+// what the value is, e.g. the age driver stores the ciphertext while
+// the sops driver encrypts the whole history.
+type HistoryEntry struct {
+	SecretVersion `yaml:",inline"`
+	Value         string `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+// History holds the versions of the secrets of a vault by key, oldest
+// first. Drivers load and persist it, the methods keep the version
+// numbers and the limit consistent between drivers.
+type History map[string][]HistoryEntry
+
+// Seed records value as version 1 of key when key has no versions
+// yet, so that the value of a secret written before the vault kept
+// history can be rolled back to.
+func (h History) Seed(key, value string) {
+	if len(h[key]) > 0 {
+		return
+	}
+
+	h[key] = []HistoryEntry{{SecretVersion: SecretVersion{Version: 1}, Value: value}}
+}
+
+// Record adds value as the next version of key and drops the oldest
+// versions beyond limit. A limit below 1 keeps every version.
+func (h History) Record(key, value string, now time.Time, limit int) int {
+	return h.add(key, HistoryEntry{SecretVersion: SecretVersion{Created: now}, Value: value}, limit)
+}
+
+// Delete records that key was deleted. The prior versions are kept so
+// that a deleted secret can be rolled back.
+func (h History) Delete(key string, now time.Time, limit int) int {
+	return h.add(key, HistoryEntry{SecretVersion: SecretVersion{Created: now, Deleted: true}}, limit)
+}
+
+func (h History) add(key string, entry HistoryEntry, limit int) int {
+	entries := h[key]
+	entry.Version = 1
+	if len(entries) > 0 {
+		entry.Version = entries[len(entries)-1].Version + 1
+	}
+
+	entries = append(entries, entry)
+	if limit > 0 && len(entries) > limit {
+		entries = append([]HistoryEntry{}, entries[len(entries)-limit:]...)
+	}
+
+	h[key] = entries
+	return entry.Version
+}
+
+// Get returns the entry of a version of key.
+func (h History) Get(key string, version int) (HistoryEntry, bool) {
+	for _, e := range h[key] {
+		if e.Version == version {
+			return e, true
+		}
+	}
+
+	return HistoryEntry{}, false
+}
+
+// Versions returns the versions of key without their values.
+func (h History) Versions(key string) []SecretVersion {
+	versions := make([]SecretVersion, 0, len(h[key]))
+	for _, e := range h[key] {
+		versions = append(versions, e.SecretVersion)
+	}
+
+	return versions
+}
+
+// ParseVersion parses GetSecretValueParams.Version for drivers that
+// number versions.
+func ParseVersion(version string) (int, error) {
+	v, err := strconv.Atoi(version)
+	if err != nil || v < 1 {
+		return 0, fmt.Errorf("invalid version %q, versions are numbers from 1", version)
+	}
+
+	return v, nil
+}
+
+// Rollback writes the value of a prior version of key as its current
+// value. The rollback is recorded as a new version, so it can itself
+// be rolled back.
+func Rollback(vault SecretVault, key, version string, params *SetSecretValueParams) error {
+	get := &GetSecretValueParams{Version: version}
+	if params != nil {
+		get.OperationParams = params.OperationParams
+	}
+
+	value, err := vault.GetSecretValue(key, get)
+	if err != nil {
+		return fmt.Errorf("secret %s version %s: %w", key, version, err)
+	}
+
+	return vault.SetSecretValue(key, value, params)
+}
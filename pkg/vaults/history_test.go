@@ -0,0 +1,61 @@
+package vaults_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	now := time.Now()
+	h := vaults.History{}
+	h.Seed("a", "old")
+	h.Seed("a", "ignored")
+	assert.Equal(t, 2, h.Record("a", "new", now, 3))
+	assert.Equal(t, 3, h.Record("a", "newer", now, 3))
+	assert.Equal(t, 4, h.Delete("a", now, 3))
+
+	versions := h.Versions("a")
+	assert.Len(t, versions, 3)
+	assert.Equal(t, 2, versions[0].Version)
+	assert.True(t, versions[2].Deleted)
+
+	_, ok := h.Get("a", 1)
+	assert.False(t, ok)
+	e, ok := h.Get("a", 3)
+	assert.True(t, ok)
+	assert.Equal(t, "newer", e.Value)
+
+	_, err := vaults.ParseVersion("0")
+	assert.NotNil(t, err)
+	n, err := vaults.ParseVersion("12")
+	assert.Nil(t, err)
+	assert.Equal(t, 12, n)
+}
+
+func TestHistoryLimit(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected int
+	}{
+		{nil, 0},
+		{true, 0},
+		{false, -1},
+		{0, -1},
+		{3, 3},
+		{"5", 5},
+	}
+
+	for _, test := range tests {
+		cfg := &vaults.VaultConfig{Options: map[string]interface{}{"history": test.value}}
+		assert.Equal(t, test.expected, vaults.HistoryLimit(cfg), test.value)
+		assert.Nil(t, vaults.ValidateHistory(cfg), test.value)
+	}
+
+	assert.Equal(t, 0, vaults.HistoryLimit(&vaults.VaultConfig{Options: map[string]interface{}{}}))
+
+	cfg := &vaults.VaultConfig{Options: map[string]interface{}{"history": "forever"}}
+	assert.ErrorContains(t, vaults.ValidateHistory(cfg), `history must be a number or false, got "forever"`)
+}
@@ -0,0 +1,290 @@
+package vaults
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+)
+
+// VaultConfig is a vault item with its uri parsed. For the uri
+// `sops:./secrets.env?age_key_file=key.txt` the scheme is `sops`, the
+// path is `./secrets.env` and the query becomes an option. Options
+// from `with` override options from the query.
+type VaultConfig struct {
+	Name    string
+	Scheme  string
+	Path    string
+	Options map[string]interface{}
+
+	// Dir is the directory that relative paths are resolved from.
+	Dir string
+}
+
+// Driver creates a vault client from its config.
+type Driver func(cfg *VaultConfig) (SecretVault, error)
+
+type DriverRegistry struct {
+	drivers map[string]Driver
+	mux     sync.RWMutex
+}
+
+// Drivers holds the vault drivers. Driver packages register
+// themselves in init, so they must be imported for their scheme
+// to be available.
+var Drivers = NewDriverRegistry()
+
+func NewDriverRegistry() *DriverRegistry {
+	return &DriverRegistry{drivers: map[string]Driver{}}
+}
+
+func (r *DriverRegistry) Register(scheme string, driver Driver) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.drivers[strings.ToLower(scheme)] = driver
+}
+
+func (r *DriverRegistry) Get(scheme string) (Driver, bool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	driver, ok := r.drivers[strings.ToLower(scheme)]
+	return driver, ok
+}
+
+func (r *DriverRegistry) Has(scheme string) bool {
+	_, ok := r.Get(scheme)
+	return ok
+}
+
+func (r *DriverRegistry) Names() []string {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	names := make([]string, 0, len(r.drivers))
+	for name := range r.drivers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Open creates the client for item with the driver of its scheme.
+// Relative paths are resolved from dir.
+func (r *DriverRegistry) Open(item configs.VaultItem, dir string) (SecretVault, error) {
+	cfg, err := ParseConfig(item)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.Dir = dir
+	driver, ok := r.Get(cfg.Scheme)
+	if !ok {
+		return nil, fmt.Errorf("vault %s: unknown driver %q, available drivers: %s", item.Name, cfg.Scheme, strings.Join(r.Names(), ", "))
+	}
+
+	vault, err := driver(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", item.Name, err)
+	}
+
+	return vault, nil
+}
+
+func RegisterDriver(scheme string, driver Driver) {
+	Drivers.Register(scheme, driver)
+}
+
+// Open creates the client for item using the registered drivers.
+func Open(item configs.VaultItem) (SecretVault, error) {
+	return Drivers.Open(item, "")
+}
+
+// OpenIn is Open with relative paths resolved from dir.
+func OpenIn(item configs.VaultItem, dir string) (SecretVault, error) {
+	return Drivers.Open(item, dir)
+}
+
+// ParseConfig splits the uri of item into scheme, path and options
+// and merges the `with` map into the options.
+func ParseConfig(item configs.VaultItem) (*VaultConfig, error) {
+	cfg := &VaultConfig{
+		Name:    item.Name,
+		Scheme:  strings.ToLower(item.Driver()),
+		Options: map[string]interface{}{},
+	}
+
+	if cfg.Scheme == "" {
+		return nil, fmt.Errorf("vault %s: uri %q is missing a scheme such as sops:", item.Name, item.Uri)
+	}
+
+	rest := item.Uri[len(cfg.Scheme)+1:]
+	path, query, _ := strings.Cut(rest, "?")
+	cfg.Path = path
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, fmt.Errorf("vault %s: invalid query in uri %q: %w", item.Name, item.Uri, err)
+		}
+
+		for k, v := range values {
+			if len(v) == 1 {
+				cfg.Options[k] = v[0]
+			} else {
+				cfg.Options[k] = v
+			}
+		}
+	}
+
+	for k, v := range item.With {
+		cfg.Options[k] = v
+	}
+
+	return cfg, nil
+}
+
+// String returns the option as a string, environment variables
+// such as ${HOME} are expanded.
+func (c *VaultConfig) String(key string) string {
+	v, ok := c.Options[key]
+	if !ok || v == nil {
+		return ""
+	}
+
+	if s, ok := v.(string); ok {
+		return os.ExpandEnv(s)
+	}
+
+	return fmt.Sprint(v)
+}
+
+func (c *VaultConfig) Bool(key string) bool {
+	b, _ := strconv.ParseBool(c.String(key))
+	return b
+}
+
+func (c *VaultConfig) Int(key string) int {
+	i, _ := strconv.Atoi(c.String(key))
+	return i
+}
+
+// Strings returns a list option. Lists may be written as a yaml
+// sequence, a repeated query parameter or a comma separated string.
+func (c *VaultConfig) Strings(key string) []string {
+	switch v := c.Options[key].(type) {
+	case nil:
+		return nil
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			values = append(values, os.ExpandEnv(fmt.Sprint(item)))
+		}
+
+		return values
+	default:
+		values := []string{}
+		for _, s := range strings.Split(c.String(key), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	}
+}
+
+// Sub returns the nested options of key as a config, for example
+// the `age` map of the sops driver.
+func (c *VaultConfig) Sub(key string) *VaultConfig {
+	sub := &VaultConfig{Name: c.Name, Scheme: c.Scheme, Dir: c.Dir, Options: map[string]interface{}{}}
+	if m, ok := c.Options[key].(map[string]interface{}); ok {
+		for k, v := range m {
+			sub.Options[k] = v
+		}
+	}
+
+	return sub
+}
+
+func (c *VaultConfig) Has(key string) bool {
+	_, ok := c.Options[key]
+	return ok
+}
+
+// ResolvePath resolves p relative to Dir and expands `~/`.
+func (c *VaultConfig) ResolvePath(p string) string {
+	if p == "" {
+		return p
+	}
+
+	p = os.ExpandEnv(p)
+	if strings.HasPrefix(p, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[2:])
+		}
+	}
+
+	if filepath.IsAbs(p) || c.Dir == "" {
+		return p
+	}
+
+	return filepath.Join(c.Dir, p)
+}
+
+// Unknown returns the option keys that are not in known, sorted, so
+// that drivers can report typos in `with`.
+func (c *VaultConfig) Unknown(known ...string) []string {
+	unknown := []string{}
+	for key := range c.Options {
+		found := false
+		for _, k := range known {
+			if k == key {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			unknown = append(unknown, key)
+		}
+	}
+
+	sort.Strings(unknown)
+	return unknown
+}
+
+// IsString reports whether the option is unset or a scalar.
+func (c *VaultConfig) IsString(key string) bool {
+	switch c.Options[key].(type) {
+	case nil, string, int, int64, float64, bool:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsStrings reports whether the option is unset, a string or a list
+// of strings.
+func (c *VaultConfig) IsStrings(key string) bool {
+	switch v := c.Options[key].(type) {
+	case nil, string, []string:
+		return true
+	case []interface{}:
+		for _, item := range v {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return false
+	}
+}
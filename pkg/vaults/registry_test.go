@@ -0,0 +1,75 @@
+package vaults_test
+
+import (
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/jolt9dev/jolt9/pkg/vaults/sops"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeVault struct {
+	vaults.SecretVault
+	cfg *vaults.VaultConfig
+}
+
+func TestParseConfig(t *testing.T) {
+	cfg, err := vaults.ParseConfig(configs.VaultItem{
+		Name: "prod",
+		Uri:  "sops:./prod.env?age_recipients=a,b&indent=2&tag=x&tag=y",
+		With: map[string]interface{}{
+			"indent": 4,
+			"age":    map[string]interface{}{"recipients": []interface{}{"c"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "sops", cfg.Scheme)
+	assert.Equal(t, "./prod.env", cfg.Path)
+	assert.Equal(t, []string{"a", "b"}, cfg.Strings("age_recipients"))
+	assert.Equal(t, []string{"x", "y"}, cfg.Strings("tag"))
+	assert.Equal(t, 4, cfg.Int("indent"))
+	assert.Equal(t, []string{"c"}, cfg.Sub("age").Strings("recipients"))
+
+	cfg.Dir = "/project"
+	assert.Equal(t, "/project/prod.env", cfg.ResolvePath(cfg.Path))
+	assert.Equal(t, "/etc/prod.env", cfg.ResolvePath("/etc/prod.env"))
+
+	_, err = vaults.ParseConfig(configs.VaultItem{Name: "bad", Uri: "./file.env"})
+	assert.NotNil(t, err)
+}
+
+func TestDriverRegistry(t *testing.T) {
+	registry := vaults.NewDriverRegistry()
+	registry.Register("fake", func(cfg *vaults.VaultConfig) (vaults.SecretVault, error) {
+		return &fakeVault{cfg: cfg}, nil
+	})
+
+	vault, err := registry.Open(configs.VaultItem{Name: "a", Uri: "FAKE:path?x=1"}, "/dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := vault.(*fakeVault)
+	assert.Equal(t, "path", fake.cfg.Path)
+	assert.Equal(t, "1", fake.cfg.String("x"))
+	assert.Equal(t, "/dir", fake.cfg.Dir)
+
+	_, err = registry.Open(configs.VaultItem{Name: "b", Uri: "nope:x"}, "")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), `unknown driver "nope", available drivers: fake`)
+}
+
+func TestOpenSops(t *testing.T) {
+	assert.True(t, vaults.Drivers.Has("sops"))
+
+	vault, err := vaults.OpenIn(configs.VaultItem{Name: "default", Uri: "sops:./default.secrets.env"}, "/project")
+	assert.Nil(t, err)
+	assert.IsType(t, &sops.SopsSecretVault{}, vault)
+
+	_, err = vaults.Open(configs.VaultItem{Name: "default", Uri: "sops:"})
+	assert.NotNil(t, err)
+}
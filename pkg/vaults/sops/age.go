@@ -0,0 +1,243 @@
+package sops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"filippo.io/age"
+	"github.com/getsops/sops/v3/aes"
+	sopsage "github.com/getsops/sops/v3/age"
+	"github.com/getsops/sops/v3/cmd/sops/common"
+	"github.com/getsops/sops/v3/cmd/sops/formats"
+	"github.com/getsops/sops/v3/config"
+	"github.com/getsops/sops/v3/keyservice"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+// DefaultAgeKeySecret is the secret read from KeyVault when
+// KeySecret is empty.
+const DefaultAgeKeySecret = "SOPS_AGE_KEY"
+
+// identities returns the age identities configured for the vault or
+// an empty string when sops should look them up itself through
+// SOPS_AGE_KEY, SOPS_AGE_KEY_FILE or the sops keys.txt file.
+func (p *SopsAgeParams) identities() (string, error) {
+	if p == nil {
+		return "", nil
+	}
+
+	ids := []string{}
+	if p.Key != "" {
+		ids = append(ids, p.Key)
+	}
+
+	if p.KeyFile != "" {
+		data, err := os.ReadFile(p.KeyFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read age key file: %w", err)
+		}
+
+		ids = append(ids, string(data))
+	}
+
+	if p.KeyEnv != "" {
+		key := os.Getenv(p.KeyEnv)
+		if key == "" {
+			return "", fmt.Errorf("age key env %s is not set", p.KeyEnv)
+		}
+
+		ids = append(ids, key)
+	}
+
+	if p.KeyVault != nil {
+		name := p.KeySecret
+		if name == "" {
+			name = DefaultAgeKeySecret
+		}
+
+		key, err := p.KeyVault.GetSecretValue(name, &vaults.GetSecretValueParams{})
+		if err != nil {
+			return "", fmt.Errorf("failed to read age key from vault: %w", err)
+		}
+
+		ids = append(ids, key)
+	}
+
+	return strings.Join(ids, "\n"), nil
+}
+
+// recipients returns the configured recipients or, when none are
+// set, the recipients of the configured identities.
+func (p *SopsAgeParams) recipients() ([]string, error) {
+	if len(p.Recipients) > 0 {
+		return p.Recipients, nil
+	}
+
+	ids, err := p.identities()
+	if err != nil {
+		return nil, err
+	}
+
+	return RecipientsFromIdentities(ids)
+}
+
+// RecipientsFromIdentities returns the public keys of the X25519
+// identities in ids.
+func RecipientsFromIdentities(ids string) ([]string, error) {
+	if strings.TrimSpace(ids) == "" {
+		return nil, nil
+	}
+
+	parsed, err := age.ParseIdentities(strings.NewReader(ids))
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := []string{}
+	for _, id := range parsed {
+		if x, ok := id.(*age.X25519Identity); ok {
+			recipients = append(recipients, x.Recipient().String())
+		}
+	}
+
+	return recipients, nil
+}
+
+// decryptData decrypts a sops document. When identities is not empty,
+// the age keys of the document use them instead of the identities
+// that sops would read from the environment.
+func decryptData(data []byte, fileType string, identities string) ([]byte, error) {
+	store := common.StoreForFormat(formats.FormatFromString(fileType), config.NewStoresConfig())
+	tree, err := store.LoadEncryptedFile(data)
+	if err != nil {
+		return nil, err
+	}
+
+	server := keyservice.KeyServiceServer(keyservice.Server{})
+	if identities != "" {
+		var parsed sopsage.ParsedIdentities
+		if err := parsed.Import(identities); err != nil {
+			return nil, err
+		}
+
+		server = ageKeyServer{identities: parsed}
+	}
+
+	key, err := tree.Metadata.GetDataKeyWithKeyServices(
+		[]keyservice.KeyServiceClient{keyservice.NewCustomLocalClient(server)}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", vaults.ErrVaultLocked, err)
+	}
+
+	cipher := aes.NewCipher()
+	mac, err := tree.Decrypt(key, cipher)
+	if err != nil {
+		return nil, err
+	}
+
+	originalMac, err := cipher.Decrypt(
+		tree.Metadata.MessageAuthenticationCode,
+		key,
+		tree.Metadata.LastModified.Format(time.RFC3339),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt original mac: %w", err)
+	}
+
+	if originalMac != mac {
+		return nil, fmt.Errorf("failed to verify data integrity. expected mac %q, got %q", originalMac, mac)
+	}
+
+	return store.EmitPlainFile(tree.Branches)
+}
+
+// ageKeyServer is the local sops key service with the age keys
+// decrypted by the identities of the vault.
+type ageKeyServer struct {
+	keyservice.Server
+	identities sopsage.ParsedIdentities
+}
+
+func (s ageKeyServer) Decrypt(ctx context.Context, req *keyservice.DecryptRequest) (*keyservice.DecryptResponse, error) {
+	ageKey := req.GetKey().GetAgeKey()
+	if ageKey == nil {
+		return s.Server.Decrypt(ctx, req)
+	}
+
+	key := &sopsage.MasterKey{Recipient: ageKey.Recipient, EncryptedKey: string(req.Ciphertext)}
+	s.identities.ApplyToMasterKey(key)
+	plaintext, err := key.Decrypt()
+	if err != nil {
+		return nil, err
+	}
+
+	return &keyservice.DecryptResponse{Plaintext: plaintext}, nil
+}
+
+// DefaultAgeKeyFile returns the key file that sops reads by default,
+// $SOPS_AGE_KEY_FILE or sops/age/keys.txt in the user config dir.
+func DefaultAgeKeyFile() (string, error) {
+	if file := os.Getenv(sopsage.SopsAgeKeyFileEnv); file != "" {
+		return file, nil
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(sopsage.SopsAgeKeyUserConfigPath)), nil
+}
+
+// GenerateAgeKey creates a new X25519 identity and returns the
+// identity and its recipient.
+func GenerateAgeKey() (identity string, recipient string, err error) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		return "", "", err
+	}
+
+	return id.String(), id.Recipient().String(), nil
+}
+
+// AppendAgeKey appends the identity to the key file in the format of
+// age-keygen. The file is created with 0600 permissions.
+func AppendAgeKey(file, identity string) error {
+	recipients, err := RecipientsFromIdentities(identity)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	sb := strings.Builder{}
+	if fi.Size() > 0 {
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("# created: %s\n", time.Now().UTC().Format(time.RFC3339)))
+	for _, r := range recipients {
+		sb.WriteString(fmt.Sprintf("# public key: %s\n", r))
+	}
+
+	sb.WriteString(identity + "\n")
+	_, err = f.WriteString(sb.String())
+	return err
+}
@@ -0,0 +1,169 @@
+package sops_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/jolt9dev/jolt9/pkg/vaults/sops"
+	"github.com/stretchr/testify/assert"
+)
+
+// isolateAge hides the age keys that sops would find on its own.
+func isolateAge(t *testing.T) string {
+	dir := t.TempDir()
+	t.Setenv("SOPS_AGE_KEY", "")
+	t.Setenv("SOPS_AGE_KEY_FILE", "")
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	return dir
+}
+
+func TestAgeKeySources(t *testing.T) {
+	dir := isolateAge(t)
+
+	identity, recipient, err := sops.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyFile := filepath.Join(dir, "keys", "age.txt")
+	assert.Nil(t, sops.AppendAgeKey(keyFile, identity))
+	fi, err := os.Stat(keyFile)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	recipients, err := sops.RecipientsFromIdentities(identity)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{recipient}, recipients)
+
+	t.Setenv("TEST_AGE_KEY", identity)
+
+	// the key vault holds the identity in its own file, which is
+	// decrypted with the key file.
+	keyVault, err := vaults.OpenIn(configs.VaultItem{
+		Name: "keys",
+		Uri:  "sops:./keys.env",
+		With: map[string]interface{}{"age": map[string]interface{}{"key_file": keyFile}},
+	}, dir)
+	assert.Nil(t, err)
+	assert.Nil(t, keyVault.SetSecretValue("PROD_AGE_KEY", identity, nil))
+
+	cases := map[string]map[string]interface{}{
+		"key_file":  {"key_file": keyFile},
+		"key_env":   {"key_env": "TEST_AGE_KEY"},
+		"key_vault": {"key_vault": "sops:./keys.env?age_key_file=" + keyFile, "key_secret": "PROD_AGE_KEY"},
+	}
+
+	for name, age := range cases {
+		t.Run(name, func(t *testing.T) {
+			item := configs.VaultItem{
+				Name: "prod",
+				Uri:  "sops:./" + name + ".secrets.env",
+				With: map[string]interface{}{"age": age},
+			}
+
+			// recipients are derived from the identity.
+			vault, err := vaults.OpenIn(item, dir)
+			assert.Nil(t, err)
+			assert.Nil(t, vault.SetSecretValue("TOKEN", "s3cret", nil))
+
+			vault, err = vaults.OpenIn(item, dir)
+			assert.Nil(t, err)
+			v, err := vault.GetSecretValue("TOKEN", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "s3cret", v)
+		})
+	}
+
+	t.Run("missing identity", func(t *testing.T) {
+		item := configs.VaultItem{Name: "prod", Uri: "sops:./key_file.secrets.env?age_recipients=" + recipient}
+		vault, err := vaults.OpenIn(item, dir)
+		assert.Nil(t, err)
+		_, err = vault.GetSecretValue("TOKEN", nil)
+		assert.ErrorIs(t, err, vaults.ErrVaultLocked)
+	})
+
+	t.Run("missing env", func(t *testing.T) {
+		item := configs.VaultItem{Name: "prod", Uri: "sops:./key_file.secrets.env?age_key_env=TEST_UNSET_AGE_KEY"}
+		vault, err := vaults.OpenIn(item, dir)
+		assert.Nil(t, err)
+		_, err = vault.GetSecretValue("TOKEN", nil)
+		assert.ErrorContains(t, err, "TEST_UNSET_AGE_KEY")
+		assert.ErrorIs(t, err, vaults.ErrVaultLocked)
+	})
+}
+
+func TestDefaultAgeKeyFile(t *testing.T) {
+	t.Setenv("SOPS_AGE_KEY_FILE", "/tmp/keys.txt")
+	file, err := sops.DefaultAgeKeyFile()
+	assert.Nil(t, err)
+	assert.Equal(t, "/tmp/keys.txt", file)
+}
+
+func TestInvalidSopsOptions(t *testing.T) {
+	cases := map[string]map[string]interface{}{
+		"unknown option age_keyfile":           {"age_keyfile": "key.txt"},
+		"unknown option age.recipient":         {"age": map[string]interface{}{"recipient": "age1"}},
+		"age must be a mapping":                {"age": "age1"},
+		"age.recipients must be a string":      {"age": map[string]interface{}{"recipients": []interface{}{1}}},
+		"indent must be a number":              {"indent": "two"},
+		"type must be dotenv, yaml or json":    {"type": "toml"},
+		"age.key_file must be a string":        {"age": map[string]interface{}{"key_file": []interface{}{"a"}}},
+		"age_recipients must be a string or a": {"age_recipients": map[string]interface{}{"a": "b"}},
+	}
+
+	for msg, with := range cases {
+		_, err := vaults.Open(configs.VaultItem{Name: "prod", Uri: "sops:./secrets.env", With: with})
+		assert.ErrorContains(t, err, msg)
+	}
+
+	_, err := sops.FromConfig(configs.VaultItem{Name: "prod", Uri: "sops:./secrets.env", With: map[string]interface{}{"pgp": []interface{}{}}})
+	assert.ErrorContains(t, err, "pgp must be a string")
+
+	_, err = sops.FromConfig(configs.VaultItem{Name: "prod", Uri: "sops:?age_key_file=key.txt"})
+	assert.ErrorContains(t, err, "requires a file")
+}
+
+func TestSopsVaultErrors(t *testing.T) {
+	dir := isolateAge(t)
+	identity, recipient, err := sops.GenerateAgeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "secrets.env")
+	vault := sops.New(sops.SopsSecretVaultParams{File: file, Age: &sops.SopsAgeParams{Key: identity}})
+	assert.Nil(t, vault.SetSecretValue("TOKEN", "value", nil))
+
+	_, err = vault.GetSecretValue("TOKEN", &vaults.GetSecretValueParams{Version: "2"})
+	assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+
+	disabled := sops.New(sops.SopsSecretVaultParams{File: file, History: -1, Age: &sops.SopsAgeParams{Key: identity}})
+	_, err = disabled.GetSecretValue("TOKEN", &vaults.GetSecretValueParams{Version: "1"})
+	assert.ErrorIs(t, err, vaults.ErrUnsupported)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	op := vaults.OperationParams{Context: ctx}
+
+	// a cancelled write leaves the file and the vault unchanged.
+	err = vault.SetSecretValue("TOKEN", "changed", &vaults.SetSecretValueParams{OperationParams: op})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	vault = sops.New(sops.SopsSecretVaultParams{File: file, Age: &sops.SopsAgeParams{Key: identity}})
+	_, err = vault.GetSecretValue("TOKEN", &vaults.GetSecretValueParams{OperationParams: op})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	v, err := vault.GetSecretValue("TOKEN", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "value", v)
+
+	// only the recipient is known, the vault can not be read.
+	vault = sops.New(sops.SopsSecretVaultParams{File: file, Age: &sops.SopsAgeParams{Recipients: []string{recipient}}})
+	_, err = vault.GetSecretValue("TOKEN", nil)
+	assert.ErrorIs(t, err, vaults.ErrVaultLocked)
+}
@@ -0,0 +1,158 @@
+package sops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jolt9dev/jolt9/pkg/configs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+var (
+	configKeys = []string{
+		"file", "config", "type", "driver", "indent",
+		"age", "age_recipients", "age_key", "age_key_file", "age_key_env",
+		"kms", "aws_profile", "encryption_context",
+		"azure_kv", "vault_uri", "pgp", "history",
+	}
+
+	ageKeys = []string{"recipients", "key", "key_file", "key_env", "key_vault", "key_secret"}
+)
+
+func paramsFromConfig(cfg *vaults.VaultConfig) (SopsSecretVaultParams, error) {
+	if err := validateConfig(cfg); err != nil {
+		return SopsSecretVaultParams{}, err
+	}
+
+	file := cfg.Path
+	if cfg.Has("file") {
+		file = cfg.String("file")
+	}
+
+	if file == "" {
+		return SopsSecretVaultParams{}, fmt.Errorf("sops vault requires a file, e.g. sops:./secrets.env")
+	}
+
+	params := SopsSecretVaultParams{
+		File:         cfg.ResolvePath(file),
+		ConfileFile:  cfg.ResolvePath(cfg.String("config")),
+		AzureKvUri:   cfg.String("azure_kv"),
+		VaultUri:     cfg.String("vault_uri"),
+		PgpPublicKey: cfg.String("pgp"),
+		Driver:       cfg.String("driver"),
+		Indent:       cfg.Int("indent"),
+		FileType:     cfg.String("type"),
+		History:      vaults.HistoryLimit(cfg),
+	}
+
+	age := cfg.Sub("age")
+	ageParams := &SopsAgeParams{
+		Recipients: age.Strings("recipients"),
+		Key:        age.String("key"),
+		KeyFile:    cfg.ResolvePath(age.String("key_file")),
+		KeyEnv:     age.String("key_env"),
+		KeySecret:  age.String("key_secret"),
+	}
+
+	if len(ageParams.Recipients) == 0 {
+		ageParams.Recipients = cfg.Strings("age_recipients")
+	}
+
+	if ageParams.Key == "" {
+		ageParams.Key = cfg.String("age_key")
+	}
+
+	if ageParams.KeyFile == "" {
+		ageParams.KeyFile = cfg.ResolvePath(cfg.String("age_key_file"))
+	}
+
+	if ageParams.KeyEnv == "" {
+		ageParams.KeyEnv = cfg.String("age_key_env")
+	}
+
+	if uri := age.String("key_vault"); uri != "" {
+		vault, err := vaults.OpenIn(configs.VaultItem{Name: cfg.Name + ".age.key_vault", Uri: uri}, cfg.Dir)
+		if err != nil {
+			return SopsSecretVaultParams{}, err
+		}
+
+		ageParams.KeyVault = vault
+	}
+
+	if len(ageParams.Recipients) > 0 || ageParams.Key != "" || ageParams.KeyFile != "" ||
+		ageParams.KeyEnv != "" || ageParams.KeyVault != nil {
+		params.Age = ageParams
+	}
+
+	if uri := cfg.String("kms"); uri != "" {
+		params.Kms = &SopsKmsParams{
+			Uri:               uri,
+			AwsProfile:        cfg.String("aws_profile"),
+			EncryptionContext: cfg.String("encryption_context"),
+		}
+	}
+
+	return params, nil
+}
+
+// validateConfig reports unknown options and options of the wrong
+// type so that typos in `with` are not silently ignored.
+func validateConfig(cfg *vaults.VaultConfig) error {
+	errs := []string{}
+	for _, key := range cfg.Unknown(configKeys...) {
+		errs = append(errs, fmt.Sprintf("unknown option %s", key))
+	}
+
+	for _, key := range []string{"file", "config", "type", "driver", "age_key", "age_key_file", "age_key_env",
+		"kms", "aws_profile", "encryption_context", "azure_kv", "vault_uri", "pgp"} {
+		if !cfg.IsString(key) {
+			errs = append(errs, fmt.Sprintf("%s must be a string", key))
+		}
+	}
+
+	if v, ok := cfg.Options["indent"]; ok && v != nil {
+		if _, err := strconv.Atoi(cfg.String("indent")); err != nil {
+			errs = append(errs, fmt.Sprintf("indent must be a number, got %q", cfg.String("indent")))
+		}
+	}
+
+	if err := vaults.ValidateHistory(cfg); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if t := cfg.String("type"); t != "" && t != "dotenv" && t != "yaml" && t != "json" {
+		errs = append(errs, fmt.Sprintf("type must be dotenv, yaml or json, got %q", t))
+	}
+
+	if !cfg.IsStrings("age_recipients") {
+		errs = append(errs, "age_recipients must be a string or a list of strings")
+	}
+
+	switch v := cfg.Options["age"].(type) {
+	case nil:
+	case map[string]interface{}:
+		age := cfg.Sub("age")
+		for _, key := range age.Unknown(ageKeys...) {
+			errs = append(errs, fmt.Sprintf("unknown option age.%s", key))
+		}
+
+		for _, key := range []string{"key", "key_file", "key_env", "key_vault", "key_secret"} {
+			if !age.IsString(key) {
+				errs = append(errs, fmt.Sprintf("age.%s must be a string", key))
+			}
+		}
+
+		if !age.IsStrings("recipients") {
+			errs = append(errs, "age.recipients must be a string or a list of strings")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("age must be a mapping, got %T", v))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid sops options: %s", strings.Join(errs, "; "))
+	}
+
+	return nil
+}
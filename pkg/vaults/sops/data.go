@@ -0,0 +1,178 @@
+package sops
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+func marshalData(data map[string]interface{}, fileType string, indent int) ([]byte, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	switch fileType {
+	case "dotenv":
+		kv := map[string]string{}
+		for k, v := range data {
+			kv[k] = fmt.Sprint(v)
+		}
+
+		str, err := godotenv.Marshal(kv)
+		if err != nil {
+			return nil, err
+		}
+
+		return []byte(str), nil
+	case "yaml":
+		if indent <= 0 {
+			indent = 2
+		}
+
+		buf := &bytes.Buffer{}
+		enc := yaml.NewEncoder(buf)
+		enc.SetIndent(indent)
+		if err := enc.Encode(data); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	case "json":
+		if indent <= 0 {
+			indent = 2
+		}
+
+		return json.MarshalIndent(data, "", strings.Repeat(" ", indent))
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	}
+}
+
+func unmarshalData(data []byte, fileType string) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	switch fileType {
+	case "dotenv":
+		kv, err := godotenv.UnmarshalBytes(data)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range kv {
+			values[k] = v
+		}
+	case "yaml":
+		if err := yaml.Unmarshal(data, &values); err != nil {
+			return nil, err
+		}
+	case "json":
+		// numbers are kept as json.Number so that they are written
+		// back exactly as they were read.
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		if err := dec.Decode(&values); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported file type: %s", fileType)
+	}
+
+	if values == nil {
+		values = map[string]interface{}{}
+	}
+
+	return values, nil
+}
+
+func splitKey(key string) []string {
+	return strings.Split(key, ".")
+}
+
+func getPath(data map[string]interface{}, path []string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range path {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+func setPath(data map[string]interface{}, path []string, value interface{}) error {
+	current := data
+	for i, part := range path[:len(path)-1] {
+		next, ok := current[part]
+		if !ok {
+			m := map[string]interface{}{}
+			current[part] = m
+			current = m
+			continue
+		}
+
+		m, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("cannot set %s, %s is not a section", strings.Join(path, "."), strings.Join(path[:i+1], "."))
+		}
+
+		current = m
+	}
+
+	current[path[len(path)-1]] = value
+	return nil
+}
+
+// deletePath removes the value at path and the sections that become
+// empty. It returns false when nothing was removed.
+func deletePath(data map[string]interface{}, path []string) bool {
+	if len(path) == 1 {
+		if _, ok := data[path[0]]; !ok {
+			return false
+		}
+
+		delete(data, path[0])
+		return true
+	}
+
+	next, ok := data[path[0]].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	if !deletePath(next, path[1:]) {
+		return false
+	}
+
+	if len(next) == 0 {
+		delete(data, path[0])
+	}
+
+	return true
+}
+
+func flattenKeys(data map[string]interface{}, prefix string, keys []string) []string {
+	for k, v := range data {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+
+		if m, ok := v.(map[string]interface{}); ok {
+			keys = flattenKeys(m, key, keys)
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys
+}
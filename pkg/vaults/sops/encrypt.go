@@ -49,6 +49,7 @@ type encryptOpts struct {
 	InputStore  sops.Store
 	OutputStore sops.Store
 	InputPath   string
+	Plain       []byte
 	KeyServices []keyservice.KeyServiceClient
 	encryptConfig
 }
@@ -110,7 +111,7 @@ func keyGroups(configPath string, file string, params SopsEncryptParams) ([]sops
 
 	if params.VaultUri != "" {
 		specific = true
-		hcVaultKeys, err := hcvault.NewMasterKeysFromURIs(params.AzureKvUri)
+		hcVaultKeys, err := hcvault.NewMasterKeysFromURIs(params.VaultUri)
 		if err != nil {
 			return nil, err
 		}
@@ -358,6 +359,9 @@ type SopsEncryptParams struct {
 	AzureKvUri string
 	VaultUri   string
 	PgpKey     string
+
+	// Plain is the plain text to encrypt. When nil File is read.
+	Plain []byte
 }
 
 func encryptOutput(params SopsEncryptParams) ([]byte, error) {
@@ -387,6 +391,7 @@ func encryptOutput(params SopsEncryptParams) ([]byte, error) {
 		OutputStore:   outputStore,
 		InputStore:    inputStore,
 		InputPath:     file,
+		Plain:         params.Plain,
 		Cipher:        aes.NewCipher(),
 		KeyServices:   svcs,
 		encryptConfig: encConfig,
@@ -397,9 +402,12 @@ func encryptOutput(params SopsEncryptParams) ([]byte, error) {
 
 func encrypt(opts encryptOpts) (encryptedFile []byte, err error) {
 	// Load the file
-	fileBytes, err := os.ReadFile(opts.InputPath)
-	if err != nil {
-		return nil, common.NewExitError(fmt.Sprintf("Error reading file: %s", err), codes.CouldNotReadInputFile)
+	fileBytes := opts.Plain
+	if fileBytes == nil {
+		fileBytes, err = os.ReadFile(opts.InputPath)
+		if err != nil {
+			return nil, common.NewExitError(fmt.Sprintf("Error reading file: %s", err), codes.CouldNotReadInputFile)
+		}
 	}
 	branches, err := opts.InputStore.LoadPlainFile(fileBytes)
 	if err != nil {
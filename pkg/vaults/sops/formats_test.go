@@ -0,0 +1,106 @@
+package sops_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/getsops/sops/v3/decrypt"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/jolt9dev/jolt9/pkg/vaults/sops"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSopsStructuredFiles(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SOPS_AGE_KEY", id.String())
+
+	for _, name := range []string{"secrets.yaml", "secrets.json"} {
+		t.Run(name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), name)
+			params := sops.SopsSecretVaultParams{
+				File: file,
+				Age:  &sops.SopsAgeParams{Recipients: []string{id.Recipient().String()}},
+			}
+
+			vault := sops.New(params)
+			assert.Equal(t, strings.TrimPrefix(filepath.Ext(name), "."), vault.FileType())
+
+			vault.LoadData(map[string]interface{}{
+				"db": map[string]interface{}{
+					"primary": map[string]interface{}{
+						"password": "s3cret",
+						"port":     5432,
+					},
+				},
+				"enabled": true,
+			})
+
+			if err := vault.Encrypt(); err != nil {
+				t.Fatal(err)
+			}
+
+			raw, _ := os.ReadFile(file)
+			assert.NotContains(t, string(raw), "s3cret")
+
+			// a new vault decrypts the file from disk.
+			vault = sops.New(params)
+			v, err := vault.GetSecretValue("db.primary.password", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "s3cret", v)
+
+			v, err = vault.GetSecretValue("db/primary/port", nil)
+			assert.Nil(t, err)
+			assert.Equal(t, "5432", v)
+
+			_, err = vault.GetSecretValue("db.primary", nil)
+			assert.NotNil(t, err)
+
+			_, err = vault.GetSecretValue("db.replica.password", nil)
+			assert.ErrorIs(t, err, vaults.ErrSecretNotFound)
+
+			assert.Nil(t, vault.SetSecretValue("db.replica.password", "other", nil))
+			names, _ := vault.ListSecretNames(nil)
+			assert.Equal(t, []string{"db.primary.password", "db.primary.port", "db.replica.password", "enabled"}, names)
+
+			assert.Nil(t, vault.DeleteSecret("db.replica.password", nil))
+
+			vault = sops.New(params)
+			names, _ = vault.ListSecretNames(nil)
+			assert.Equal(t, []string{"db.primary.password", "db.primary.port", "enabled"}, names)
+
+			// types survive the round trip.
+			if err := vault.Decrypt(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := vault.Encrypt(); err != nil {
+				t.Fatal(err)
+			}
+
+			plain, err := decrypt.File(file, vault.FileType())
+			assert.Nil(t, err)
+			assert.Contains(t, string(plain), "5432")
+			assert.NotContains(t, string(plain), `"5432"`)
+			assert.NotContains(t, string(plain), `'5432'`)
+
+			vault = sops.New(params)
+			v, _ = vault.GetSecretValue("enabled", nil)
+			assert.Equal(t, "true", v)
+		})
+	}
+}
+
+func TestFileTypeFromPath(t *testing.T) {
+	assert.Equal(t, "yaml", sops.FileTypeFromPath("a.yml"))
+	assert.Equal(t, "yaml", sops.FileTypeFromPath("a.YAML"))
+	assert.Equal(t, "json", sops.FileTypeFromPath("a.json"))
+	assert.Equal(t, "dotenv", sops.FileTypeFromPath("a.env"))
+	assert.Equal(t, "dotenv", sops.FileTypeFromPath("a.secrets"))
+}
@@ -0,0 +1,181 @@
+package sops
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+)
+
+// HistorySuffix is appended to the file of a vault for the file that
+// holds the versions of its secrets. The history is encrypted with
+// the same keys as the vault. It is written as json, sops reads the
+// timestamps of yaml as values that it cannot encrypt.
+const HistorySuffix = ".history"
+
+// HistoryFile returns the history file of the vault.
+func (s *SopsSecretVault) HistoryFile() string {
+	return s.params.File + HistorySuffix
+}
+
+func (s *SopsSecretVault) historyLimit() int {
+	if s.params.History == 0 {
+		return vaults.DefaultHistoryLimit
+	}
+
+	return s.params.History
+}
+
+func (s *SopsSecretVault) loadHistory(ctx context.Context) error {
+	if s.historyLoaded {
+		return nil
+	}
+
+	history := vaults.History{}
+	bits, err := os.ReadFile(s.HistoryFile())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err == nil {
+		data, err := s.decryptBytes(ctx, bits, "json")
+		if err != nil {
+			return fmt.Errorf("history %s: %w", s.HistoryFile(), err)
+		}
+
+		if err := json.Unmarshal(data, &history); err != nil {
+			return fmt.Errorf("invalid history %s: %w", s.HistoryFile(), err)
+		}
+
+		if history == nil {
+			history = vaults.History{}
+		}
+	}
+
+	s.history = history
+	s.historyLoaded = true
+	return nil
+}
+
+func (s *SopsSecretVault) saveHistory(ctx context.Context) error {
+	bits, err := json.Marshal(s.history)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := s.encryptBytes(ctx, bits, "json")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFile(s.HistoryFile(), bytes); err != nil {
+		return err
+	}
+
+	s.historyChanged = false
+	return nil
+}
+
+// recordVersion adds value as a version of key. A value that was set
+// before the vault kept history becomes version 1.
+func (s *SopsSecretVault) recordVersion(ctx context.Context, key, old string, existed bool, value string) error {
+	if s.params.History < 0 || (existed && old == value) {
+		return nil
+	}
+
+	if err := s.loadHistory(ctx); err != nil {
+		return err
+	}
+
+	if existed {
+		s.history.Seed(key, old)
+	}
+
+	s.history.Record(key, value, time.Now().UTC(), s.historyLimit())
+	s.historyChanged = true
+	return nil
+}
+
+// recordDelete records that key is deleted. Deleting a section of a
+// yaml or json file is not recorded.
+func (s *SopsSecretVault) recordDelete(ctx context.Context, key string) error {
+	old, ok, err := s.lookup(key)
+	if s.params.History < 0 || err != nil || !ok {
+		return nil
+	}
+
+	if err := s.loadHistory(ctx); err != nil {
+		return err
+	}
+
+	s.history.Seed(key, old)
+	s.history.Delete(key, time.Now().UTC(), s.historyLimit())
+	s.historyChanged = true
+	return nil
+}
+
+func (s *SopsSecretVault) getVersion(ctx context.Context, key, version string) (string, error) {
+	if s.params.History < 0 {
+		return "", fmt.Errorf("%w: the history of vault %s is disabled", vaults.ErrUnsupported, s.params.File)
+	}
+
+	n, err := vaults.ParseVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.loadHistory(ctx); err != nil {
+		return "", err
+	}
+
+	// secrets written before the vault kept history only have the
+	// current value as version 1.
+	if len(s.history[key]) == 0 && n == 1 {
+		if v, ok, err := s.lookup(key); err != nil || ok {
+			return v, err
+		}
+	}
+
+	entry, ok := s.history.Get(key, n)
+	if !ok || entry.Deleted {
+		return "", fmt.Errorf("%w: %s version %d", vaults.ErrSecretNotFound, key, n)
+	}
+
+	return entry.Value, nil
+}
+
+// ListSecretVersions returns the kept versions of key, oldest first.
+func (s *SopsSecretVault) ListSecretVersions(key string, params *vaults.ListSecretVersionsParams) ([]vaults.SecretVersion, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.params.History < 0 {
+		return nil, fmt.Errorf("%w: the history of vault %s is disabled", vaults.ErrUnsupported, s.params.File)
+	}
+
+	ctx := vaults.Context(params)
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadHistory(ctx); err != nil {
+		return nil, err
+	}
+
+	key = normalizeKey(key, s.fileType)
+	versions := s.history.Versions(key)
+	if len(versions) == 0 {
+		if _, ok, _ := s.lookup(key); ok {
+			versions = append(versions, vaults.SecretVersion{Version: 1})
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+	}
+
+	return versions, nil
+}
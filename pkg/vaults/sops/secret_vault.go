@@ -1,22 +1,34 @@
 package sops
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"unicode"
 
-	"github.com/getsops/sops/v3/decrypt"
-	"github.com/joho/godotenv"
 	"github.com/jolt9dev/jolt9/pkg/configs"
 	"github.com/jolt9dev/jolt9/pkg/vaults"
 )
 
+// SopsSecretVault is safe for concurrent use, the decrypted secrets
+// are shared by the calls of one vault.
 type SopsSecretVault struct {
 	params   SopsSecretVaultParams
 	fileType string
 	data     map[string]interface{}
 	loaded   bool
+	mux      sync.Mutex
+
+	// history is read from the history file on the first write or
+	// read of a version. changed marks versions that are not saved.
+	history        vaults.History
+	historyLoaded  bool
+	historyChanged bool
 }
 
 type SopsSecretVaultParams struct {
@@ -29,11 +41,30 @@ type SopsSecretVaultParams struct {
 	PgpPublicKey string
 	Driver       string
 	Indent       int
+
+	// FileType is dotenv, yaml or json. When empty it is detected
+	// from the extension of File.
+	FileType string
+
+	// History is the number of versions kept of each secret. Zero
+	// uses vaults.DefaultHistoryLimit, a negative value keeps no
+	// history.
+	History int
 }
 
+// SopsAgeParams configures the age keys of the vault. Recipients
+// encrypt the file, when empty the recipients of the identities are
+// used. The identities that decrypt the file are read from Key,
+// KeyFile, the env var KeyEnv and the secret KeySecret of KeyVault.
+// When no identity is configured sops reads SOPS_AGE_KEY,
+// SOPS_AGE_KEY_FILE or its keys.txt file.
 type SopsAgeParams struct {
 	Recipients []string
 	Key        string
+	KeyFile    string
+	KeyEnv     string
+	KeyVault   vaults.SecretVault
+	KeySecret  string
 }
 
 type SopsKmsParams struct {
@@ -47,90 +78,208 @@ func New(params SopsSecretVaultParams) *SopsSecretVault {
 		params.Driver = "age"
 	}
 
+	fileType := params.FileType
+	if fileType == "" {
+		fileType = FileTypeFromPath(params.File)
+	}
+
 	return &SopsSecretVault{
 		params:   params,
-		fileType: "dotenv",
+		fileType: fileType,
 	}
 }
 
-func FromConfig(cfg configs.VaultItem) *SopsSecretVault {
-	params := SopsSecretVaultParams{}
-	age := &SopsAgeParams{}
-	params.Driver = "age"
-	if cfg.With != nil {
-		if v, ok := cfg.With["file"]; ok {
-			params.File = v.(string)
-		}
+// FileTypeFromPath returns yaml for .yaml and .yml files, json for
+// .json files and dotenv otherwise.
+func FileTypeFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".json":
+		return "json"
+	default:
+		return "dotenv"
+	}
+}
 
-		if v, ok := cfg.With["age"]; ok {
-			params.Driver = "age"
-			params.Age = age
-			ageWith := v.(map[string]interface{})
-			if v, ok := ageWith["recipients"]; ok {
-				age.Recipients = v.([]string)
-			}
+func init() {
+	vaults.RegisterDriver("sops", OpenVault)
+}
 
-			if v, ok := ageWith["key"]; ok {
-				age.Key = v.(string)
-			}
-		}
+// OpenVault is the vault driver for the `sops` scheme.
+//
+//	vaults:
+//	  default: sops:./default.secrets.env
+//	  prod:
+//	    uri: sops:./prod.secrets.env?config=.sops.yaml
+//	    with:
+//	      age:
+//	        recipients: [age1...]
+//	        key_file: ~/.config/sops/age/prod.txt
+//
+// The age identity may also be read from an env var with `key_env`
+// or from another vault with `key_vault` and `key_secret`. Prior
+// values of secrets are kept in `<file>.history`, `history: 5` sets
+// the number of versions per secret and `history: false` disables it.
+func OpenVault(cfg *vaults.VaultConfig) (vaults.SecretVault, error) {
+	params, err := paramsFromConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	return &SopsSecretVault{
-		params: params,
+	return New(params), nil
+}
+
+// FromConfig creates the vault for a vault item. Relative paths are
+// resolved from the working directory.
+func FromConfig(cfg configs.VaultItem) (*SopsSecretVault, error) {
+	parsed, err := vaults.ParseConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := paramsFromConfig(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("vault %s: %w", cfg.Name, err)
 	}
+
+	return New(params), nil
 }
 
+// LoadData replaces the secrets of the vault. For yaml and json files
+// data may contain nested maps.
 func (s *SopsSecretVault) LoadData(data map[string]interface{}) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	s.data = data
 	s.loaded = true
 	return nil
 }
 
-func (s *SopsSecretVault) GetSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
-	if !s.loaded {
-		err := s.Decrypt()
-		if err != nil {
-			return "", err
+// FileType returns the format of the secrets file.
+func (s *SopsSecretVault) FileType() string {
+	return s.fileType
+}
+
+func (s *SopsSecretVault) load(ctx context.Context) error {
+	if s.loaded {
+		return ctx.Err()
+	}
+
+	return s.decrypt(ctx)
+}
+
+// save encrypts the changed secrets. When that fails the secrets are
+// read from the file again so that they match the file.
+func (s *SopsSecretVault) save(ctx context.Context) error {
+	if err := s.encrypt(ctx); err != nil {
+		s.reset()
+		return err
+	}
+
+	if s.historyChanged {
+		if err := s.saveHistory(ctx); err != nil {
+			s.historyLoaded = false
+			s.history = nil
+			s.historyChanged = false
+			return fmt.Errorf("secrets were saved, but their history was not: %w", err)
 		}
 	}
 
+	return nil
+}
+
+// reset drops the secrets and versions that are not saved.
+func (s *SopsSecretVault) reset() {
+	s.loaded = false
+	s.data = nil
+	s.historyLoaded = false
+	s.history = nil
+	s.historyChanged = false
+}
+
+// GetSecretValue returns the secret stored under key. Keys of yaml
+// and json files are dotted paths such as `db.primary.password`.
+func (s *SopsSecretVault) GetSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.getSecretValue(key, params)
+}
+
+func (s *SopsSecretVault) getSecretValue(key string, params *vaults.GetSecretValueParams) (string, error) {
+	if err := s.load(vaults.Context(params)); err != nil {
+		return "", err
+	}
+
+	key = normalizeKey(key, s.fileType)
+	if params != nil && params.Version != "" {
+		return s.getVersion(vaults.Context(params), key, params.Version)
+	}
+
+	v, ok, err := s.lookup(key)
+	if err != nil {
+		return "", err
+	}
+
+	if !ok {
+		return "", fmt.Errorf("%w: %s", vaults.ErrSecretNotFound, key)
+	}
+
+	return v, nil
+}
+
+// lookup returns the value of a normalized key.
+func (s *SopsSecretVault) lookup(key string) (string, bool, error) {
 	if s.fileType == "dotenv" {
-		key = normalizeKey(key, s.fileType)
-		if v, ok := s.data[key]; ok {
-			return v.(string), nil
+		v, ok := s.data[key]
+		if !ok {
+			return "", false, nil
 		}
 
-		return "", fmt.Errorf("key not found: %s", key)
-	} else {
-		return "", fmt.Errorf("unsupported file type: %s", s.fileType)
+		return fmt.Sprint(v), true, nil
+	}
+
+	v, ok := getPath(s.data, splitKey(key))
+	if !ok {
+		return "", false, nil
 	}
+
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return "", false, fmt.Errorf("%s is a section, not a secret value", key)
+	}
+
+	return fmt.Sprint(v), true, nil
 }
 
+// ListSecretNames returns the sorted keys of the vault. Nested values
+// of yaml and json files are listed by their dotted paths.
 func (s *SopsSecretVault) ListSecretNames(params *vaults.ListSecretNamesParams) ([]string, error) {
-	if !s.loaded {
-		err := s.Decrypt()
-		if err != nil {
-			return nil, err
-		}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if err := s.load(vaults.Context(params)); err != nil {
+		return nil, err
 	}
 
+	keys := []string{}
 	if s.fileType == "dotenv" {
-		keys := make([]string, 0, len(s.data))
 		for k := range s.data {
 			keys = append(keys, k)
 		}
-
-		return keys, nil
+	} else {
+		keys = flattenKeys(s.data, "", keys)
 	}
 
-	return nil, fmt.Errorf("unsupported file type: %s", s.fileType)
+	sort.Strings(keys)
+	return keys, nil
 }
 
 func (s *SopsSecretVault) BatchGetSecretValues(keys []string, params *vaults.GetSecretValueParams) (map[string]string, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
 	values := map[string]string{}
 	for _, key := range keys {
-		v, err := s.GetSecretValue(key, params)
+		v, err := s.getSecretValue(key, params)
 		if err != nil {
 			return nil, err
 		}
@@ -163,29 +312,31 @@ func (s *SopsSecretVault) MapSecretValues(query map[string]string, params *vault
 }
 
 func (s *SopsSecretVault) SetSecretValue(key, value string, params *vaults.SetSecretValueParams) error {
-	e := s.setSecretValue(key, value)
-	if e != nil {
-		return e
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ctx := vaults.Context(params)
+	if err := s.setSecretValue(ctx, key, value); err != nil {
+		s.reset()
+		return err
 	}
 
-	return s.Encrypt()
+	return s.save(ctx)
 }
 
-func (s *SopsSecretVault) setSecretValue(key, value string) error {
-	if !s.loaded {
-		err := s.Decrypt()
-		if err != nil {
-			return err
-		}
+func (s *SopsSecretVault) setSecretValue(ctx context.Context, key, value string) error {
+	if err := s.load(ctx); err != nil {
+		return err
 	}
 
+	key = normalizeKey(key, s.fileType)
+	old, existed, _ := s.lookup(key)
 	if s.fileType == "dotenv" {
-		key = normalizeKey(key, s.fileType)
 		s.data[key] = value
-		return nil
-	} else {
-		return fmt.Errorf("unsupported file type: %s", s.fileType)
+	} else if err := setPath(s.data, splitKey(key), value); err != nil {
+		return err
 	}
+
+	return s.recordVersion(ctx, key, old, existed, value)
 }
 
 func (s *SopsSecretVault) BatchSetSecretValues(values map[string]string, params *vaults.SetSecretValueParams) error {
@@ -193,60 +344,116 @@ func (s *SopsSecretVault) BatchSetSecretValues(values map[string]string, params
 		return nil
 	}
 
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ctx := vaults.Context(params)
 	for k, v := range values {
-		err := s.setSecretValue(k, v)
-		if err != nil {
+		if err := s.setSecretValue(ctx, k, v); err != nil {
+			s.reset()
 			return err
 		}
 	}
 
-	return s.Encrypt()
+	return s.save(ctx)
 }
 
 func (s *SopsSecretVault) DeleteSecret(key string, params *vaults.DeleteSecretParams) error {
-	if !s.loaded {
-		err := s.Decrypt()
-		if err != nil {
-			return err
-		}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	ctx := vaults.Context(params)
+	if err := s.load(ctx); err != nil {
+		return err
+	}
+
+	key = normalizeKey(key, s.fileType)
+	if err := s.recordDelete(ctx, key); err != nil {
+		return err
 	}
 
 	if s.fileType == "dotenv" {
-		key = normalizeKey(key, s.fileType)
-		if _, ok := s.data[key]; ok {
-			delete(s.data, key)
-			err := s.Encrypt()
-			if err != nil {
-				return err
-			}
+		if _, ok := s.data[key]; !ok {
+			return nil
 		}
-	} else {
-		return fmt.Errorf("unsupported file type: %s", s.fileType)
+
+		delete(s.data, key)
+		return s.save(ctx)
 	}
 
-	return nil
+	if !deletePath(s.data, splitKey(key)) {
+		return nil
+	}
+
+	return s.save(ctx)
 }
 
+// Encrypt encrypts the secrets and writes them to the file.
 func (s *SopsSecretVault) Encrypt() error {
+	return s.EncryptContext(context.Background())
+}
 
-	if s.fileType != "dotenv" {
-		return fmt.Errorf("unsupported file type: %s", s.fileType)
+// EncryptContext is Encrypt that stops when ctx is done. The file is
+// only written once the secrets are encrypted, a cancelled encryption
+// leaves the file as it was.
+func (s *SopsSecretVault) EncryptContext(ctx context.Context) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.encrypt(ctx)
+}
+
+func (s *SopsSecretVault) encrypt(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	kv := map[string]string{}
-	for k, v := range s.data {
-		kv[k] = v.(string)
+	bits, err := marshalData(s.data, s.fileType, s.params.Indent)
+	if err != nil {
+		return err
 	}
 
-	str, err := godotenv.Marshal(kv)
+	bytes, err := s.encryptBytes(ctx, bits, s.fileType)
 	if err != nil {
 		return err
 	}
 
-	bits := []byte(str)
-	fi, err := os.Stat(s.params.File)
-	var mode os.FileMode
-	mode = 0644
+	return writeFile(s.params.File, bytes)
+}
+
+// encryptBytes encrypts plain with the keys of the vault. The creation
+// rules of the sops config are matched against the file of the vault.
+func (s *SopsSecretVault) encryptBytes(ctx context.Context, plain []byte, fileType string) ([]byte, error) {
+	var ageParams *SopsAgeParams
+	if s.params.Age != nil {
+		recipients, err := s.params.Age.recipients()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(recipients) > 0 {
+			ageParams = &SopsAgeParams{Recipients: recipients}
+		}
+	}
+
+	// key services such as kms may block on the network.
+	return run(ctx, func() ([]byte, error) {
+		return encryptOutput(SopsEncryptParams{
+			File:       s.params.File,
+			FileType:   fileType,
+			Indent:     s.params.Indent,
+			ConfigPath: s.params.ConfileFile,
+			Age:        ageParams,
+			Kms:        s.params.Kms,
+			AzureKvUri: s.params.AzureKvUri,
+			VaultUri:   s.params.VaultUri,
+			PgpKey:     s.params.PgpPublicKey,
+			Plain:      plain,
+		})
+	})
+}
+
+// writeFile writes data to file and keeps the mode of an existing file.
+func writeFile(file string, data []byte) error {
+	var mode os.FileMode = 0644
+	fi, err := os.Stat(file)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -255,54 +462,100 @@ func (s *SopsSecretVault) Encrypt() error {
 		mode = fi.Mode()
 	}
 
-	// TODO: check if directory exists
-	if err = os.WriteFile(s.params.File, bits, mode); err != nil {
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
 		return err
 	}
 
-	bytes, err := encryptOutput(SopsEncryptParams{
-		File:       s.params.File,
-		FileType:   s.fileType,
-		Indent:     0,
-		ConfigPath: s.params.ConfileFile,
-		Age:        s.params.Age,
-		Kms:        s.params.Kms,
-		AzureKvUri: s.params.AzureKvUri,
-		VaultUri:   s.params.VaultUri,
-		PgpKey:     s.params.PgpPublicKey,
-	})
+	return os.WriteFile(file, data, mode)
+}
 
-	if err != nil {
-		return err
-	}
+// Decrypt reads and decrypts the file. A missing file is an empty
+// vault so that the first secret creates it.
+func (s *SopsSecretVault) Decrypt() error {
+	return s.DecryptContext(context.Background())
+}
 
-	return os.WriteFile(s.params.File, bytes, mode)
+// DecryptContext is Decrypt that stops when ctx is done. It returns
+// an error that wraps vaults.ErrVaultLocked when no configured key
+// decrypts the file.
+func (s *SopsSecretVault) DecryptContext(ctx context.Context) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.decrypt(ctx)
 }
 
-func (s *SopsSecretVault) Decrypt() error {
+func (s *SopsSecretVault) decrypt(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 
-	data, err := decrypt.File(s.params.File, s.fileType)
+	bits, err := os.ReadFile(s.params.File)
 	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			s.data = map[string]interface{}{}
+			s.loaded = true
+			return nil
+		}
+
 		return err
 	}
 
-	if s.fileType != "dotenv" {
-		return fmt.Errorf("unsupported file type: %s", s.fileType)
+	data, err := s.decryptBytes(ctx, bits, s.fileType)
+	if err != nil {
+		return err
 	}
 
-	kv, err := godotenv.UnmarshalBytes(data)
+	values, err := unmarshalData(data, s.fileType)
 	if err != nil {
 		return err
 	}
 
-	s.data = map[string]interface{}{}
-	for k, v := range kv {
-		s.data[k] = v
+	s.data = values
+	s.loaded = true
+	return nil
+}
+
+func (s *SopsSecretVault) decryptBytes(ctx context.Context, bits []byte, fileType string) ([]byte, error) {
+	identities, err := s.params.Age.identities()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", vaults.ErrVaultLocked, err)
 	}
 
-	return nil
+	return run(ctx, func() ([]byte, error) {
+		return decryptData(bits, fileType, identities)
+	})
+}
+
+// run calls fn and returns early with the error of ctx when ctx is
+// done first. fn keeps running in the background, its result is
+// dropped.
+func run(ctx context.Context, fn func() ([]byte, error)) ([]byte, error) {
+	if ctx.Done() == nil {
+		return fn()
+	}
+
+	type result struct {
+		data []byte
+		err  error
+	}
+
+	ch := make(chan result, 1)
+	go func() {
+		data, err := fn()
+		ch <- result{data, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		return r.data, r.err
+	}
 }
 
+// normalizeKey maps a key to the key used in the file. Dotenv keys
+// may only contain letters, digits and underscores, the keys of yaml
+// and json files are dotted paths where `/` and `:` also separate.
 func normalizeKey(key string, filetype string) string {
 	if filetype == "dotenv" {
 		sb := strings.Builder{}
@@ -323,7 +576,7 @@ func normalizeKey(key string, filetype string) string {
 
 	sb := strings.Builder{}
 	for _, c := range key {
-		if c == '_' || c == '-' || c == '.' || c == '/' || c == ':' {
+		if c == '/' || c == ':' {
 			sb.WriteRune('.')
 			continue
 		}
@@ -2,10 +2,12 @@ package sops_test
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"filippo.io/age"
 	"github.com/jolt9dev/jolt9/internal/fs"
+	"github.com/jolt9dev/jolt9/pkg/vaults"
 	"github.com/jolt9dev/jolt9/pkg/vaults/sops"
 	"github.com/stretchr/testify/assert"
 )
@@ -21,6 +23,10 @@ func TestSopsSecretVault(t *testing.T) {
 		if fs.Exists("./.env") {
 			err = os.Remove("./.env")
 		}
+
+		if fs.Exists("./.env.history") {
+			err = os.Remove("./.env.history")
+		}
 	}()
 
 	publicKey := id.Recipient().String()
@@ -76,3 +82,48 @@ func TestSopsSecretVault(t *testing.T) {
 	_, err = vault.GetSecretValue("NEW_VAR", nil)
 	assert.NotNil(t, err)
 }
+
+func TestSopsSecretVaultHistory(t *testing.T) {
+	id, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(t.TempDir(), "secrets.env")
+	open := func() *sops.SopsSecretVault {
+		return sops.New(sops.SopsSecretVaultParams{File: file, Age: &sops.SopsAgeParams{Key: id.String()}})
+	}
+
+	vault := open()
+	assert.Nil(t, vault.LoadData(map[string]interface{}{"TOKEN": "one"}))
+	assert.Nil(t, vault.Encrypt())
+
+	// the value from before the history becomes version 1.
+	assert.Nil(t, vault.SetSecretValue("TOKEN", "two", nil))
+	assert.Nil(t, vault.SetSecretValue("TOKEN", "two", nil))
+	assert.FileExists(t, vault.HistoryFile())
+
+	vault = open()
+	versions, err := vault.ListSecretVersions("TOKEN", nil)
+	assert.Nil(t, err)
+	assert.Len(t, versions, 2)
+	assert.True(t, versions[0].Created.IsZero())
+	assert.False(t, versions[1].Created.IsZero())
+
+	v, err := vault.GetSecretValue("TOKEN", &vaults.GetSecretValueParams{Version: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "one", v)
+
+	assert.Nil(t, vaults.Rollback(vault, "TOKEN", "1", nil))
+	v, _ = open().GetSecretValue("TOKEN", nil)
+	assert.Equal(t, "one", v)
+
+	assert.Nil(t, vault.DeleteSecret("TOKEN", nil))
+	versions, _ = open().ListSecretVersions("TOKEN", nil)
+	assert.Equal(t, 4, versions[len(versions)-1].Version)
+	assert.True(t, versions[len(versions)-1].Deleted)
+
+	raw, err := os.ReadFile(vault.HistoryFile())
+	assert.Nil(t, err)
+	assert.NotContains(t, string(raw), "two")
+}
@@ -1,11 +1,112 @@
 package vaults
 
-import "context"
+import (
+	"context"
+	"errors"
+)
 
+var (
+	// ErrSecretNotFound is returned by vaults when a secret does not exist.
+	ErrSecretNotFound = errors.New("secret not found")
+
+	// ErrVaultLocked is returned when the vault can not be read with the
+	// configured keys or credentials, e.g. no age identity matches or
+	// the token was denied.
+	ErrVaultLocked = errors.New("vault is locked")
+
+	// ErrUnsupported is returned for operations or parameters that a
+	// driver does not support, e.g. reading a version of a secret.
+	ErrUnsupported = errors.New("operation not supported")
+)
+
+// OperationParams are the params shared by every vault operation.
+// Params are optional, a nil params or a nil Context uses
+// context.Background(). Drivers stop when the context is cancelled or
+// its deadline passes and return the error of the context.
 type OperationParams struct {
 	Context context.Context
 }
 
+// Params is implemented by the params of every vault operation.
+type Params interface {
+	operation() *OperationParams
+}
+
+func (p *OperationParams) operation() *OperationParams {
+	return p
+}
+
+// the params are usually passed as nil pointers, so each type checks
+// for nil before taking the address of the embedded params.
+
+func (p *GetSecretValueParams) operation() *OperationParams {
+	if p == nil {
+		return nil
+	}
+
+	return &p.OperationParams
+}
+
+func (p *BatchGetSecretValuesParams) operation() *OperationParams {
+	if p == nil {
+		return nil
+	}
+
+	return &p.OperationParams
+}
+
+func (p *SetSecretValueParams) operation() *OperationParams {
+	if p == nil {
+		return nil
+	}
+
+	return &p.OperationParams
+}
+
+func (p *BatchSetSecretValuesParams) operation() *OperationParams {
+	if p == nil {
+		return nil
+	}
+
+	return &p.OperationParams
+}
+
+func (p *DeleteSecretParams) operation() *OperationParams {
+	if p == nil {
+		return nil
+	}
+
+	return &p.OperationParams
+}
+
+func (p *ListSecretNamesParams) operation() *OperationParams {
+	if p == nil {
+		return nil
+	}
+
+	return &p.OperationParams
+}
+
+// Context returns the context of params or context.Background().
+func Context(params Params) context.Context {
+	if params == nil {
+		return context.Background()
+	}
+
+	op := params.operation()
+	if op == nil || op.Context == nil {
+		return context.Background()
+	}
+
+	return op.Context
+}
+
+// Err returns the error of the context of params, it is nil unless
+// the context is cancelled or past its deadline.
+func Err(params Params) error {
+	return Context(params).Err()
+}
+
 type GetSecretValueParams struct {
 	OperationParams
 	Version string
@@ -46,3 +147,14 @@ type SecretVault interface {
 
 	DeleteSecret(key string, params *DeleteSecretParams) error
 }
+
+// RecipientVault is implemented by vaults that encrypt secrets to a
+// list of public keys. Changing the recipients re-encrypts every
+// secret of the vault.
+type RecipientVault interface {
+	Recipients() ([]string, error)
+
+	AddRecipients(recipients ...string) error
+
+	RemoveRecipients(recipients ...string) error
+}
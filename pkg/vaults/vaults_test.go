@@ -0,0 +1,23 @@
+package vaults_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jolt9dev/jolt9/pkg/vaults"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContext(t *testing.T) {
+	var params *vaults.GetSecretValueParams
+	assert.Equal(t, context.Background(), vaults.Context(params))
+	assert.Equal(t, context.Background(), vaults.Context(nil))
+	assert.Equal(t, context.Background(), vaults.Context(&vaults.DeleteSecretParams{}))
+	assert.Nil(t, vaults.Err(params))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	set := &vaults.SetSecretValueParams{OperationParams: vaults.OperationParams{Context: ctx}}
+	assert.Equal(t, ctx, vaults.Context(set))
+	assert.ErrorIs(t, vaults.Err(set), context.Canceled)
+}